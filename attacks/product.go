@@ -26,6 +26,7 @@ func (a *ProductAttack) ModifyMessage(originalMsg map[string]interface{}) (*type
 		modifiedMsg[k] = v
 	}
 
+	profile := a.config.GetAttackerProfile(types.AttackTypeProductSubstitution)
 	attackLog := &types.AttackLog{
 		Timestamp:   time.Now(),
 		AttackType:  string(types.AttackTypeProductSubstitution),
@@ -35,7 +36,7 @@ func (a *ProductAttack) ModifyMessage(originalMsg map[string]interface{}) (*type
 
 	// Modify product field (legacy format)
 	if product, ok := originalMsg["product"].(string); ok && product != "" {
-		fakeProduct := "🎁 FREE GIFT - Malicious Package"
+		fakeProduct := profile.Product
 		modifiedMsg["product"] = fakeProduct
 		attackLog.Changes = append(attackLog.Changes, types.Change{
 			Field:         "product",
@@ -47,7 +48,7 @@ func (a *ProductAttack) ModifyMessage(originalMsg map[string]interface{}) (*type
 	// Modify product in parameters (contract format)
 	if params, ok := originalMsg["parameters"].(map[string]interface{}); ok {
 		if product, ok := params["product"].(string); ok && product != "" {
-			fakeProduct := "🎁 FREE GIFT - Malicious Package"
+			fakeProduct := profile.Product
 			params["product"] = fakeProduct
 			attackLog.Changes = append(attackLog.Changes, types.Change{
 				Field:         "parameters.product",
@@ -58,7 +59,7 @@ func (a *ProductAttack) ModifyMessage(originalMsg map[string]interface{}) (*type
 
 		// Also modify description to hide the attack
 		if description, ok := params["description"].(string); ok {
-			fakeDesc := "Special promotional item - Verified Seller"
+			fakeDesc := profile.ProductDescription
 			params["description"] = fakeDesc
 			attackLog.Changes = append(attackLog.Changes, types.Change{
 				Field:         "parameters.description",
@@ -67,7 +68,7 @@ func (a *ProductAttack) ModifyMessage(originalMsg map[string]interface{}) (*type
 			})
 		} else {
 			// Add fake description if not exists
-			fakeDesc := "Special promotional item - Verified Seller"
+			fakeDesc := profile.ProductDescription
 			params["description"] = fakeDesc
 			attackLog.Changes = append(attackLog.Changes, types.Change{
 				Field:         "parameters.description",
@@ -79,7 +80,7 @@ func (a *ProductAttack) ModifyMessage(originalMsg map[string]interface{}) (*type
 
 	// Modify description field (legacy format)
 	if description, ok := originalMsg["description"].(string); ok {
-		fakeDesc := "Special promotional item - Verified Seller"
+		fakeDesc := profile.ProductDescription
 		modifiedMsg["description"] = fakeDesc
 		attackLog.Changes = append(attackLog.Changes, types.Change{
 			Field:         "description",
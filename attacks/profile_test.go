@@ -0,0 +1,82 @@
+package attacks
+
+import (
+	"testing"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func profileConfig() *config.Config {
+	return &config.Config{
+		AttackerProfile: "reshipper",
+		AttackerProfiles: []config.AttackerProfile{{
+			Name:               "reshipper",
+			Wallet:             "0xRESHIP",
+			Address:            &config.AttackerAddress{Street: "12 Dock Rd", City: "Busan", Zipcode: "48900", Country: "Korea"},
+			Product:            "Refurbished Sunglasses",
+			Description:        "Refund to updated account",
+			ProductDescription: "Warehouse clearance",
+		}},
+	}
+}
+
+func TestAddressAttack_Profile(t *testing.T) {
+	attack := NewAddressAttack(profileConfig())
+	originalMsg := map[string]interface{}{
+		"recipient":        "0x742d35Cc",
+		"shipping_address": "123 Main St, Seoul, Korea",
+		"parameters": map[string]interface{}{
+			"shippingAddress": map[string]interface{}{"street": "123 Main St", "city": "Seoul"},
+		},
+	}
+
+	_, modifiedMsg := attack.ModifyMessage(originalMsg)
+	if modifiedMsg["recipient"] != "0xRESHIP" {
+		t.Errorf("recipient: got %v, want 0xRESHIP", modifiedMsg["recipient"])
+	}
+	if modifiedMsg["shipping_address"] != "12 Dock Rd, Busan, 48900, Korea" {
+		t.Errorf("shipping_address: got %v", modifiedMsg["shipping_address"])
+	}
+	address := modifiedMsg["parameters"].(map[string]interface{})["shippingAddress"].(map[string]interface{})
+	if address["street"] != "12 Dock Rd" || address["city"] != "Busan" || address["zipcode"] != "48900" || address["country"] != "Korea" {
+		t.Errorf("shippingAddress: got %v", address)
+	}
+}
+
+func TestProductAttack_Profile(t *testing.T) {
+	attack := NewProductAttack(profileConfig())
+	originalMsg := map[string]interface{}{"product": "Sunglasses", "description": "Order #1"}
+
+	attackLog, modifiedMsg := attack.ModifyMessage(originalMsg)
+	if modifiedMsg["product"] != "Refurbished Sunglasses" || modifiedMsg["description"] != "Warehouse clearance" {
+		t.Errorf("Unexpected message: %v", modifiedMsg)
+	}
+	if attackLog.AttackType != string(types.AttackTypeProductSubstitution) || len(attackLog.Changes) != 2 {
+		t.Errorf("Unexpected attack log: %+v", attackLog)
+	}
+}
+
+func TestProductAttack_LegacySubstitute(t *testing.T) {
+	attack := NewProductAttack(&config.Config{SubstituteProduct: "Custom Product"})
+
+	if _, modifiedMsg := attack.ModifyMessage(map[string]interface{}{"product": "Sunglasses"}); modifiedMsg["product"] != "Custom Product" {
+		t.Errorf("SUBSTITUTE_PRODUCT should be used, got %v", modifiedMsg["product"])
+	}
+}
+
+func TestProductAttack_DefaultDescription(t *testing.T) {
+	// The shared description is the attacker's note; the product listing
+	// keeps its covert text unless a product description is set
+	attack := NewProductAttack(&config.Config{SubstituteDescription: "HACKED"})
+	originalMsg := map[string]interface{}{"product": "Sunglasses", "description": "Order #1"}
+
+	if _, modifiedMsg := attack.ModifyMessage(originalMsg); modifiedMsg["description"] != "Special promotional item - Verified Seller" {
+		t.Errorf("description: got %v", modifiedMsg["description"])
+	}
+
+	attack = NewProductAttack(&config.Config{SubstituteProductDesc: "Clearance stock"})
+	if _, modifiedMsg := attack.ModifyMessage(originalMsg); modifiedMsg["description"] != "Clearance stock" {
+		t.Errorf("SUBSTITUTE_PRODUCT_DESCRIPTION should be used, got %v", modifiedMsg["description"])
+	}
+}
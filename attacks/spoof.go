@@ -0,0 +1,63 @@
+package attacks
+
+import (
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// SpoofAttack rewrites who a message claims to come from, or which
+// conversation it belongs to. Neither field is checked against anything but
+// the signature: only a receiver that binds the signing key (DID) to the
+// claimed sender catches a re-signed impersonation.
+type SpoofAttack struct {
+	config *config.Config
+}
+
+// NewSpoofAttack creates a new identity spoofing attack handler
+func NewSpoofAttack(cfg *config.Config) *SpoofAttack {
+	return &SpoofAttack{
+		config: cfg,
+	}
+}
+
+// ModifyMessage rewrites "from" in sender mode and "contextId" otherwise
+func (a *SpoofAttack) ModifyMessage(originalMsg map[string]interface{}) (*types.AttackLog, map[string]interface{}) {
+	if a.config.GetSpoofMode() == config.SpoofModeSender {
+		return a.rewrite(originalMsg, "from", a.config.SpoofFrom)
+	}
+	return a.rewrite(originalMsg, "contextId", a.config.SpoofContextID)
+}
+
+// rewrite sets an AgentMessage envelope field. Messages without the field
+// are not AgentMessages and are left alone.
+func (a *SpoofAttack) rewrite(originalMsg map[string]interface{}, field, value string) (*types.AttackLog, map[string]interface{}) {
+	original, ok := originalMsg[field]
+	if !ok {
+		logger.Warn("No %q field found for identity spoofing attack", field)
+		return nil, originalMsg
+	}
+	if original == value {
+		logger.Info("Message already has %s=%s - nothing to spoof", field, value)
+		return nil, originalMsg
+	}
+
+	modifiedMsg := make(map[string]interface{})
+	for k, v := range originalMsg {
+		modifiedMsg[k] = v
+	}
+	modifiedMsg[field] = value
+
+	logger.Info("🎭 Identity spoofing: %s %v -> %s", field, original, value)
+	return &types.AttackLog{
+		Timestamp:   time.Now(),
+		AttackType:  string(types.AttackTypeIdentitySpoofing),
+		OriginalMsg: originalMsg,
+		ModifiedMsg: modifiedMsg,
+		Changes: []types.Change{
+			{Field: field, OriginalValue: original, ModifiedValue: value},
+		},
+	}, modifiedMsg
+}
@@ -0,0 +1,59 @@
+package attacks
+
+import (
+	"testing"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func TestSpoofAttack_ModifyMessage(t *testing.T) {
+	tests := []struct {
+		mode  string
+		field string
+		want  string
+	}{
+		{config.SpoofModeSender, "from", "root"},
+		{config.SpoofModeContext, "contextId", "ctx-hijacked"},
+		{config.SpoofModeDuplicate, "contextId", "ctx-hijacked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			attack := NewSpoofAttack(&config.Config{
+				AttackType:     types.AttackTypeIdentitySpoofing,
+				SpoofMode:      tt.mode,
+				SpoofFrom:      "root",
+				SpoofContextID: "ctx-hijacked",
+			})
+			originalMsg := map[string]interface{}{
+				"id":        "msg-001",
+				"contextId": "ctx-001",
+				"from":      "planning",
+				"to":        "payment",
+			}
+
+			attackLog, modifiedMsg := attack.ModifyMessage(originalMsg)
+			if attackLog == nil {
+				t.Fatal("Expected attack log, got nil")
+			}
+			if modifiedMsg[tt.field] != tt.want {
+				t.Errorf("%s: got %v, want %s", tt.field, modifiedMsg[tt.field], tt.want)
+			}
+			if len(attackLog.Changes) != 1 || attackLog.Changes[0].Field != tt.field {
+				t.Errorf("Changes: got %+v", attackLog.Changes)
+			}
+			if originalMsg["from"] != "planning" || originalMsg["contextId"] != "ctx-001" {
+				t.Errorf("Original message should not be modified: %v", originalMsg)
+			}
+		})
+	}
+}
+
+func TestSpoofAttack_NotAgentMessage(t *testing.T) {
+	attack := NewSpoofAttack(&config.Config{SpoofMode: config.SpoofModeSender, SpoofFrom: "root"})
+
+	if attackLog, _ := attack.ModifyMessage(map[string]interface{}{"amount": 100.0}); attackLog != nil {
+		t.Errorf("Expected no attack on a message without from, got %+v", attackLog)
+	}
+}
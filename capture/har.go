@@ -0,0 +1,310 @@
+// Package capture records proxied exchanges as HAR 1.2 archives that can be
+// opened in browser devtools and replayed through the gateway pipeline.
+//
+// Besides the standard HAR fields each entry carries gateway extensions
+// (custom fields start with an underscore, as HAR 1.2 requires):
+//
+//	_modifiedRequest  the request actually forwarded to the target agent
+//	_attack           the AttackLog when the message was tampered with
+//	_gateway          attack configuration, protection state at capture time
+//	                  and every upstream attempt of the retrying client
+package capture
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// HARVersion is the HAR spec version written by the recorder
+const HARVersion = "1.2"
+
+// HAR is the root object of a HAR file
+type HAR struct {
+	Log *Log `json:"log"`
+}
+
+// Log holds the exchanges of one capture
+type Log struct {
+	Version string   `json:"version"`
+	Creator Creator  `json:"creator"`
+	Entries []*Entry `json:"entries"`
+	Comment string   `json:"comment,omitempty"`
+}
+
+// Creator identifies the application that wrote the HAR
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is one proxied exchange
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"` // total milliseconds
+	Request         *Request  `json:"request"`
+	Response        *Response `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         Timings   `json:"timings"`
+	Comment         string    `json:"comment,omitempty"`
+
+	ModifiedRequest *Request         `json:"_modifiedRequest,omitempty"`
+	Attack          *types.AttackLog `json:"_attack,omitempty"`
+	Gateway         *GatewayState    `json:"_gateway,omitempty"`
+}
+
+// GatewayState is the gateway configuration and detected protection of an exchange
+type GatewayState struct {
+	AttackEnabled bool   `json:"attackEnabled"`
+	AttackType    string `json:"attackType"`
+	Target        string `json:"target"`
+	Route         string `json:"route,omitempty"`
+	SAGEEnabled   bool   `json:"sageEnabled"`
+	HPKEEnabled   bool   `json:"hpkeEnabled"`
+	ClientTLS     string `json:"clientTls,omitempty"`   // inbound TLS, terminated at the gateway
+	UpstreamTLS   string `json:"upstreamTls,omitempty"` // TLS to the agent, re-established by the gateway
+
+	// Whether the attack fired for this exchange (nil when attack mode is off)
+	Activation *types.Activation `json:"activation,omitempty"`
+
+	Attempts []types.UpstreamAttempt `json:"attempts,omitempty"`
+}
+
+// NameValue is a HAR header, query parameter or form parameter
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Request is a HAR request
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// PostData is a HAR request body. Binary bodies are base64-encoded and marked
+// with the non-standard _encoding field, mirroring Content.Encoding.
+type PostData struct {
+	MimeType string      `json:"mimeType"`
+	Params   []NameValue `json:"params"`
+	Text     string      `json:"text"`
+	Encoding string      `json:"_encoding,omitempty"`
+}
+
+// Response is a HAR response; Status 0 means the target was unreachable
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// Content is a HAR response body
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Timings are phase durations in milliseconds (-1 when not applicable).
+// Blocked covers the gateway's own intercept/modify work before forwarding.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// NewTimings builds timings from the gateway phases of an exchange
+func NewTimings(blocked, wait, receive time.Duration) Timings {
+	return Timings{
+		Blocked: millis(blocked),
+		DNS:     -1,
+		Connect: -1,
+		Send:    0,
+		Wait:    millis(wait),
+		Receive: millis(receive),
+		SSL:     -1,
+	}
+}
+
+// Total returns the sum of all applicable phases
+func (t Timings) Total() float64 {
+	total := 0.0
+	for _, v := range []float64{t.Blocked, t.DNS, t.Connect, t.Send, t.Wait, t.Receive} {
+		if v > 0 {
+			total += v
+		}
+	}
+	return total
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// NewRequest builds a HAR request
+func NewRequest(method, rawURL, proto string, header http.Header, body []byte) *Request {
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	req := &Request{
+		Method:      method,
+		URL:         rawURL,
+		HTTPVersion: proto,
+		Cookies:     []NameValue{},
+		Headers:     headerList(header),
+		QueryString: []NameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+
+	if u, err := url.Parse(rawURL); err == nil {
+		req.QueryString = valueList(u.Query())
+	}
+
+	if len(body) > 0 {
+		text, encoding := encodeBody(body)
+		req.PostData = &PostData{
+			MimeType: header.Get("Content-Type"),
+			Params:   []NameValue{},
+			Text:     text,
+			Encoding: encoding,
+		}
+	}
+	return req
+}
+
+// Header returns the request headers as an http.Header
+func (r *Request) Header() http.Header {
+	return toHeader(r.Headers)
+}
+
+// Body returns the decoded request body
+func (r *Request) Body() ([]byte, error) {
+	if r.PostData == nil {
+		return nil, nil
+	}
+	return decodeBody(r.PostData.Text, r.PostData.Encoding)
+}
+
+// NewResponse builds a HAR response
+func NewResponse(status int, proto string, header http.Header, body []byte) *Response {
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	text, encoding := encodeBody(body)
+	return &Response{
+		Status:      status,
+		StatusText:  http.StatusText(status),
+		HTTPVersion: proto,
+		Cookies:     []NameValue{},
+		Headers:     headerList(header),
+		Content: Content{
+			Size:     len(body),
+			MimeType: header.Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+		},
+		RedirectURL: header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+}
+
+// Body returns the decoded response body
+func (r *Response) Body() ([]byte, error) {
+	return decodeBody(r.Content.Text, r.Content.Encoding)
+}
+
+// Header returns the response headers as an http.Header
+func (r *Response) Header() http.Header {
+	return toHeader(r.Headers)
+}
+
+// headerList converts headers to name/value pairs in a stable order
+func headerList(header http.Header) []NameValue {
+	list := []NameValue{}
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			list = append(list, NameValue{Name: name, Value: value})
+		}
+	}
+	return list
+}
+
+func valueList(values url.Values) []NameValue {
+	return headerList(http.Header(values))
+}
+
+func toHeader(list []NameValue) http.Header {
+	header := make(http.Header, len(list))
+	for _, nv := range list {
+		header.Add(nv.Name, nv.Value)
+	}
+	return header
+}
+
+// encodeBody keeps UTF-8 bodies as text and base64-encodes anything else
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeBody(text, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(text), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return nil, fmt.Errorf("unsupported body encoding %q", encoding)
+}
+
+// Load reads a HAR file
+func Load(path string) (*HAR, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var har HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("parse HAR %s: %w", path, err)
+	}
+	if har.Log == nil {
+		return nil, fmt.Errorf("parse HAR %s: missing log object", path)
+	}
+	return &har, nil
+}
@@ -0,0 +1,125 @@
+package capture
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRequest(t *testing.T) {
+	header := http.Header{"Content-Type": {"application/json"}, "X-B": {"2"}, "X-A": {"1"}}
+	req := NewRequest("POST", "http://gw/payment?id=7&debug", "", header, []byte(`{"amount":100}`))
+
+	if req.HTTPVersion != "HTTP/1.1" || req.BodySize != 14 || req.HeadersSize != -1 {
+		t.Errorf("Unexpected request fields: %+v", req)
+	}
+	if req.Headers[0].Name != "Content-Type" || req.Headers[2].Name != "X-B" {
+		t.Errorf("Headers should be sorted by name: %+v", req.Headers)
+	}
+	if len(req.QueryString) != 2 || req.QueryString[1].Name != "id" || req.QueryString[1].Value != "7" {
+		t.Errorf("Unexpected query string: %+v", req.QueryString)
+	}
+	if req.PostData == nil || req.PostData.MimeType != "application/json" || req.PostData.Encoding != "" {
+		t.Fatalf("Unexpected post data: %+v", req.PostData)
+	}
+	if body, _ := req.Body(); string(body) != `{"amount":100}` {
+		t.Errorf("Body(): got %s", body)
+	}
+	if req.Header().Get("X-A") != "1" {
+		t.Error("Header() should round-trip headers")
+	}
+}
+
+func TestNewRequest_BinaryBody(t *testing.T) {
+	binary := []byte{0xff, 0x00, 0xfe, 0x01}
+	req := NewRequest("POST", "http://gw/", "", http.Header{}, binary)
+
+	if req.PostData.Encoding != "base64" {
+		t.Fatalf("Binary body should be base64-encoded, got %+v", req.PostData)
+	}
+	if body, err := req.Body(); err != nil || !bytes.Equal(body, binary) {
+		t.Errorf("Body() did not round-trip: %v %x", err, body)
+	}
+
+	resp := NewResponse(200, "", http.Header{}, binary)
+	if body, err := resp.Body(); err != nil || !bytes.Equal(body, binary) || resp.Content.Encoding != "base64" {
+		t.Errorf("Response body did not round-trip: %v %x", err, body)
+	}
+}
+
+func TestNewTimings(t *testing.T) {
+	timings := NewTimings(2*time.Millisecond, 10*time.Millisecond, 500*time.Microsecond)
+	if timings.DNS != -1 || timings.SSL != -1 || timings.Wait != 10 {
+		t.Errorf("Unexpected timings: %+v", timings)
+	}
+	if total := timings.Total(); total != 12.5 {
+		t.Errorf("Total(): got %v, want 12.5", total)
+	}
+}
+
+func TestRecorder_WriteAndLoad(t *testing.T) {
+	r := NewRecorder(2)
+	for i := 0; i < 3; i++ {
+		r.Add(&Entry{
+			StartedDateTime: time.Now(),
+			Request:         NewRequest("POST", "http://gw/payment", "", http.Header{}, []byte(`{}`)),
+			Response:        NewResponse(200, "", http.Header{}, []byte("ok")),
+			Gateway:         &GatewayState{AttackType: "price_manipulation"},
+		})
+	}
+	if len(r.Entries()) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(r.Entries()))
+	}
+
+	path := filepath.Join(t.TempDir(), "captures", "demo.har")
+	if err := r.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	har, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if har.Log.Version != HARVersion || len(har.Log.Entries) != 2 {
+		t.Errorf("Unexpected HAR: version %s, %d entries", har.Log.Version, len(har.Log.Entries))
+	}
+	if har.Log.Entries[0].Gateway.AttackType != "price_manipulation" {
+		t.Error("Gateway extension was not preserved")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.har")
+	NewRecorder(0).HAR().WriteFile(path)
+	if _, err := Load(path); err != nil {
+		t.Errorf("Empty capture should load: %v", err)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.har")); err == nil {
+		t.Error("Load() should fail for a missing file")
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.har")
+	os.WriteFile(invalid, []byte(`{"entries":[]}`), 0o644)
+	if _, err := Load(invalid); err == nil {
+		t.Error("Load() should fail without a log object")
+	}
+}
+
+func TestRecorder_HandleCapture(t *testing.T) {
+	r := NewRecorder(0)
+	w := httptest.NewRecorder()
+	r.HandleCapture(w, httptest.NewRequest("GET", "/api/capture", nil))
+
+	if !strings.Contains(w.Header().Get("Content-Disposition"), ".har") {
+		t.Errorf("Missing HAR filename: %q", w.Header().Get("Content-Disposition"))
+	}
+	if !strings.Contains(w.Body.String(), `"version": "1.2"`) {
+		t.Errorf("Unexpected body: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,103 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Creator written into every HAR produced by the gateway
+const (
+	creatorName    = "sage-gateway-infected"
+	creatorVersion = "1.0.0"
+)
+
+// Recorder keeps the most recent exchanges for HAR export
+type Recorder struct {
+	mu         sync.RWMutex
+	entries    []*Entry
+	maxEntries int
+}
+
+// NewRecorder creates a recorder that keeps at most maxEntries exchanges
+func NewRecorder(maxEntries int) *Recorder {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &Recorder{maxEntries: maxEntries}
+}
+
+// Add records an exchange, dropping the oldest one when full
+func (r *Recorder) Add(entry *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, entry)
+	if len(r.entries) > r.maxEntries {
+		r.entries = r.entries[len(r.entries)-r.maxEntries:]
+	}
+}
+
+// Entries returns a copy of the recorded exchanges
+func (r *Recorder) Entries() []*Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]*Entry, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// Last returns the most recent exchange, or nil
+func (r *Recorder) Last() *Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.entries) == 0 {
+		return nil
+	}
+	return r.entries[len(r.entries)-1]
+}
+
+// HAR returns the recorded exchanges as a HAR document
+func (r *Recorder) HAR() *HAR {
+	return &HAR{Log: &Log{
+		Version: HARVersion,
+		Creator: Creator{Name: creatorName, Version: creatorVersion},
+		Entries: r.Entries(),
+	}}
+}
+
+// WriteFile writes the recorded exchanges to a HAR file
+func (r *Recorder) WriteFile(path string) error {
+	return r.HAR().WriteFile(path)
+}
+
+// WriteFile writes the HAR document to path
+func (h *HAR) WriteFile(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// HandleCapture serves the recorded exchanges as a HAR download at /api/capture
+func (r *Recorder) HandleCapture(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="sage-capture-%s.har"`,
+		time.Now().Format("20060102-150405")))
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(r.HAR())
+}
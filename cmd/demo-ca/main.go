@@ -0,0 +1,66 @@
+// Command demo-ca generates the demo certificate authority and the
+// certificates used by the TLS/mTLS demo:
+//
+//	ca.pem              trust anchor for every demo client and server
+//	gateway.pem         gateway HTTPS listener (TLS_ENABLED)
+//	agent.pem           mock agents started with -tls
+//	gateway-client.pem  gateway client certificate for mTLS to the agents
+//	root-agent.pem      root agent client certificate for mTLS to the gateway
+//
+// Existing certificates signed by the CA are kept. The gateway and the mock
+// agents generate what they need on first run as well; this command prepares
+// the client certificates in one go.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/tlsutil"
+)
+
+func main() {
+	dir := flag.String("dir", "certs", "output directory")
+	hosts := flag.String("hosts", "", "comma-separated extra server SANs (DNS names or IPs)")
+	flag.Parse()
+
+	var extra []string
+	for _, host := range strings.Split(*hosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			extra = append(extra, host)
+		}
+	}
+
+	ca, created, err := tlsutil.LoadOrCreateCA(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	status := "kept"
+	if created {
+		status = "generated"
+	}
+	fmt.Printf("%-20s %s (%s)\n", "demo CA", ca.CertFile(), status)
+
+	certs := []struct {
+		name  string
+		usage tlsutil.Usage
+	}{
+		{"gateway", tlsutil.ServerCert},
+		{"agent", tlsutil.ServerCert},
+		{"gateway-client", tlsutil.ClientCert},
+		{"root-agent", tlsutil.ClientCert},
+	}
+	for _, c := range certs {
+		certFile, keyFile, err := ca.Issue(c.name, c.usage, extra...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "issue %s: %v\n", c.name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%-20s %s, %s\n", c.name, certFile, keyFile)
+	}
+
+	fmt.Println("\nThe CA key is stored unencrypted. Trust this CA in demo clients only.")
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/tlsutil"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// Policy controls how an agent treats a SAGE security feature
+type Policy string
+
+const (
+	PolicyOff      Policy = "off"      // never checked
+	PolicyOptional Policy = "optional" // verified if present
+	PolicyRequired Policy = "required" // rejected if absent
+)
+
+// ParsePolicy parses a policy flag value
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyOff, PolicyOptional, PolicyRequired:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid policy %q (valid: off, optional, required)", s)
+}
+
+// AgentConfig holds the settings of a single stand-in agent
+type AgentConfig struct {
+	Name            string
+	SignaturePolicy Policy
+	HPKEPolicy      Policy
+	SignResponses   bool
+	CheckSender     bool    // reject messages whose "from" is not the agent bound to the signing keyid
+	MaxAmount       float64 // payment business-rule limit, 0 disables the check
+
+	// Signature freshness (see sage.Verifier)
+	MaxSignatureAge time.Duration // 0 only enforces expires
+	ClockSkew       time.Duration // tolerated signer clock difference
+	ClockOffset     time.Duration // simulated drift of the agent clock
+}
+
+// Outcome is the business decision of an agent for one message
+type Outcome struct {
+	Status   int
+	Accepted bool
+	Reason   string
+	Content  string
+	Metadata map[string]interface{}
+}
+
+// Behavior implements the business logic of a stand-in agent
+type Behavior func(cfg *AgentConfig, msg *types.AgentMessage, raw map[string]interface{}) *Outcome
+
+// Agent is an HTTP handler that behaves like a SAGE-enabled agent
+type Agent struct {
+	config   *AgentConfig
+	keys     *sage.KeyPair
+	verifier *sage.Verifier
+	behavior Behavior
+}
+
+// NewAgent creates a stand-in agent for the configured name
+func NewAgent(cfg *AgentConfig) (*Agent, error) {
+	behavior, ok := behaviors[cfg.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown agent %q (valid: payment, medical, planning)", cfg.Name)
+	}
+
+	verifier := &sage.Verifier{MaxAge: cfg.MaxSignatureAge, ClockSkew: cfg.ClockSkew}
+	if cfg.ClockOffset != 0 {
+		verifier.Now = func() time.Time { return time.Now().Add(cfg.ClockOffset) }
+	}
+
+	return &Agent{
+		config:   cfg,
+		keys:     sage.DemoKeyPair(cfg.Name),
+		verifier: verifier,
+		behavior: behavior,
+	}, nil
+}
+
+// ServeHTTP verifies the incoming message and applies the agent behaviour
+func (a *Agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/health" {
+		a.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":           "healthy",
+			"agent":            a.config.Name,
+			"signature_policy": a.config.SignaturePolicy,
+			"hpke_policy":      a.config.HPKEPolicy,
+		})
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		a.reject(w, nil, &Outcome{Status: http.StatusBadRequest, Reason: "failed to read body"}, nil)
+		return
+	}
+
+	// The digest of what actually arrived lets clients detect in-transit tampering
+	verification := map[string]interface{}{
+		"received_digest": sage.ContentDigest(body),
+		"transport":       "plaintext",
+	}
+	// TLS only protects the hop from the gateway, not the message
+	if r.TLS != nil {
+		verification["transport"] = tlsutil.Describe(r.TLS)
+	}
+
+	// Step 1: RFC 9421 signature
+	if outcome := a.checkSignature(r, body, verification); outcome != nil {
+		a.reject(w, nil, outcome, verification)
+		return
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		a.reject(w, nil, &Outcome{Status: http.StatusBadRequest, Reason: "body is not a JSON object"}, verification)
+		return
+	}
+
+	// Step 2: HPKE payload
+	msg, raw, outcome := a.openPayload(body, raw, verification)
+	if outcome != nil {
+		a.reject(w, msg, outcome, verification)
+		return
+	}
+
+	// Step 3: the signing key must belong to the claimed sender (DID binding)
+	if outcome := a.checkSender(msg, verification); outcome != nil {
+		a.reject(w, msg, outcome, verification)
+		return
+	}
+
+	// Step 4: business logic
+	outcome = a.behavior(a.config, msg, raw)
+	if !outcome.Accepted {
+		a.reject(w, msg, outcome, verification)
+		return
+	}
+
+	logger.Info("[%s] ACCEPTED message %s from %s: %s", a.config.Name, msg.ID, msg.From, outcome.Content)
+	a.respond(w, msg, outcome, verification)
+}
+
+// checkSignature applies the signature policy and returns a rejection, if any
+func (a *Agent) checkSignature(r *http.Request, body []byte, verification map[string]interface{}) *Outcome {
+	if a.config.SignaturePolicy == PolicyOff {
+		verification["signature"] = "not_checked"
+		return nil
+	}
+
+	params, err := a.verifier.VerifyRequest(r, body)
+	if params != nil && !params.Created.IsZero() {
+		verification["signature_age"] = a.clock().Sub(params.Created).Truncate(time.Second).Seconds()
+	}
+	switch {
+	case err == nil:
+		verification["signature"] = "verified"
+		verification["keyid"] = params.KeyID
+		// Anything not listed here can change without breaking the signature
+		covered := make([]string, len(params.Components))
+		for i, c := range params.Components {
+			covered[i] = c.Name
+			if c.Param != "" {
+				covered[i] += ";name=" + c.Param
+			}
+		}
+		verification["covered"] = covered
+		return nil
+
+	case errors.Is(err, sage.ErrNoSignature):
+		verification["signature"] = "absent"
+		if a.config.SignaturePolicy == PolicyRequired {
+			return &Outcome{Status: http.StatusUnauthorized, Reason: "signature required but message is unsigned"}
+		}
+		return nil
+
+	case errors.Is(err, sage.ErrStaleSignature):
+		verification["signature"] = "stale"
+		return &Outcome{Status: http.StatusUnauthorized, Reason: "signature rejected: " + err.Error()}
+
+	default:
+		verification["signature"] = "invalid"
+		return &Outcome{Status: http.StatusUnauthorized, Reason: "signature verification failed: " + err.Error()}
+	}
+}
+
+// checkSender compares the claimed sender with the agent the verified keyid
+// is bound to. Unsigned messages and messages without "from" are not checked.
+func (a *Agent) checkSender(msg *types.AgentMessage, verification map[string]interface{}) *Outcome {
+	keyID, signed := verification["keyid"].(string)
+	if !a.config.CheckSender || !signed || msg.From == "" {
+		return nil
+	}
+
+	signer, ok := sage.AgentFromKeyID(keyID)
+	if !ok || signer != msg.From {
+		verification["sender"] = "mismatch"
+		logger.Warn("[%s] 🪪 Message %s claims from=%s but is signed by %s", a.config.Name, msg.ID, msg.From, keyID)
+		return &Outcome{Status: http.StatusForbidden, Reason: fmt.Sprintf("sender mismatch: message claims from %q but is signed by %s", msg.From, keyID)}
+	}
+	verification["sender"] = "bound"
+	return nil
+}
+
+// clock returns the current time of the agent, including any simulated drift
+func (a *Agent) clock() time.Time {
+	return time.Now().Add(a.config.ClockOffset)
+}
+
+// openPayload decrypts HPKE envelopes according to the HPKE policy
+func (a *Agent) openPayload(body []byte, raw map[string]interface{}, verification map[string]interface{}) (*types.AgentMessage, map[string]interface{}, *Outcome) {
+	msgType, _ := raw["type"].(string)
+	_, hasPayload := raw["encryptedPayload"]
+	encrypted := hasPayload || msgType == sage.SecureMessageType || msgType == "encrypted"
+
+	if !encrypted {
+		var msg types.AgentMessage
+		json.Unmarshal(body, &msg)
+
+		verification["hpke"] = "plaintext"
+		if a.config.HPKEPolicy == PolicyRequired {
+			return &msg, raw, &Outcome{Status: http.StatusBadRequest, Reason: "plaintext rejected: HPKE encryption required"}
+		}
+		return &msg, raw, nil
+	}
+
+	var sm types.SecureMessage
+	if err := json.Unmarshal(body, &sm); err != nil {
+		verification["hpke"] = "invalid"
+		return nil, raw, &Outcome{Status: http.StatusBadRequest, Reason: "malformed secure message"}
+	}
+	envelope := &types.AgentMessage{ID: sm.ID, ContextID: sm.ContextID, From: sm.From, To: sm.To}
+
+	if a.config.HPKEPolicy == PolicyOff {
+		verification["hpke"] = "unsupported"
+		return envelope, raw, &Outcome{Status: http.StatusUnsupportedMediaType, Reason: "encrypted payloads are not supported by this agent"}
+	}
+
+	msg, err := sage.DecryptSecureMessage(&sm, a.keys)
+	if err != nil {
+		verification["hpke"] = "decrypt_failed"
+		return envelope, raw, &Outcome{Status: http.StatusBadRequest, Reason: err.Error()}
+	}
+
+	verification["hpke"] = "decrypted"
+
+	// Business logic works on the decrypted message
+	plaintext, _ := json.Marshal(msg)
+	raw = map[string]interface{}{}
+	json.Unmarshal(plaintext, &raw)
+	return msg, raw, nil
+}
+
+// reject logs and writes a rejection response
+func (a *Agent) reject(w http.ResponseWriter, msg *types.AgentMessage, outcome *Outcome, verification map[string]interface{}) {
+	if msg == nil {
+		msg = &types.AgentMessage{}
+	}
+	logger.Warn("[%s] REJECTED message %s from %s: %s", a.config.Name, msg.ID, msg.From, outcome.Reason)
+
+	outcome.Accepted = false
+	if outcome.Content == "" {
+		outcome.Content = "Request rejected: " + outcome.Reason
+	}
+	a.respond(w, msg, outcome, verification)
+}
+
+// respond writes the agent reply as an AgentMessage
+func (a *Agent) respond(w http.ResponseWriter, msg *types.AgentMessage, outcome *Outcome, verification map[string]interface{}) {
+	status := "accepted"
+	if !outcome.Accepted {
+		status = "rejected"
+	}
+
+	metadata := map[string]interface{}{
+		"status":       status,
+		"verification": verification,
+	}
+	if outcome.Reason != "" {
+		metadata["reason"] = outcome.Reason
+	}
+	for k, v := range outcome.Metadata {
+		metadata[k] = v
+	}
+
+	reply := types.AgentMessage{
+		ID:        "resp-" + msg.ID,
+		ContextID: msg.ContextID,
+		From:      a.config.Name,
+		To:        msg.From,
+		Content:   outcome.Content,
+		Timestamp: time.Now().UTC(),
+		Type:      "response",
+		Metadata:  metadata,
+	}
+
+	code := outcome.Status
+	if code == 0 {
+		code = http.StatusOK
+	}
+	a.writeJSON(w, code, reply)
+}
+
+// writeJSON writes a JSON response, signing it when configured
+func (a *Agent) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if a.config.SignResponses {
+		if _, err := sage.SignResponse(status, w.Header(), body, a.keys, nil); err != nil {
+			logger.Error("[%s] Failed to sign response: %v", a.config.Name, err)
+		}
+	}
+
+	w.WriteHeader(status)
+	w.Write(body)
+}
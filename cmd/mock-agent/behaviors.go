@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// behaviors maps agent names to their business logic
+var behaviors = map[string]Behavior{
+	"payment":  paymentBehavior,
+	"medical":  medicalBehavior,
+	"planning": planningBehavior,
+}
+
+// defaultPorts matches the default AGENT_URLS of the gateway
+var defaultPorts = map[string]int{
+	"planning": 19081,
+	"medical":  19082,
+	"payment":  19083,
+}
+
+// paymentBehavior approves payments that pass a naive business-rule check
+func paymentBehavior(cfg *AgentConfig, msg *types.AgentMessage, raw map[string]interface{}) *Outcome {
+	amount, currency := extractAmount(raw)
+	recipient := extractString(raw, "metadata.recipient", "metadata.to", "recipient", "parameters.recipient")
+
+	if amount <= 0 {
+		return &Outcome{Status: http.StatusUnprocessableEntity, Reason: "payment amount missing or not positive"}
+	}
+	if recipient == "" {
+		return &Outcome{Status: http.StatusUnprocessableEntity, Reason: "payment recipient missing"}
+	}
+	if cfg.MaxAmount > 0 && amount > cfg.MaxAmount {
+		return &Outcome{
+			Status: http.StatusUnprocessableEntity,
+			Reason: fmt.Sprintf("amount %.2f exceeds limit %.2f", amount, cfg.MaxAmount),
+		}
+	}
+
+	return &Outcome{
+		Status:   http.StatusOK,
+		Accepted: true,
+		Content:  fmt.Sprintf("Payment of %s to %s approved", formatAmount(amount, currency), recipient),
+		Metadata: map[string]interface{}{
+			"transactionId": "tx-" + msg.ID,
+			"amount":        amount,
+			"currency":      currency,
+			"recipient":     recipient,
+		},
+	}
+}
+
+// medicalBehavior acknowledges medical information requests
+func medicalBehavior(cfg *AgentConfig, msg *types.AgentMessage, raw map[string]interface{}) *Outcome {
+	if strings.TrimSpace(msg.Content) == "" {
+		return &Outcome{Status: http.StatusUnprocessableEntity, Reason: "empty medical query"}
+	}
+
+	return &Outcome{
+		Status:   http.StatusOK,
+		Accepted: true,
+		Content:  "Medical information request received: " + msg.Content,
+		Metadata: map[string]interface{}{
+			"disclaimer": "Demo response - not medical advice",
+		},
+	}
+}
+
+// planningBehavior returns a canned plan for the requested task
+func planningBehavior(cfg *AgentConfig, msg *types.AgentMessage, raw map[string]interface{}) *Outcome {
+	task := strings.TrimSpace(msg.Content)
+	if task == "" {
+		task = "unspecified task"
+	}
+
+	return &Outcome{
+		Status:   http.StatusOK,
+		Accepted: true,
+		Content:  "Plan created for: " + task,
+		Metadata: map[string]interface{}{
+			"steps": []string{"gather requirements", "book resources", "confirm with user"},
+		},
+	}
+}
+
+// extractAmount finds the payment amount in the supported message formats
+func extractAmount(raw map[string]interface{}) (float64, string) {
+	currency := extractString(raw, "metadata.currency", "currency", "parameters.currency")
+
+	for _, path := range []string{"metadata.amountKRW", "metadata.amount", "amount", "parameters.amount"} {
+		if amount, ok := toFloat(lookup(raw, path)); ok {
+			if currency == "" && strings.HasSuffix(path, "KRW") {
+				currency = "KRW"
+			}
+			return amount, currency
+		}
+	}
+	return 0, currency
+}
+
+// formatAmount renders an amount with its currency, if known
+func formatAmount(amount float64, currency string) string {
+	if currency == "" {
+		return fmt.Sprintf("%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", amount, currency)
+}
+
+// extractString returns the first non-empty string at the given paths
+func extractString(raw map[string]interface{}, paths ...string) string {
+	for _, path := range paths {
+		if s, ok := lookup(raw, path).(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// lookup resolves a dotted path such as "metadata.amount"
+func lookup(raw map[string]interface{}, path string) interface{} {
+	var current interface{} = raw
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
+
+// toFloat converts JSON numbers to float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,154 @@
+// Command mock-agent runs payment, medical and planning agent stand-ins that
+// speak the sage-multi-agent AgentMessage format. It verifies RFC 9421
+// signatures and HPKE payloads with the demo keys from the sage package, so
+// the SAGE ON vs OFF outcome can be shown without any external services.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/tlsutil"
+)
+
+func main() {
+	agentName := flag.String("agent", "all", "agent to run: payment, medical, planning or all")
+	port := flag.Int("port", 0, "listen port (single agent only, defaults to the gateway's default AGENT_URLS port)")
+	signaturePolicy := flag.String("signature-policy", "optional", "RFC 9421 signature policy: off, optional, required")
+	hpkePolicy := flag.String("hpke-policy", "optional", "HPKE payload policy: off, optional, required")
+	signResponses := flag.Bool("sign", false, "sign responses with the agent's demo key")
+	checkSender := flag.Bool("check-sender", true, "reject signed messages whose \"from\" is not the agent bound to the keyid (DID)")
+	maxAmount := flag.Float64("max-amount", 1000000, "payment agent business-rule limit (0 disables)")
+	maxSignatureAge := flag.Duration("max-signature-age", 0, "reject signatures older than this relative to created (0 = only enforce expires)")
+	clockSkew := flag.Duration("clock-skew", 0, "tolerated clock difference for created/expires")
+	clockOffset := flag.Duration("clock-offset", 0, "simulated drift of the agent clock (e.g. -30s)")
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn, error")
+	useTLS := flag.Bool("tls", false, "serve HTTPS with a certificate issued from the demo CA")
+	tlsDir := flag.String("tls-dir", "certs", "demo CA directory (generated on first run)")
+	clientAuth := flag.String("client-auth", "none", "client certificates signed by the demo CA: none, optional, require (mTLS)")
+	flag.Parse()
+
+	logger.SetLogLevel(*logLevel)
+
+	sigPolicy, err := ParsePolicy(*signaturePolicy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-signature-policy: %v\n", err)
+		os.Exit(2)
+	}
+	encPolicy, err := ParsePolicy(*hpkePolicy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-hpke-policy: %v\n", err)
+		os.Exit(2)
+	}
+
+	names := []string{*agentName}
+	if *agentName == "all" {
+		names = names[:0]
+		for name := range behaviors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if *port != 0 {
+			fmt.Fprintln(os.Stderr, "-port can only be used with a single agent")
+			os.Exit(2)
+		}
+	}
+
+	var tlsConfig *tls.Config
+	scheme := "http"
+	if *useTLS {
+		if tlsConfig, err = demoTLS(*tlsDir, *clientAuth); err != nil {
+			fmt.Fprintf(os.Stderr, "-tls: %v\n", err)
+			os.Exit(2)
+		}
+		scheme = "https"
+	}
+
+	var servers []*http.Server
+	for _, name := range names {
+		agent, err := NewAgent(&AgentConfig{
+			Name:            name,
+			SignaturePolicy: sigPolicy,
+			HPKEPolicy:      encPolicy,
+			SignResponses:   *signResponses,
+			CheckSender:     *checkSender,
+			MaxAmount:       *maxAmount,
+			MaxSignatureAge: *maxSignatureAge,
+			ClockSkew:       *clockSkew,
+			ClockOffset:     *clockOffset,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+
+		listenPort := *port
+		if listenPort == 0 {
+			listenPort = defaultPorts[name]
+		}
+
+		server := &http.Server{
+			Addr:      fmt.Sprintf(":%d", listenPort),
+			Handler:   agent,
+			TLSConfig: tlsConfig,
+		}
+		servers = append(servers, server)
+
+		logger.Info("Mock %s agent listening on %s://localhost:%d (signature: %s, hpke: %s, signed responses: %v)",
+			name, scheme, listenPort, sigPolicy, encPolicy, *signResponses)
+		if *maxSignatureAge > 0 || *clockSkew != 0 || *clockOffset != 0 {
+			logger.Info("Mock %s agent freshness: max signature age %s, clock skew %s, clock offset %s",
+				name, *maxSignatureAge, *clockSkew, *clockOffset)
+		}
+
+		go func() {
+			var err error
+			if tlsConfig != nil {
+				err = server.ListenAndServeTLS("", "")
+			} else {
+				err = server.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				logger.Error("Mock %s agent failed: %v", name, err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	<-sigChan
+
+	logger.Info("Shutting down mock agents...")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, server := range servers {
+		server.Shutdown(ctx)
+	}
+}
+
+// demoTLS serves the "agent" certificate of the demo CA and, unless
+// clientAuth is none, verifies client certificates against the same CA
+func demoTLS(dir, clientAuth string) (*tls.Config, error) {
+	ca, created, err := tlsutil.LoadOrCreateCA(dir)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		logger.Warn("Generated demo CA %s - trust it in demo clients only", ca.CertFile())
+	}
+	certFile, keyFile, err := ca.Issue("agent", tlsutil.ServerCert)
+	if err != nil {
+		return nil, err
+	}
+	return tlsutil.ServerConfig(certFile, keyFile, ca.CertFile(), clientAuth)
+}
@@ -0,0 +1,96 @@
+// Command replay feeds a HAR captured by the gateway (CAPTURE_FILE or
+// /api/capture) back through the interceptor/modifier pipeline against local
+// agents, and reports any exchange whose forwarded body, tampered fields or
+// upstream outcome no longer match the recording. A capture of a live demo
+// thereby becomes a reproducible regression fixture.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/capture"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+)
+
+func main() {
+	harPath := flag.String("har", "", "HAR file captured by the gateway (required)")
+	output := flag.String("o", "", "write the replayed exchanges to this HAR file")
+	agents := flag.String("agents", "", `agent URLs as JSON, e.g. {"payment":"http://localhost:19083"} (default: AGENT_URLS)`)
+	target := flag.String("target", "", "send every request to this URL instead of routing by agent")
+	attack := flag.String("attack", "", "attack type to apply (default: as recorded in the HAR)")
+	logLevel := flag.String("log-level", "warn", "gateway log level: debug, info, warn, error")
+	verbose := flag.Bool("v", false, "print every exchange, not only mismatches")
+	flag.Parse()
+
+	if *harPath == "" && flag.NArg() > 0 {
+		*harPath = flag.Arg(0)
+	}
+	if *harPath == "" {
+		fmt.Fprintln(os.Stderr, "usage: replay -har capture.har [-target URL | -agents JSON] [-attack TYPE] [-o replayed.har]")
+		os.Exit(2)
+	}
+
+	har, err := capture.Load(*harPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	opts := &Options{Target: *target, Attack: *attack}
+	if *agents != "" {
+		if err := json.Unmarshal([]byte(*agents), &opts.AgentURLs); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -agents JSON: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	logger.SetLogLevel(*logLevel)
+	replayer := NewReplayer(config.LoadConfig(), opts)
+	results := replayer.Run(har)
+
+	failed := printResults(os.Stdout, results, *verbose)
+
+	if *output != "" {
+		if err := replayer.HAR().WriteFile(*output); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write %s: %v\n", *output, err)
+			os.Exit(1)
+		}
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// printResults writes per-exchange results and a summary, returning the number of mismatches
+func printResults(w io.Writer, results []*Result, verbose bool) int {
+	failed := 0
+	for _, r := range results {
+		if r.OK() && !verbose {
+			continue
+		}
+		status := "OK  "
+		if !r.OK() {
+			status = "DIFF"
+			failed++
+		}
+		fmt.Fprintf(w, "[%s] #%d %s %s\n", status, r.Index, r.Recorded.Request.Method, r.Recorded.Request.URL)
+		for _, d := range r.Diffs {
+			fmt.Fprintf(w, "       - %s\n", d)
+		}
+	}
+
+	fmt.Fprintln(w, "╔════════════════════════════════════════════════════════════╗")
+	fmt.Fprintln(w, "║   HAR Replay - Summary                                     ║")
+	fmt.Fprintln(w, "╠════════════════════════════════════════════════════════════╣")
+	fmt.Fprintf(w, "║ Exchanges:           %-37d ║\n", len(results))
+	fmt.Fprintf(w, "║ Matched:             %-37d ║\n", len(results)-failed)
+	fmt.Fprintf(w, "║ Mismatched:          %-37d ║\n", failed)
+	fmt.Fprintln(w, "╚════════════════════════════════════════════════════════════╝")
+	return failed
+}
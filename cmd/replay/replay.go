@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"sort"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/capture"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/handlers"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/report"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// Options configures a replay run
+type Options struct {
+	AgentURLs map[string]string // replaces the gateway's AGENT_URLS when set
+	Target    string            // sends every request to this URL, ignoring routing
+	Attack    string            // attack type to apply ("" = as recorded in the HAR)
+}
+
+// Result compares one recorded exchange with its replay
+type Result struct {
+	Index    int
+	Recorded *capture.Entry
+	Replayed *capture.Entry
+	Diffs    []string
+}
+
+// OK reports whether the replay matched the recording
+func (r *Result) OK() bool {
+	return len(r.Diffs) == 0
+}
+
+// Replayer feeds recorded requests through the gateway pipeline in-process
+type Replayer struct {
+	base     *config.Config
+	opts     *Options
+	handlers map[string]*handlers.ProxyHandler
+	replayed []*capture.Entry
+}
+
+// NewReplayer creates a replayer using base as the gateway configuration
+func NewReplayer(base *config.Config, opts *Options) *Replayer {
+	return &Replayer{
+		base:     base,
+		opts:     opts,
+		handlers: make(map[string]*handlers.ProxyHandler),
+	}
+}
+
+// Run replays every entry of a HAR in order
+func (rp *Replayer) Run(har *capture.HAR) []*Result {
+	results := make([]*Result, 0, len(har.Log.Entries))
+	for i, entry := range har.Log.Entries {
+		result := rp.Replay(entry)
+		result.Index = i
+		results = append(results, result)
+	}
+	return results
+}
+
+// Replay sends one recorded request through the interceptor/modifier pipeline
+// and compares the forwarded body and upstream outcome with the recording
+func (rp *Replayer) Replay(entry *capture.Entry) *Result {
+	result := &Result{Recorded: entry}
+
+	body, err := entry.Request.Body()
+	if err != nil {
+		result.Diffs = append(result.Diffs, fmt.Sprintf("cannot decode recorded body: %v", err))
+		return result
+	}
+
+	req := httptest.NewRequest(entry.Request.Method, entry.Request.URL, bytes.NewReader(body))
+	for name, values := range entry.Request.Header() {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	handler := rp.handlerFor(entry.Gateway)
+	before := handler.Capture().Last()
+	handler.HandleRequest(httptest.NewRecorder(), req)
+
+	replayed := handler.Capture().Last()
+	if replayed == nil || replayed == before {
+		result.Diffs = append(result.Diffs, "request was not forwarded by the gateway")
+		return result
+	}
+	result.Replayed = replayed
+	rp.replayed = append(rp.replayed, replayed)
+
+	result.Diffs = compare(entry, replayed)
+	return result
+}
+
+// HAR returns the replayed exchanges as a HAR document
+func (rp *Replayer) HAR() *capture.HAR {
+	rec := capture.NewRecorder(len(rp.replayed))
+	for _, entry := range rp.replayed {
+		rec.Add(entry)
+	}
+	return rec.HAR()
+}
+
+// handlerFor returns a proxy handler configured like the recording gateway.
+// Handlers are cached per attack configuration.
+func (rp *Replayer) handlerFor(state *capture.GatewayState) *handlers.ProxyHandler {
+	cfg := *rp.base
+	// Replay the recorded activation decision instead of drawing a new one
+	cfg.AttackProbability, cfg.AttackRateLimit, cfg.AttackSchedule = nil, 0, ""
+	if state != nil {
+		cfg.AttackEnabled = state.AttackEnabled && (state.Activation == nil || state.Activation.Fired)
+		cfg.AttackType = types.AttackType(state.AttackType)
+	}
+	if rp.opts.Attack != "" {
+		cfg.AttackType = types.AttackType(rp.opts.Attack)
+		cfg.AttackEnabled = cfg.AttackType != types.AttackTypeNone
+	}
+	if rp.opts.AgentURLs != nil {
+		cfg.AgentURLs = rp.opts.AgentURLs
+	}
+	if rp.opts.Target != "" {
+		cfg.TargetAgentURL = rp.opts.Target
+		cfg.AgentURLs = nil
+		cfg.Routes = nil
+		cfg.RouteDefault = ""
+	}
+
+	key := fmt.Sprintf("%t/%s", cfg.AttackEnabled, cfg.AttackType)
+	handler, ok := rp.handlers[key]
+	if !ok {
+		handler = handlers.NewProxyHandler(&cfg)
+		rp.handlers[key] = handler
+	}
+	return handler
+}
+
+// compare lists the differences between a recorded and a replayed exchange
+func compare(recorded, replayed *capture.Entry) []string {
+	var diffs []string
+
+	if recorded.ModifiedRequest != nil && replayed.ModifiedRequest != nil {
+		want, _ := recorded.ModifiedRequest.Body()
+		got, _ := replayed.ModifiedRequest.Body()
+		if !sameBody(want, got) {
+			diffs = append(diffs, fmt.Sprintf("forwarded body differs: recorded %s, replayed %s", want, got))
+		}
+	}
+
+	if want, got := changedFields(recorded.Attack), changedFields(replayed.Attack); want != got {
+		diffs = append(diffs, fmt.Sprintf("tampered fields differ: recorded [%s], replayed [%s]", want, got))
+	}
+
+	if recorded.Response.Status != replayed.Response.Status {
+		diffs = append(diffs, fmt.Sprintf("upstream status differs: recorded %d, replayed %d",
+			recorded.Response.Status, replayed.Response.Status))
+	}
+
+	if want, got := outcome(recorded.Response), outcome(replayed.Response); want != got {
+		diffs = append(diffs, fmt.Sprintf("outcome differs: recorded %s, replayed %s", want, got))
+	}
+
+	return diffs
+}
+
+// sameBody compares JSON bodies semantically and anything else byte for byte
+func sameBody(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) == nil && json.Unmarshal(b, &vb) == nil {
+		ja, _ := json.Marshal(va)
+		jb, _ := json.Marshal(vb)
+		return bytes.Equal(ja, jb)
+	}
+	return bytes.Equal(a, b)
+}
+
+func changedFields(attackLog *types.AttackLog) string {
+	if attackLog == nil {
+		return ""
+	}
+	fields := make([]string, 0, len(attackLog.Changes))
+	for _, c := range attackLog.Changes {
+		fields = append(fields, c.Field)
+	}
+	sort.Strings(fields)
+	return fmt.Sprint(fields)
+}
+
+func outcome(resp *capture.Response) string {
+	body, _ := resp.Body()
+	return report.ClassifyOutcome(resp.Status, body)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/capture"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/handlers"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// paymentAgent accepts payments up to limit and rejects anything larger
+func paymentAgent(limit float64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&msg)
+
+		status, replyStatus := http.StatusOK, "accepted"
+		if amount, _ := msg["amount"].(float64); amount > limit {
+			status, replyStatus = http.StatusUnprocessableEntity, "rejected"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"metadata": map[string]interface{}{"status": replyStatus}})
+	}))
+}
+
+// recordHAR proxies one payment through a gateway and returns the capture
+func recordHAR(t *testing.T, target string) *capture.HAR {
+	t.Helper()
+	gateway := handlers.NewProxyHandler(&config.Config{
+		AttackEnabled:   true,
+		AttackType:      types.AttackTypePriceManipulation,
+		TargetAgentURL:  target,
+		PriceMultiplier: 100.0,
+	})
+
+	req := httptest.NewRequest("POST", "/payment", bytes.NewBufferString(`{"amount":100,"recipient":"0xabc"}`))
+	req.Header.Set("Content-Type", "application/json")
+	gateway.HandleRequest(httptest.NewRecorder(), req)
+
+	har := gateway.Capture().HAR()
+	if len(har.Log.Entries) != 1 {
+		t.Fatalf("Expected 1 captured exchange, got %d", len(har.Log.Entries))
+	}
+	return har
+}
+
+func TestReplayer_Run_Matches(t *testing.T) {
+	agent := paymentAgent(1000000)
+	defer agent.Close()
+
+	har := recordHAR(t, agent.URL)
+	if har.Log.Entries[0].Attack == nil || har.Log.Entries[0].Gateway.AttackType != "price_manipulation" {
+		t.Fatalf("Capture should include the attack: %+v", har.Log.Entries[0])
+	}
+
+	replayer := NewReplayer(&config.Config{PriceMultiplier: 100.0}, &Options{Target: agent.URL})
+	results := replayer.Run(har)
+
+	if len(results) != 1 || !results[0].OK() {
+		t.Fatalf("Expected matching replay, got %+v", results[0].Diffs)
+	}
+	if len(replayer.HAR().Log.Entries) != 1 {
+		t.Error("Replayed HAR should contain the exchange")
+	}
+}
+
+func TestReplayer_Run_DetectsRegression(t *testing.T) {
+	recordedAgent := paymentAgent(1000000)
+	defer recordedAgent.Close()
+	har := recordHAR(t, recordedAgent.URL)
+
+	// The replay agent now enforces a limit that rejects the tampered amount
+	strictAgent := paymentAgent(1000)
+	defer strictAgent.Close()
+
+	replayer := NewReplayer(&config.Config{PriceMultiplier: 100.0}, &Options{Target: strictAgent.URL})
+	results := replayer.Run(har)
+
+	if results[0].OK() {
+		t.Fatal("Replay should report the changed outcome")
+	}
+	if len(results[0].Diffs) != 2 {
+		t.Errorf("Expected status and outcome diffs, got %v", results[0].Diffs)
+	}
+}
+
+func TestReplayer_Run_AttackOverride(t *testing.T) {
+	agent := paymentAgent(1000000)
+	defer agent.Close()
+	har := recordHAR(t, agent.URL)
+
+	replayer := NewReplayer(&config.Config{PriceMultiplier: 100.0}, &Options{Target: agent.URL, Attack: "none"})
+	results := replayer.Run(har)
+
+	if results[0].OK() {
+		t.Fatal("Replay without the attack should differ from the tampered recording")
+	}
+	forwarded, _ := results[0].Replayed.ModifiedRequest.Body()
+	if !sameBody(forwarded, []byte(`{"amount":100,"recipient":"0xabc"}`)) {
+		t.Errorf("Expected the original body to be forwarded, got %s", forwarded)
+	}
+}
+
+func TestReplayer_Run_RecordedActivation(t *testing.T) {
+	agent := paymentAgent(1000000)
+	defer agent.Close()
+	har := recordHAR(t, agent.URL)
+
+	// Recorded while the attack did not fire: the replay must not draw again
+	entry := har.Log.Entries[0]
+	entry.Gateway.Activation = &types.Activation{Fired: false, Reason: "roll 0.9000 >= probability 0.10", Seed: 1, Sequence: 1}
+	entry.Attack = nil
+	entry.ModifiedRequest = entry.Request
+
+	replayer := NewReplayer(&config.Config{PriceMultiplier: 100.0, AttackProbability: new(0.5)}, &Options{Target: agent.URL})
+	results := replayer.Run(har)
+
+	forwarded, _ := results[0].Replayed.ModifiedRequest.Body()
+	if !sameBody(forwarded, []byte(`{"amount":100,"recipient":"0xabc"}`)) {
+		t.Errorf("Expected the original body to be forwarded, got %s", forwarded)
+	}
+}
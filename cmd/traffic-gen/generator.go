@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/tlsutil"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// Kind selects the payload format produced by the generator
+type Kind string
+
+const (
+	KindAgent   Kind = "agent"   // AgentMessage to payment/medical/planning
+	KindPayment Kind = "payment" // legacy PaymentMessage to /payment
+	KindOrder   Kind = "order"   // legacy OrderMessage to /order
+	KindMixed   Kind = "mixed"   // round-robin over all kinds
+)
+
+// Options configures a generator run
+type Options struct {
+	GatewayURL  string
+	Kind        Kind
+	Requests    int
+	Concurrency int
+	Rate        float64 // requests per second, 0 means unlimited
+	Sign        bool
+	Encrypt     bool
+	From        string
+	Components  []string      // signature coverage, empty means sage defaults
+	Expires     time.Duration // signature lifetime, 0 means no expires parameter
+	Timeout     time.Duration
+	Verbose     bool
+
+	// HTTPS gateways: extra CA bundle and mTLS client certificate
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// Generator produces signed agent traffic and classifies the outcomes
+type Generator struct {
+	opts   *Options
+	client *http.Client
+	keys   *sage.KeyPair
+}
+
+// NewGenerator creates a traffic generator
+func NewGenerator(opts *Options) (*Generator, error) {
+	switch opts.Kind {
+	case KindAgent, KindPayment, KindOrder, KindMixed:
+	default:
+		return nil, fmt.Errorf("invalid kind %q (valid: agent, payment, order, mixed)", opts.Kind)
+	}
+	if opts.Encrypt && opts.Kind != KindAgent {
+		return nil, fmt.Errorf("HPKE encryption requires kind %q (SecureMessage wraps an AgentMessage)", KindAgent)
+	}
+	if opts.Requests <= 0 {
+		return nil, fmt.Errorf("requests must be positive, got %d", opts.Requests)
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.From == "" {
+		opts.From = "root"
+	}
+
+	client := &http.Client{Timeout: opts.Timeout}
+	if opts.CAFile != "" || opts.CertFile != "" {
+		tlsConfig, err := tlsutil.ClientConfig(opts.CAFile, opts.CertFile, opts.KeyFile, false)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
+	return &Generator{
+		opts:   opts,
+		client: client,
+		keys:   sage.DemoKeyPair(opts.From),
+	}, nil
+}
+
+// Request is one generated request before it is sent
+type Request struct {
+	Seq  int
+	Kind Kind
+	Path string
+	To   string
+	Body []byte
+}
+
+// Build creates the payload for sequence number seq
+func (g *Generator) Build(seq int) (*Request, error) {
+	kind := g.opts.Kind
+	if kind == KindMixed {
+		kind = []Kind{KindAgent, KindPayment, KindOrder}[seq%3]
+	}
+
+	id := fmt.Sprintf("gen-%06d", seq)
+	now := time.Now().UTC()
+
+	var req *Request
+	var payload interface{}
+
+	switch kind {
+	case KindPayment:
+		req = &Request{Path: "/payment"}
+		payload = types.PaymentMessage{
+			Amount:      float64(100 + seq%10*10),
+			Currency:    "USD",
+			Product:     "Sunglasses",
+			Recipient:   "0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb",
+			Sender:      g.opts.From,
+			Description: "Generated payment " + id,
+			Timestamp:   now.Unix(),
+		}
+
+	case KindOrder:
+		req = &Request{Path: "/order"}
+		payload = types.OrderMessage{
+			OrderID:         id,
+			Product:         "Sunglasses",
+			Quantity:        1 + seq%3,
+			Amount:          float64(100 + seq%10*10),
+			ShippingAddress: "123 Main St, Seoul, Korea",
+			Recipient:       "0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb",
+			Timestamp:       now.Unix(),
+		}
+
+	default:
+		msg := g.agentMessage(seq, id, now)
+		req = &Request{Path: "/", To: msg.To}
+		payload = msg
+		if g.opts.Encrypt {
+			sm, err := sage.EncryptAgentMessage(msg)
+			if err != nil {
+				return nil, err
+			}
+			payload = sm
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Seq = seq
+	req.Kind = kind
+	req.Body = body
+	return req, nil
+}
+
+// agentMessage cycles through the payment, medical and planning agents
+func (g *Generator) agentMessage(seq int, id string, now time.Time) *types.AgentMessage {
+	msg := &types.AgentMessage{
+		ID:        id,
+		ContextID: fmt.Sprintf("ctx-%04d", seq/3),
+		From:      g.opts.From,
+		Timestamp: now,
+		Type:      "request",
+	}
+
+	switch seq % 3 {
+	case 0:
+		msg.To = "payment"
+		msg.Content = "Pay for sunglasses"
+		msg.Metadata = map[string]interface{}{
+			"amount":    float64(100 + seq%10*10),
+			"currency":  "KRW",
+			"recipient": "0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb",
+		}
+	case 1:
+		msg.To = "medical"
+		msg.Content = "What are the side effects of ibuprofen?"
+	default:
+		msg.To = "planning"
+		msg.Content = "Plan a two-day trip to Busan"
+	}
+	return msg
+}
+
+// Result is the classified outcome of one request
+type Result struct {
+	Seq     int
+	Kind    Kind
+	To      string
+	Status  int
+	Outcome Outcome
+	Reason  string
+	Latency time.Duration
+}
+
+// Send signs (if enabled) and sends one request through the gateway
+func (g *Generator) Send(ctx context.Context, r *Request) *Result {
+	result := &Result{Seq: r.Seq, Kind: r.Kind, To: r.To}
+
+	url := strings.TrimRight(g.opts.GatewayURL, "/") + r.Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(r.Body))
+	if err != nil {
+		result.Outcome = OutcomeError
+		result.Reason = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if g.opts.Sign {
+		opts := &sage.SignOptions{Components: g.opts.Components, Expires: g.opts.Expires}
+		if _, err := sage.SignRequest(req, r.Body, g.keys, opts); err != nil {
+			result.Outcome = OutcomeError
+			result.Reason = "sign: " + err.Error()
+			return result
+		}
+	}
+
+	start := time.Now()
+	resp, err := g.client.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Outcome = OutcomeError
+		result.Reason = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	result.Status = resp.StatusCode
+	result.Outcome, result.Reason = Classify(r.Body, resp.StatusCode, respBody)
+	return result
+}
+
+// Run sends all requests honouring the configured rate and concurrency
+func (g *Generator) Run(ctx context.Context) ([]*Result, error) {
+	jobs := make(chan *Request)
+	results := make([]*Result, g.opts.Requests)
+
+	var wg sync.WaitGroup
+	for i := 0; i < g.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				res := g.Send(ctx, r)
+				results[r.Seq] = res
+				if g.opts.Verbose {
+					fmt.Printf("#%04d %-7s %-9s HTTP %-3d %-18s %s\n",
+						res.Seq, res.Kind, res.To, res.Status, res.Outcome, res.Reason)
+				}
+			}
+		}()
+	}
+
+	var ticker *time.Ticker
+	if g.opts.Rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / g.opts.Rate))
+		defer ticker.Stop()
+	}
+
+	var runErr error
+produce:
+	for seq := 0; seq < g.opts.Requests; seq++ {
+		r, err := g.Build(seq)
+		if err != nil {
+			runErr = err
+			break
+		}
+
+		if ticker != nil && seq > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				break produce
+			}
+		}
+
+		select {
+		case jobs <- r:
+		case <-ctx.Done():
+			break produce
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Drop slots that were never sent (cancelled or build error)
+	sent := results[:0]
+	for _, res := range results {
+		if res != nil {
+			sent = append(sent, res)
+		}
+	}
+	return sent, runErr
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// echoAgent replies like the mock agent, optionally seeing a tampered body
+func echoAgent(t *testing.T, tamper bool, verify bool) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if tamper {
+			body = append(body, ' ')
+		}
+
+		status := http.StatusOK
+		replyStatus := "accepted"
+		if verify {
+			if _, err := (&sage.Verifier{}).VerifyRequest(r, body); err != nil {
+				status = http.StatusUnauthorized
+				replyStatus = "rejected"
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"status": replyStatus,
+				"verification": map[string]interface{}{
+					"received_digest": sage.ContentDigest(body),
+				},
+			},
+		})
+	}))
+}
+
+func TestNewGenerator_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *Options
+	}{
+		{"invalid kind", &Options{Kind: "bogus", Requests: 1}},
+		{"hpke with payment", &Options{Kind: KindPayment, Encrypt: true, Requests: 1}},
+		{"no requests", &Options{Kind: KindAgent, Requests: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewGenerator(tt.opts); err == nil {
+				t.Error("NewGenerator() should fail")
+			}
+		})
+	}
+}
+
+func TestGenerator_Build(t *testing.T) {
+	gen, err := NewGenerator(&Options{Kind: KindMixed, Requests: 3})
+	if err != nil {
+		t.Fatalf("NewGenerator() error: %v", err)
+	}
+
+	agentReq, _ := gen.Build(0)
+	var msg types.AgentMessage
+	if err := json.Unmarshal(agentReq.Body, &msg); err != nil || msg.To != "payment" || msg.From != "root" {
+		t.Errorf("Build(0): expected AgentMessage to payment, got %s", agentReq.Body)
+	}
+
+	paymentReq, _ := gen.Build(1)
+	var payment types.PaymentMessage
+	if err := json.Unmarshal(paymentReq.Body, &payment); err != nil || payment.Amount <= 0 || paymentReq.Path != "/payment" {
+		t.Errorf("Build(1): expected PaymentMessage, got %s %s", paymentReq.Path, paymentReq.Body)
+	}
+
+	orderReq, _ := gen.Build(2)
+	var order types.OrderMessage
+	if err := json.Unmarshal(orderReq.Body, &order); err != nil || order.Quantity <= 0 || orderReq.Path != "/order" {
+		t.Errorf("Build(2): expected OrderMessage, got %s %s", orderReq.Path, orderReq.Body)
+	}
+}
+
+func TestGenerator_Build_Encrypted(t *testing.T) {
+	gen, _ := NewGenerator(&Options{Kind: KindAgent, Encrypt: true, Requests: 1})
+
+	req, err := gen.Build(0)
+	if err != nil {
+		t.Fatalf("Build() error: %v", err)
+	}
+
+	var sm types.SecureMessage
+	json.Unmarshal(req.Body, &sm)
+	if sm.Type != sage.SecureMessageType || sm.EncryptedPayload == "" {
+		t.Fatalf("Expected SecureMessage, got %s", req.Body)
+	}
+	if _, err := sage.DecryptSecureMessage(&sm, sage.DemoKeyPair(sm.To)); err != nil {
+		t.Errorf("DecryptSecureMessage() error: %v", err)
+	}
+}
+
+func TestGenerator_Run_Intact(t *testing.T) {
+	agent := echoAgent(t, false, true)
+	defer agent.Close()
+
+	gen, _ := NewGenerator(&Options{
+		GatewayURL:  agent.URL,
+		Kind:        KindMixed,
+		Requests:    6,
+		Concurrency: 3,
+		Sign:        true,
+		Timeout:     5 * time.Second,
+	})
+
+	results, err := gen.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	summary := Summarize(results, time.Second)
+	if summary.Total != 6 || summary.Outcomes[OutcomeAccepted] != 6 {
+		t.Errorf("Expected 6 accepted, got %+v", summary.Outcomes)
+	}
+}
+
+func TestGenerator_Run_TamperedSigned(t *testing.T) {
+	agent := echoAgent(t, true, true)
+	defer agent.Close()
+
+	gen, _ := NewGenerator(&Options{
+		GatewayURL: agent.URL,
+		Kind:       KindAgent,
+		Requests:   2,
+		Sign:       true,
+		Timeout:    5 * time.Second,
+	})
+
+	results, _ := gen.Run(context.Background())
+	summary := Summarize(results, time.Second)
+
+	if summary.Outcomes[OutcomeRejectedTampered] != 2 {
+		t.Errorf("Expected 2 blocked attacks, got %+v", summary.Outcomes)
+	}
+	if summary.Tampered() != 2 || summary.Rejected() != 2 {
+		t.Errorf("Tampered/Rejected mismatch: %d/%d", summary.Tampered(), summary.Rejected())
+	}
+}
+
+func TestGenerator_Run_TamperedUnsigned(t *testing.T) {
+	agent := echoAgent(t, true, false)
+	defer agent.Close()
+
+	gen, _ := NewGenerator(&Options{
+		GatewayURL: agent.URL,
+		Kind:       KindPayment,
+		Requests:   2,
+		Timeout:    5 * time.Second,
+	})
+
+	results, _ := gen.Run(context.Background())
+	summary := Summarize(results, time.Second)
+
+	if summary.Outcomes[OutcomeAcceptedTampered] != 2 {
+		t.Errorf("Expected 2 successful attacks, got %+v", summary.Outcomes)
+	}
+}
+
+func TestGenerator_Run_Rate(t *testing.T) {
+	agent := echoAgent(t, false, false)
+	defer agent.Close()
+
+	gen, _ := NewGenerator(&Options{
+		GatewayURL: agent.URL,
+		Kind:       KindAgent,
+		Requests:   3,
+		Rate:       20,
+		Timeout:    5 * time.Second,
+	})
+
+	start := time.Now()
+	results, _ := gen.Run(context.Background())
+	elapsed := time.Since(start)
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	// Two ticker waits at 50ms each
+	if elapsed < 90*time.Millisecond {
+		t.Errorf("Rate limit not applied: elapsed %v", elapsed)
+	}
+}
+
+func TestGenerator_Run_Unreachable(t *testing.T) {
+	gen, _ := NewGenerator(&Options{
+		GatewayURL: "http://127.0.0.1:1",
+		Kind:       KindAgent,
+		Requests:   1,
+		Timeout:    time.Second,
+	})
+
+	results, _ := gen.Run(context.Background())
+	if len(results) != 1 || results[0].Outcome != OutcomeError {
+		t.Errorf("Expected error outcome, got %+v", results)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	sent := []byte(`{"amount":100}`)
+	intact := sage.ContentDigest(sent)
+	tampered := sage.ContentDigest([]byte(`{"amount":10000}`))
+
+	reply := func(status, digest string) []byte {
+		b, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"status":       status,
+				"verification": map[string]interface{}{"received_digest": digest},
+			},
+		})
+		return b
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		body   []byte
+		want   Outcome
+	}{
+		{"accepted", 200, reply("accepted", intact), OutcomeAccepted},
+		{"accepted tampered", 200, reply("accepted", tampered), OutcomeAcceptedTampered},
+		{"rejected tampered", 401, reply("rejected", tampered), OutcomeRejectedTampered},
+		{"rejected", 422, reply("rejected", intact), OutcomeRejected},
+		{"plain 200", 200, []byte("ok"), OutcomeAccepted},
+		{"bad gateway", 502, []byte("Failed to reach target agent"), OutcomeError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := Classify(sent, tt.status, tt.body); got != tt.want {
+				t.Errorf("Classify(): got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
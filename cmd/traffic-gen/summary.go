@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+)
+
+// Outcome classifies what happened to a generated request
+type Outcome string
+
+const (
+	OutcomeAccepted         Outcome = "accepted"          // delivered intact and accepted
+	OutcomeAcceptedTampered Outcome = "accepted_tampered" // tampered in transit and still accepted (attack succeeded)
+	OutcomeRejectedTampered Outcome = "rejected_tampered" // tampered in transit and rejected (attack blocked)
+	OutcomeRejected         Outcome = "rejected"          // rejected for another reason
+	OutcomeError            Outcome = "error"             // transport or gateway failure
+)
+
+// agentReply is the subset of the mock agent reply used for classification
+type agentReply struct {
+	Metadata struct {
+		Status       string `json:"status"`
+		Reason       string `json:"reason"`
+		Verification struct {
+			ReceivedDigest string `json:"received_digest"`
+		} `json:"verification"`
+	} `json:"metadata"`
+}
+
+// Classify decides the outcome from the sent body and the agent reply.
+// Tampering is detected by comparing the digest of the sent body with the
+// received_digest echoed by the mock agent.
+func Classify(sent []byte, status int, respBody []byte) (Outcome, string) {
+	var reply agentReply
+	json.Unmarshal(respBody, &reply)
+
+	accepted := status >= 200 && status < 300
+	if reply.Metadata.Status != "" {
+		accepted = reply.Metadata.Status == "accepted"
+	} else if status == 502 || status == 503 || status == 504 {
+		return OutcomeError, fmt.Sprintf("gateway returned HTTP %d", status)
+	}
+
+	received := reply.Metadata.Verification.ReceivedDigest
+	tampered := received != "" && received != sage.ContentDigest(sent)
+
+	switch {
+	case accepted && tampered:
+		return OutcomeAcceptedTampered, "message was modified in transit and accepted"
+	case accepted:
+		return OutcomeAccepted, ""
+	case tampered:
+		return OutcomeRejectedTampered, reply.Metadata.Reason
+	default:
+		return OutcomeRejected, reply.Metadata.Reason
+	}
+}
+
+// Summary aggregates the results of a run
+type Summary struct {
+	Total    int                      `json:"total"`
+	Outcomes map[Outcome]int          `json:"outcomes"`
+	ByKind   map[Kind]map[Outcome]int `json:"by_kind"`
+	Latency  LatencyStats             `json:"latency"`
+	Elapsed  time.Duration            `json:"elapsed_ns"`
+}
+
+// LatencyStats summarises request latencies
+type LatencyStats struct {
+	Min time.Duration `json:"min_ns"`
+	Avg time.Duration `json:"avg_ns"`
+	P50 time.Duration `json:"p50_ns"`
+	P95 time.Duration `json:"p95_ns"`
+	Max time.Duration `json:"max_ns"`
+}
+
+// Summarize aggregates results
+func Summarize(results []*Result, elapsed time.Duration) *Summary {
+	s := &Summary{
+		Total:    len(results),
+		Outcomes: make(map[Outcome]int),
+		ByKind:   make(map[Kind]map[Outcome]int),
+		Elapsed:  elapsed,
+	}
+
+	var latencies []time.Duration
+	var sum time.Duration
+	for _, r := range results {
+		s.Outcomes[r.Outcome]++
+		if s.ByKind[r.Kind] == nil {
+			s.ByKind[r.Kind] = make(map[Outcome]int)
+		}
+		s.ByKind[r.Kind][r.Outcome]++
+
+		if r.Outcome != OutcomeError {
+			latencies = append(latencies, r.Latency)
+			sum += r.Latency
+		}
+	}
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		s.Latency = LatencyStats{
+			Min: latencies[0],
+			Avg: sum / time.Duration(len(latencies)),
+			P50: latencies[len(latencies)*50/100],
+			P95: latencies[len(latencies)*95/100],
+			Max: latencies[len(latencies)-1],
+		}
+	}
+	return s
+}
+
+// Tampered returns the number of requests that were modified in transit
+func (s *Summary) Tampered() int {
+	return s.Outcomes[OutcomeAcceptedTampered] + s.Outcomes[OutcomeRejectedTampered]
+}
+
+// Accepted returns the number of requests accepted by the agents
+func (s *Summary) Accepted() int {
+	return s.Outcomes[OutcomeAccepted] + s.Outcomes[OutcomeAcceptedTampered]
+}
+
+// Rejected returns the number of requests rejected by the agents
+func (s *Summary) Rejected() int {
+	return s.Outcomes[OutcomeRejected] + s.Outcomes[OutcomeRejectedTampered]
+}
+
+// Print writes a human-readable summary
+func (s *Summary) Print(w io.Writer) {
+	fmt.Fprintln(w, "╔════════════════════════════════════════════════════════════╗")
+	fmt.Fprintln(w, "║   Traffic Generator - Summary                              ║")
+	fmt.Fprintln(w, "╠════════════════════════════════════════════════════════════╣")
+	fmt.Fprintf(w, "║ Requests:            %-37d ║\n", s.Total)
+	fmt.Fprintf(w, "║ Elapsed:             %-37s ║\n", s.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "║ Accepted:            %-37d ║\n", s.Accepted())
+	fmt.Fprintf(w, "║ Rejected:            %-37d ║\n", s.Rejected())
+	fmt.Fprintf(w, "║ Tampered in transit: %-37d ║\n", s.Tampered())
+	fmt.Fprintf(w, "║   - attack succeeded: %-36d ║\n", s.Outcomes[OutcomeAcceptedTampered])
+	fmt.Fprintf(w, "║   - attack blocked:   %-36d ║\n", s.Outcomes[OutcomeRejectedTampered])
+	fmt.Fprintf(w, "║ Errors:              %-37d ║\n", s.Outcomes[OutcomeError])
+	fmt.Fprintln(w, "╠════════════════════════════════════════════════════════════╣")
+
+	kinds := make([]string, 0, len(s.ByKind))
+	for kind := range s.ByKind {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		counts := s.ByKind[Kind(kind)]
+		line := fmt.Sprintf("ok=%d tampered=%d/%d rejected=%d err=%d",
+			counts[OutcomeAccepted],
+			counts[OutcomeAcceptedTampered], counts[OutcomeRejectedTampered],
+			counts[OutcomeRejected], counts[OutcomeError])
+		fmt.Fprintf(w, "║ %-8s %-49s ║\n", kind+":", line)
+	}
+
+	fmt.Fprintln(w, "╠════════════════════════════════════════════════════════════╣")
+	round := 100 * time.Microsecond
+	latency := fmt.Sprintf("avg %s / p50 %s / p95 %s / max %s",
+		s.Latency.Avg.Round(round), s.Latency.P50.Round(round),
+		s.Latency.P95.Round(round), s.Latency.Max.Round(round))
+	fmt.Fprintf(w, "║ Latency: %-49s ║\n", latency)
+	fmt.Fprintln(w, "╚════════════════════════════════════════════════════════════╝")
+}
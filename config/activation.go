@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a daily window of local time, as offsets from midnight.
+// A window whose end is before its start runs past midnight.
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Contains reports whether the time of day of t falls in the window
+func (w TimeWindow) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// String renders the window as "HH:MM-HH:MM"
+func (w TimeWindow) String() string {
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+func clock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// ParseSchedule parses comma-separated "HH:MM-HH:MM" windows,
+// e.g. "09:00-12:00,22:00-02:00"
+func ParseSchedule(s string) ([]TimeWindow, error) {
+	var windows []TimeWindow
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		start, end, ok := strings.Cut(item, "-")
+		if !ok {
+			return nil, fmt.Errorf("window %q is not HH:MM-HH:MM", item)
+		}
+		var w TimeWindow
+		var err error
+		if w.Start, err = parseClock(start); err != nil {
+			return nil, fmt.Errorf("window %q: %v", item, err)
+		}
+		if w.End, err = parseClock(end); err != nil {
+			return nil, fmt.Errorf("window %q: %v", item, err)
+		}
+		if w.Start == w.End {
+			return nil, fmt.Errorf("window %q is empty", item)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// parseClock parses "HH:MM" into an offset from midnight ("24:00" is allowed
+// as an end)
+func parseClock(s string) (time.Duration, error) {
+	hh, mm, ok := strings.Cut(strings.TrimSpace(s), ":")
+	h, errH := strconv.Atoi(hh)
+	m, errM := strconv.Atoi(mm)
+	if !ok || errH != nil || errM != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// loadActivation reads ATTACK_PROBABILITY and ATTACK_SEED, which are left
+// nil when unset. A value that is not a number is kept for Validate.
+func (c *Config) loadActivation() {
+	if value := os.Getenv("ATTACK_PROBABILITY"); value != "" {
+		if p, err := strconv.ParseFloat(value, 64); err != nil {
+			c.invalidActivation = append(c.invalidActivation, fmt.Sprintf("ATTACK_PROBABILITY must be a number, got: %q", value))
+		} else {
+			c.AttackProbability = &p
+		}
+	}
+	if value := os.Getenv("ATTACK_SEED"); value != "" {
+		if seed, err := strconv.ParseInt(value, 10, 64); err != nil {
+			c.invalidActivation = append(c.invalidActivation, fmt.Sprintf("ATTACK_SEED must be an integer, got: %q", value))
+		} else {
+			c.AttackSeed = &seed
+		}
+	}
+}
+
+// GetAttackProbability returns the chance an enabled attack fires for a
+// message (unset means always, 0 never)
+func (c *Config) GetAttackProbability() float64 {
+	if c.AttackProbability == nil || *c.AttackProbability < 0 || *c.AttackProbability > 1 {
+		return 1
+	}
+	return *c.AttackProbability
+}
+
+// GetAttackSchedule returns the ATTACK_SCHEDULE windows (nil = always)
+func (c *Config) GetAttackSchedule() []TimeWindow {
+	windows, _ := ParseSchedule(c.AttackSchedule)
+	return windows
+}
+
+// IsActivationConditional reports whether an enabled attack may still let
+// messages through untouched (probability, rate limit or schedule)
+func (c *Config) IsActivationConditional() bool {
+	return c.GetAttackProbability() < 1 || c.AttackRateLimit > 0 || c.AttackSchedule != ""
+}
+
+// validateActivation checks when an enabled attack fires
+func (c *Config) validateActivation() []string {
+	errors := append([]string{}, c.invalidActivation...)
+
+	if p := c.AttackProbability; p != nil && (*p < 0 || *p > 1) {
+		errors = append(errors, fmt.Sprintf("ATTACK_PROBABILITY must be between 0 and 1, got: %.2f", *p))
+	}
+	if c.AttackRateLimit < 0 {
+		errors = append(errors, fmt.Sprintf("ATTACK_RATE_LIMIT must not be negative, got: %d", c.AttackRateLimit))
+	}
+	if _, err := ParseSchedule(c.AttackSchedule); err != nil {
+		errors = append(errors, fmt.Sprintf("Invalid ATTACK_SCHEDULE: %v", err))
+	}
+	return errors
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// ParseHeaderList parses "Name: value" pairs separated by ";" (COVERAGE_HEADERS)
+func ParseHeaderList(s string) (http.Header, error) {
+	header := http.Header{}
+	for _, pair := range strings.Split(s, ";") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q (want Name: value)", pair)
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+// validateCoverage checks the settings of the uncovered_components attack
+func (c *Config) validateCoverage() []string {
+	var errors []string
+
+	switch c.CoverageBodyTamper {
+	case "", types.AttackTypePriceManipulation, types.AttackTypeAddressManipulation, types.AttackTypeProductSubstitution:
+	default:
+		errors = append(errors, fmt.Sprintf("Invalid COVERAGE_BODY_TAMPER: %s (valid: price_manipulation, address_manipulation, product_substitution)", c.CoverageBodyTamper))
+	}
+	if _, err := ParseHeaderList(c.CoverageHeaders); err != nil {
+		errors = append(errors, fmt.Sprintf("Invalid COVERAGE_HEADERS: %v", err))
+	}
+	if c.CoveragePath != "" && !strings.HasPrefix(c.CoveragePath, "/") {
+		errors = append(errors, fmt.Sprintf("COVERAGE_PATH must start with /, got: %s", c.CoveragePath))
+	}
+	if _, err := url.ParseQuery(c.CoverageQuery); err != nil {
+		errors = append(errors, fmt.Sprintf("Invalid COVERAGE_QUERY: %v", err))
+	}
+	return errors
+}
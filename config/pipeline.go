@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// What a pipeline does when a step does not apply (PIPELINE_ON_SKIP)
+const (
+	PipelineOnSkipContinue = "continue" // record the step as skipped and run the rest
+	PipelineOnSkipAbort    = "abort"    // forward the original message untouched
+)
+
+// chainableAttackTypes are the attack types allowed as pipeline steps.
+// uncovered_components picks its own changes from the signature coverage and
+// cannot be combined; identity_spoofing is allowed except in duplicate mode.
+var chainableAttackTypes = map[types.AttackType]bool{
+	types.AttackTypePriceManipulation:   true,
+	types.AttackTypeAddressManipulation: true,
+	types.AttackTypeProductSubstitution: true,
+	types.AttackTypeStripSignature:      true,
+	types.AttackTypeHPKEDowngrade:       true,
+	types.AttackTypeStaleSignature:      true,
+	types.AttackTypeIdentitySpoofing:    true,
+	types.AttackTypePromptInjection:     true,
+	types.AttackTypeSalami:              true,
+}
+
+// getEnvAttackList parses a comma-separated list of attack types
+func getEnvAttackList(key string) []types.AttackType {
+	var list []types.AttackType
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, types.AttackType(item))
+		}
+	}
+	return list
+}
+
+// UsesAttack reports whether the configured attack is t, or a pipeline with
+// a t step
+func (c *Config) UsesAttack(t types.AttackType) bool {
+	if c.AttackType == t {
+		return true
+	}
+	if c.AttackType != types.AttackTypePipeline {
+		return false
+	}
+	for _, step := range c.AttackPipeline {
+		if step == t {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPipelineOnSkip returns what the pipeline does with a step that does not apply
+func (c *Config) GetPipelineOnSkip() string {
+	if c.PipelineOnSkip == "" {
+		return PipelineOnSkipContinue
+	}
+	return c.PipelineOnSkip
+}
+
+// validatePipeline checks the steps of the pipeline attack
+func (c *Config) validatePipeline() []string {
+	var errors []string
+
+	if len(c.AttackPipeline) == 0 {
+		errors = append(errors, "ATTACK_PIPELINE must list at least one step when ATTACK_TYPE=pipeline")
+	}
+	for i, step := range c.AttackPipeline {
+		if !chainableAttackTypes[step] {
+			errors = append(errors, fmt.Sprintf("ATTACK_PIPELINE step %d: %s cannot be chained (valid: price_manipulation, address_manipulation, product_substitution, strip_signature, hpke_downgrade, stale_signature, identity_spoofing, prompt_injection, salami)", i, step))
+		}
+	}
+	if c.UsesAttack(types.AttackTypeIdentitySpoofing) && c.GetSpoofMode() == SpoofModeDuplicate {
+		errors = append(errors, "SPOOF_MODE=duplicate cannot be used in ATTACK_PIPELINE")
+	}
+	switch c.GetPipelineOnSkip() {
+	case PipelineOnSkipContinue, PipelineOnSkipAbort:
+	default:
+		errors = append(errors, fmt.Sprintf("Invalid PIPELINE_ON_SKIP: %s (valid: continue, abort)", c.PipelineOnSkip))
+	}
+	return errors
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// Route is one entry of the routing table. Every condition that is set must
+// match; a route without conditions matches every request. Matching routes
+// are tried by descending Priority, ties keeping table order.
+type Route struct {
+	Name     string `json:"name,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+
+	// Conditions
+	PathPrefix string            `json:"path_prefix,omitempty"` // e.g. /agents/payment
+	Host       string            `json:"host,omitempty"`        // Host header without port, "*.example.com" allowed
+	Headers    map[string]string `json:"headers,omitempty"`     // header values; "*" only requires presence
+	Metadata   map[string]string `json:"metadata,omitempty"`    // JSON-RPC params.message.metadata values
+
+	// StripPrefix removes PathPrefix before forwarding
+	StripPrefix bool `json:"strip_prefix,omitempty"`
+
+	// Destination: an AGENT_URLS name or an explicit URL
+	Agent string `json:"agent,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// DisplayName returns the route name, or a description of its destination
+func (r Route) DisplayName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	if r.Agent != "" {
+		return "agent:" + r.Agent
+	}
+	return r.URL
+}
+
+// loadRoutes loads the routing table from ROUTES (JSON array) or, if unset,
+// from the JSON file named by ROUTES_FILE
+// Example: ROUTES=[{"path_prefix":"/agents/payment","strip_prefix":true,"agent":"payment"}]
+func loadRoutes() []Route {
+	data := []byte(os.Getenv("ROUTES"))
+	source := "ROUTES"
+	if len(data) == 0 {
+		path := os.Getenv("ROUTES_FILE")
+		if path == "" {
+			return nil
+		}
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			fmt.Printf("[CONFIG] [ERROR] Failed to read ROUTES_FILE: %v\n", err)
+			return nil
+		}
+		source = path
+	}
+
+	var routes []Route
+	if err := json.Unmarshal(data, &routes); err != nil {
+		fmt.Printf("[CONFIG] [ERROR] Failed to parse routes from %s: %v\n", source, err)
+		fmt.Println("[CONFIG] [WARN] Routing table disabled")
+		return nil
+	}
+
+	fmt.Printf("[CONFIG] Loaded %d route(s) from %s\n", len(routes), source)
+	return routes
+}
+
+// ResolveDestination returns the URL for an AGENT_URLS name or an explicit
+// URL, or "" if neither resolves
+func (c *Config) ResolveDestination(agent, rawURL string) string {
+	if agent != "" {
+		return c.GetAgentURL(agent)
+	}
+	return rawURL
+}
+
+// validateRoutes checks the routing table and the default route
+func (c *Config) validateRoutes() []string {
+	var errors []string
+	for i, route := range c.Routes {
+		switch {
+		case route.Agent == "" && route.URL == "":
+			errors = append(errors, fmt.Sprintf("Route %d (%s) needs an agent or url", i, route.DisplayName()))
+		case route.Agent != "" && route.URL != "":
+			errors = append(errors, fmt.Sprintf("Route %d (%s) must not set both agent and url", i, route.DisplayName()))
+		case route.Agent != "" && c.GetAgentURL(route.Agent) == "":
+			errors = append(errors, fmt.Sprintf("Route %d (%s) refers to unknown agent: %s", i, route.DisplayName(), route.Agent))
+		case route.URL != "" && !isAbsoluteURL(route.URL):
+			errors = append(errors, fmt.Sprintf("Route %d (%s) has an invalid url: %s", i, route.DisplayName(), route.URL))
+		}
+		if route.StripPrefix && route.PathPrefix == "" {
+			errors = append(errors, fmt.Sprintf("Route %d (%s) sets strip_prefix without path_prefix", i, route.DisplayName()))
+		}
+	}
+
+	if c.RouteDefault != "" && c.GetAgentURL(c.RouteDefault) == "" && !isAbsoluteURL(c.RouteDefault) {
+		errors = append(errors, fmt.Sprintf("ROUTE_DEFAULT must be an agent name or URL, got: %s", c.RouteDefault))
+	}
+	return errors
+}
+
+func isAbsoluteURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
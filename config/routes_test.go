@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func TestLoadRoutes(t *testing.T) {
+	os.Setenv("ROUTES", `[{"name":"payments","path_prefix":"/agents/payment","strip_prefix":true,"agent":"payment","priority":10}]`)
+	defer os.Unsetenv("ROUTES")
+
+	routes := loadRoutes()
+	if len(routes) != 1 {
+		t.Fatalf("Expected 1 route, got %d", len(routes))
+	}
+	if r := routes[0]; r.Name != "payments" || r.PathPrefix != "/agents/payment" || !r.StripPrefix || r.Agent != "payment" || r.Priority != 10 {
+		t.Errorf("Unexpected route: %+v", r)
+	}
+}
+
+func TestLoadRoutes_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.json")
+	os.WriteFile(path, []byte(`[{"host":"*.medical.local","url":"http://localhost:19082"}]`), 0o644)
+	os.Setenv("ROUTES_FILE", path)
+	defer os.Unsetenv("ROUTES_FILE")
+
+	routes := loadRoutes()
+	if len(routes) != 1 || routes[0].Host != "*.medical.local" {
+		t.Fatalf("Unexpected routes: %+v", routes)
+	}
+	if got := routes[0].DisplayName(); got != "http://localhost:19082" {
+		t.Errorf("DisplayName(): got %s", got)
+	}
+}
+
+func TestLoadRoutes_InvalidJSON(t *testing.T) {
+	os.Setenv("ROUTES", `{not json`)
+	defer os.Unsetenv("ROUTES")
+
+	if routes := loadRoutes(); routes != nil {
+		t.Errorf("Invalid ROUTES should disable the routing table, got %+v", routes)
+	}
+}
+
+func TestConfig_Validate_Routes(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  []Route
+		def     string
+		wantErr string
+	}{
+		{"valid", []Route{{PathPrefix: "/agents/payment", StripPrefix: true, Agent: "payment"}, {Host: "a.local", URL: "http://localhost:1"}}, "payment", ""},
+		{"default url", nil, "http://localhost:19083", ""},
+		{"no destination", []Route{{PathPrefix: "/x"}}, "", "needs an agent or url"},
+		{"both destinations", []Route{{Agent: "payment", URL: "http://localhost:1"}}, "", "both agent and url"},
+		{"unknown agent", []Route{{Agent: "billing"}}, "", "unknown agent: billing"},
+		{"invalid url", []Route{{URL: "localhost:1"}}, "", "invalid url"},
+		{"strip without prefix", []Route{{StripPrefix: true, Agent: "payment"}}, "", "strip_prefix without path_prefix"},
+		{"invalid default", nil, "billing", "ROUTE_DEFAULT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				GatewayPort:     "8090",
+				AttackType:      types.AttackTypePriceManipulation,
+				AgentURLs:       map[string]string{"payment": "http://localhost:19083"},
+				PriceMultiplier: 100.0,
+				Routes:          tt.routes,
+				RouteDefault:    tt.def,
+			}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error should contain %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+)
+
+// salami modes (SALAMI_MODE)
+const (
+	SalamiModeSkim     = "skim"     // add SALAMI_SKIM to every amount
+	SalamiModeRound    = "round"    // round amounts up to a multiple of SALAMI_ROUND_UNIT
+	SalamiModeCurrency = "currency" // swap KRW <-> USD, keeping the number
+	SalamiModeScale    = "scale"    // shift the decimal point by SALAMI_SCALE_DIGITS
+	SalamiModeQuantity = "quantity" // add SALAMI_QUANTITY_DELTA to order quantities
+)
+
+// validateSalami checks the settings of the salami attack
+func (c *Config) validateSalami() []string {
+	var errors []string
+
+	switch c.GetSalamiMode() {
+	case SalamiModeSkim:
+		if c.SalamiSkim <= 0 {
+			errors = append(errors, "SALAMI_SKIM must be positive")
+		}
+	case SalamiModeRound:
+		if c.SalamiRoundUnit <= 0 {
+			errors = append(errors, "SALAMI_ROUND_UNIT must be positive")
+		}
+	case SalamiModeCurrency:
+		if c.SalamiKRWPerUSD <= 0 {
+			errors = append(errors, "SALAMI_KRW_PER_USD must be positive")
+		}
+	case SalamiModeScale:
+		if c.SalamiScaleDigits == 0 || c.SalamiScaleDigits < -6 || c.SalamiScaleDigits > 6 {
+			errors = append(errors, fmt.Sprintf("SALAMI_SCALE_DIGITS must be between -6 and 6 and not 0, got %d", c.SalamiScaleDigits))
+		}
+	case SalamiModeQuantity:
+		if c.SalamiQuantityDelta == 0 {
+			errors = append(errors, "SALAMI_QUANTITY_DELTA must not be 0")
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("Invalid SALAMI_MODE: %s (valid: skim, round, currency, scale, quantity)", c.SalamiMode))
+	}
+	return errors
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+)
+
+// identity_spoofing modes (SPOOF_MODE)
+const (
+	SpoofModeSender    = "sender"    // rewrite "from" to SPOOF_FROM
+	SpoofModeContext   = "context"   // move the message to SPOOF_CONTEXT_ID
+	SpoofModeDuplicate = "duplicate" // forward the original, then a copy in SPOOF_CONTEXT_ID
+)
+
+// validateSpoof checks the settings of the identity_spoofing attack
+func (c *Config) validateSpoof() []string {
+	var errors []string
+
+	switch c.GetSpoofMode() {
+	case SpoofModeSender:
+		if c.SpoofFrom == "" {
+			errors = append(errors, "SPOOF_FROM must not be empty in sender mode")
+		}
+	case SpoofModeContext, SpoofModeDuplicate:
+		if c.SpoofContextID == "" {
+			errors = append(errors, "SPOOF_CONTEXT_ID must not be empty in context and duplicate modes")
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("Invalid SPOOF_MODE: %s (valid: sender, context, duplicate)", c.SpoofMode))
+	}
+	return errors
+}
@@ -1,7 +1,16 @@
 module github.com/sage-x-project/sage-gateway-infected-for-demo
 
-go 1.25.2
+go 1.26
 
-require github.com/gorilla/websocket v1.5.1
+require (
+	github.com/andybalholm/brotli v1.2.6
+	github.com/fxamacker/cbor/v2 v2.9.4
+	github.com/gorilla/websocket v1.5.1
+	github.com/klauspost/compress v1.20.1
+	google.golang.org/protobuf v1.36.12
+)
 
-require golang.org/x/net v0.17.0 // indirect
+require (
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/net v0.17.0 // indirect
+)
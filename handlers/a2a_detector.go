@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
 )
 
 // A2AStatus represents the detected A2A protocol status
@@ -12,6 +14,10 @@ type A2AStatus struct {
 	HPKEEnabled bool   // HPKE encrypted payload detected
 	SignatureID string // Signature identifier (e.g., "sig1")
 	Algorithm   string // Signature algorithm
+
+	// Signature is the first Signature-Input member, the one verifiers check;
+	// nil when unsigned or unparsable
+	Signature *sage.SignatureParams
 }
 
 // DetectA2AProtocol detects if the request uses SAGE (RFC 9421) signatures and/or HPKE encryption
@@ -34,6 +40,10 @@ func DetectA2AProtocol(r *http.Request, body []byte) *A2AStatus {
 			status.SignatureID = "sig1"
 		}
 
+		if inputs, err := sage.ParseSignatureInput(signatureInputHeader); err == nil {
+			status.Signature = inputs[0]
+		}
+
 		// Try to extract algorithm from keyid or other fields
 		if strings.Contains(signatureInputHeader, "ecdsa") {
 			status.Algorithm = "ecdsa-p256-sha256"
@@ -46,29 +56,28 @@ func DetectA2AProtocol(r *http.Request, body []byte) *A2AStatus {
 	if len(body) > 0 {
 		var bodyMap map[string]interface{}
 		if err := json.Unmarshal(body, &bodyMap); err == nil {
-			// Check for HPKE-related fields
-			if _, hasEncryptedPayload := bodyMap["encryptedPayload"]; hasEncryptedPayload {
-				status.HPKEEnabled = true
-			}
-			if _, hasEncData := bodyMap["enc_data"]; hasEncData {
-				status.HPKEEnabled = true
-			}
-			if _, hasCiphertext := bodyMap["ciphertext"]; hasCiphertext {
-				status.HPKEEnabled = true
-			}
-
-			// Check for SAGE transport.SecureMessage structure
-			if msgType, ok := bodyMap["type"].(string); ok {
-				if msgType == "secure" || msgType == "encrypted" {
-					status.HPKEEnabled = true
-				}
-			}
+			status.HPKEEnabled = hpkePayload(bodyMap)
 		}
 	}
 
 	return status
 }
 
+// hpkePayload reports whether a decoded JSON body carries an HPKE encrypted
+// payload
+func hpkePayload(bodyMap map[string]interface{}) bool {
+	// Check for HPKE-related fields
+	for _, field := range []string{"encryptedPayload", "enc_data", "ciphertext"} {
+		if _, ok := bodyMap[field]; ok {
+			return true
+		}
+	}
+
+	// Check for SAGE transport.SecureMessage structure
+	msgType, _ := bodyMap["type"].(string)
+	return msgType == "secure" || msgType == "encrypted"
+}
+
 // GetStatusString returns a human-readable status string
 func (s *A2AStatus) GetStatusString() string {
 	if s.SAGEEnabled && s.HPKEEnabled {
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// Activator decides per message whether an enabled attack fires, for
+// "intermittent compromise" demos: with ATTACK_PROBABILITY, at most
+// ATTACK_RATE_LIMIT times a minute, and only inside the ATTACK_SCHEDULE
+// windows. The draws come from an RNG seeded with ATTACK_SEED (or the clock,
+// when unset), so the same seed and the same sequence of messages give the
+// same decisions.
+type Activator struct {
+	probability float64
+	rateLimit   int
+	schedule    []config.TimeWindow
+
+	mu       sync.Mutex
+	seed     int64
+	rng      *rand.Rand
+	sequence int
+	fired    []time.Time // attacks in the last minute, oldest first
+}
+
+// NewActivator creates an activator from the attack settings
+func NewActivator(cfg *config.Config) *Activator {
+	seed := time.Now().UnixNano()
+	if cfg.AttackSeed != nil {
+		seed = *cfg.AttackSeed
+	}
+	return &Activator{
+		probability: cfg.GetAttackProbability(),
+		rateLimit:   cfg.AttackRateLimit,
+		schedule:    cfg.GetAttackSchedule(),
+		seed:        seed,
+		rng:         rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Seed returns the seed of the decision RNG
+func (a *Activator) Seed() int64 {
+	return a.seed
+}
+
+// Decide makes the decision for the next message, received at now. A draw
+// is taken for every message when the probability is below 1, fired or not,
+// so the n-th message always gets the n-th draw whatever the rate limit and
+// the schedule decided before it.
+func (a *Activator) Decide(now time.Time) types.Activation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sequence++
+	decision := types.Activation{Seed: a.seed, Sequence: a.sequence}
+	var reasons []string
+
+	if a.probability < 1 {
+		decision.Roll = a.rng.Float64()
+		if decision.Roll >= a.probability {
+			decision.Reason = fmt.Sprintf("roll %.4f >= probability %.2f", decision.Roll, a.probability)
+			return decision
+		}
+		reasons = append(reasons, fmt.Sprintf("roll %.4f < probability %.2f", decision.Roll, a.probability))
+	}
+
+	if len(a.schedule) > 0 {
+		window := a.window(now)
+		if window == "" {
+			decision.Reason = "outside schedule " + a.scheduleString()
+			return decision
+		}
+		reasons = append(reasons, "in schedule "+window)
+	}
+
+	if a.rateLimit > 0 {
+		cutoff := now.Add(-time.Minute)
+		for len(a.fired) > 0 && !a.fired[0].After(cutoff) {
+			a.fired = a.fired[1:]
+		}
+		if len(a.fired) >= a.rateLimit {
+			decision.Reason = fmt.Sprintf("rate limit %d/min reached", a.rateLimit)
+			return decision
+		}
+		a.fired = append(a.fired, now)
+		reasons = append(reasons, fmt.Sprintf("%d/%d this minute", len(a.fired), a.rateLimit))
+	}
+
+	decision.Fired = true
+	decision.Reason = "always"
+	if len(reasons) > 0 {
+		decision.Reason = strings.Join(reasons, ", ")
+	}
+	return decision
+}
+
+// window returns the schedule window containing now, or ""
+func (a *Activator) window(now time.Time) string {
+	for _, w := range a.schedule {
+		if w.Contains(now) {
+			return w.String()
+		}
+	}
+	return ""
+}
+
+func (a *Activator) scheduleString() string {
+	windows := make([]string, len(a.schedule))
+	for i, w := range a.schedule {
+		windows[i] = w.String()
+	}
+	return strings.Join(windows, ",")
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func TestActivator_Always(t *testing.T) {
+	a := NewActivator(&config.Config{AttackSeed: new(int64(7))})
+
+	decision := a.Decide(time.Now())
+	if !decision.Fired || decision.Reason != "always" || decision.Seed != 7 || decision.Sequence != 1 {
+		t.Errorf("Decide() = %+v", decision)
+	}
+}
+
+func TestActivator_SeedZero(t *testing.T) {
+	cfg := &config.Config{AttackProbability: new(0.5), AttackSeed: new(int64(0))}
+	first, second := NewActivator(cfg), NewActivator(cfg)
+
+	if first.Seed() != 0 {
+		t.Fatalf("ATTACK_SEED=0 should be used as the seed, got %d", first.Seed())
+	}
+	for i := 0; i < 20; i++ {
+		if a, b := first.Decide(time.Now()), second.Decide(time.Now()); a.Roll != b.Roll {
+			t.Fatalf("Decision %d differs with seed 0: %+v vs %+v", i+1, a, b)
+		}
+	}
+}
+
+func TestActivator_ProbabilityReproducible(t *testing.T) {
+	cfg := &config.Config{AttackProbability: new(0.3), AttackSeed: new(int64(42))}
+	first, second := NewActivator(cfg), NewActivator(cfg)
+	now := time.Now()
+
+	fired := 0
+	for i := 0; i < 200; i++ {
+		a, b := first.Decide(now), second.Decide(now)
+		if a != b {
+			t.Fatalf("Decision %d differs with the same seed: %+v vs %+v", i+1, a, b)
+		}
+		if a.Fired != (a.Roll < 0.3) {
+			t.Errorf("Decision %d: fired %v with roll %.4f", i+1, a.Fired, a.Roll)
+		}
+		if a.Fired {
+			fired++
+		}
+	}
+	if fired < 30 || fired > 90 {
+		t.Errorf("Expected about 60 of 200 attacks at p=0.3, got %d", fired)
+	}
+}
+
+func TestActivator_ProbabilityZero(t *testing.T) {
+	a := NewActivator(&config.Config{AttackProbability: new(0.0), AttackSeed: new(int64(42))})
+
+	for i := 0; i < 100; i++ {
+		if decision := a.Decide(time.Now()); decision.Fired {
+			t.Fatalf("Decision %d fired at p=0: %+v", i+1, decision)
+		}
+	}
+}
+
+func TestActivator_RateLimit(t *testing.T) {
+	a := NewActivator(&config.Config{AttackRateLimit: 2, AttackSeed: new(int64(1))})
+	start := time.Unix(1700000000, 0)
+
+	for i, tt := range []struct {
+		at    time.Duration
+		fired bool
+	}{
+		{0, true},
+		{10 * time.Second, true},
+		{20 * time.Second, false},
+		{60 * time.Second, true}, // the first attack left the window
+		{65 * time.Second, false},
+	} {
+		decision := a.Decide(start.Add(tt.at))
+		if decision.Fired != tt.fired {
+			t.Errorf("Decision %d at +%s: got %+v, want fired=%v", i+1, tt.at, decision, tt.fired)
+		}
+	}
+}
+
+func TestActivator_Schedule(t *testing.T) {
+	a := NewActivator(&config.Config{AttackSchedule: "09:00-12:00,22:00-02:00", AttackSeed: new(int64(1))})
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local)
+
+	for at, fired := range map[string]bool{
+		"08:59": false,
+		"09:00": true,
+		"11:59": true,
+		"12:00": false,
+		"23:30": true,
+		"01:15": true,
+		"02:00": false,
+	} {
+		now, _ := time.ParseInLocation("15:04", at, time.Local)
+		now = day.Add(time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute)
+		if decision := a.Decide(now); decision.Fired != fired {
+			t.Errorf("%s: got %+v, want fired=%v", at, decision, fired)
+		}
+	}
+}
+
+func TestProxyHandler_Activation(t *testing.T) {
+	var amounts []float64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var msg map[string]interface{}
+		json.Unmarshal(body, &msg)
+		amounts = append(amounts, msg["amount"].(float64))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	handler := NewProxyHandler(&config.Config{
+		AttackEnabled:     true,
+		AttackType:        types.AttackTypePriceManipulation,
+		TargetAgentURL:    target.URL,
+		PriceMultiplier:   100.0,
+		AttackProbability: new(0.5),
+		AttackSeed:        new(int64(3)),
+	})
+
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest("POST", "/payment", bytes.NewReader([]byte(`{"amount":100}`)))
+		req.Header.Set("Content-Type", "application/json")
+		handler.HandleRequest(httptest.NewRecorder(), req)
+	}
+
+	fired := 0
+	for i, entry := range handler.Recorder().Entries() {
+		if entry.Activation == nil || entry.Activation.Seed != 3 || entry.Activation.Sequence != i+1 {
+			t.Fatalf("Entry %d: activation %+v", i, entry.Activation)
+		}
+		if entry.Activation.Fired != entry.Tampered() || entry.Activation.Fired != (amounts[i] == 10000) {
+			t.Errorf("Entry %d: fired %v, tampered %v, forwarded amount %v", i, entry.Activation.Fired, entry.Tampered(), amounts[i])
+		}
+		if entry.Activation.Fired {
+			fired++
+		}
+	}
+	if fired == 0 || fired == 10 {
+		t.Errorf("Expected some of 10 messages attacked at p=0.5, got %d", fired)
+	}
+}
+
+func TestProxyHandler_ActivationSkipsPassthrough(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	cfg := &config.Config{
+		AttackEnabled:     true,
+		AttackType:        types.AttackTypePriceManipulation,
+		TargetAgentURL:    target.URL,
+		PriceMultiplier:   100.0,
+		AttackProbability: new(0.5),
+		AttackRateLimit:   1,
+		AttackSeed:        new(int64(42)),
+	}
+	handler := NewProxyHandler(cfg)
+
+	// No body and a body without a codec: the attack cannot apply to either
+	handler.HandleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/status", nil))
+	req := httptest.NewRequest("POST", "/notes", bytes.NewReader([]byte("amount: 100")))
+	req.Header.Set("Content-Type", "text/plain")
+	handler.HandleRequest(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/payment", bytes.NewReader([]byte(`{"amount":100}`)))
+	req.Header.Set("Content-Type", "application/json")
+	handler.HandleRequest(httptest.NewRecorder(), req)
+
+	entries := handler.Recorder().Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	for i, entry := range entries[:2] {
+		if entry.Activation != nil {
+			t.Errorf("Passthrough entry %d: activation %+v", i, entry.Activation)
+		}
+	}
+
+	// The first decoded message gets the first draw and the free rate-limit slot
+	want := NewActivator(cfg).Decide(time.Now())
+	got := entries[2].Activation
+	if !want.Fired {
+		t.Fatalf("Seed %d should fire on the first draw: %+v", *cfg.AttackSeed, want)
+	}
+	if got == nil || got.Sequence != 1 || got.Roll != want.Roll || !got.Fired {
+		t.Errorf("Activation = %+v, want the first draw %+v", got, want)
+	}
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// Load-balancing policies (LB_POLICY)
+const (
+	LBRoundRobin     = "round_robin"
+	LBLeastConn      = "least_conn"
+	LBConsistentHash = "consistent_hash"
+)
+
+const (
+	defaultHealthCheckTimeout  = 2 * time.Second
+	defaultHealthCheckFailures = 2
+)
+
+// Backend is one replica of a load-balanced agent
+type Backend struct {
+	URL string
+
+	active atomic.Int64
+	total  atomic.Int64
+
+	mu        sync.Mutex
+	healthy   bool
+	failures  int
+	lastCheck time.Time
+	lastError string
+}
+
+// Healthy reports whether the replica is in rotation
+func (b *Backend) Healthy() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.healthy
+}
+
+func (b *Backend) acquire() {
+	b.active.Add(1)
+	b.total.Add(1)
+}
+
+func (b *Backend) release() {
+	b.active.Add(-1)
+}
+
+// recordProbe updates the health state and reports whether it flipped
+func (b *Backend) recordProbe(err error, threshold int) (changed, healthy bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastCheck = time.Now()
+	wasHealthy := b.healthy
+	if err == nil {
+		b.failures = 0
+		b.lastError = ""
+		b.healthy = true
+	} else {
+		b.failures++
+		b.lastError = err.Error()
+		if b.failures >= threshold {
+			b.healthy = false
+		}
+	}
+	return wasHealthy != b.healthy, b.healthy
+}
+
+func (b *Backend) status() types.UpstreamBackend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return types.UpstreamBackend{
+		URL:            b.URL,
+		Healthy:        b.healthy,
+		ActiveRequests: b.active.Load(),
+		TotalRequests:  b.total.Load(),
+		Failures:       b.failures,
+		LastCheck:      b.lastCheck,
+		LastError:      b.lastError,
+	}
+}
+
+// Pool selects among the replicas of one agent
+type Pool struct {
+	Agent    string
+	policy   string
+	backends []*Backend
+	next     atomic.Uint64
+}
+
+// Pick selects a healthy replica. key (the ContextID) pins a conversation to
+// one replica under consistent_hash; without a key it falls back to round
+// robin. If every replica is ejected, all of them are tried anyway.
+func (p *Pool) Pick(key string) *Backend {
+	candidates := make([]*Backend, 0, len(p.backends))
+	for _, b := range p.backends {
+		if b.Healthy() {
+			candidates = append(candidates, b)
+		}
+	}
+	if len(candidates) == 0 {
+		logger.Warn("All %d replicas of %s are unhealthy, trying them anyway", len(p.backends), p.Agent)
+		candidates = p.backends
+	}
+
+	switch {
+	case p.policy == LBLeastConn:
+		// Start at a rotating offset so ties are spread across replicas
+		start := int(p.next.Add(1)-1) % len(candidates)
+		best := candidates[start]
+		for i := 1; i < len(candidates); i++ {
+			b := candidates[(start+i)%len(candidates)]
+			if b.active.Load() < best.active.Load() {
+				best = b
+			}
+		}
+		return best
+	case p.policy == LBConsistentHash && key != "":
+		// Rendezvous hashing: ejecting a replica only moves its own keys
+		var best *Backend
+		var bestScore uint64
+		for _, b := range candidates {
+			h := fnv.New64a()
+			h.Write([]byte(key))
+			h.Write([]byte(b.URL))
+			if score := h.Sum64(); best == nil || score > bestScore {
+				best, bestScore = b, score
+			}
+		}
+		return best
+	}
+	return candidates[int(p.next.Add(1)-1)%len(candidates)]
+}
+
+// Balancer holds the replica pools of every agent listed with several
+// upstreams in AGENT_URLS and probes their health
+type Balancer struct {
+	pools    map[string]*Pool
+	client   *http.Client
+	path     string
+	interval time.Duration
+	failures int
+}
+
+// NewBalancer creates pools for the configured agent upstreams
+func NewBalancer(cfg *config.Config) *Balancer {
+	policy := cfg.LBPolicy
+	if policy == "" {
+		policy = LBRoundRobin
+	}
+	timeout := time.Duration(cfg.HealthCheckTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	failures := cfg.HealthCheckFailures
+	if failures <= 0 {
+		failures = defaultHealthCheckFailures
+	}
+
+	b := &Balancer{
+		pools:    make(map[string]*Pool),
+		client:   &http.Client{Timeout: timeout},
+		path:     cfg.HealthCheckPath,
+		interval: time.Duration(cfg.HealthCheckInterval) * time.Second,
+		failures: failures,
+	}
+	if len(cfg.AgentUpstreams) > 0 {
+		b.client.Transport = upstreamTransport(upstreamTLSConfig(cfg), 0)
+	}
+	for agent, urls := range cfg.AgentUpstreams {
+		pool := &Pool{Agent: agent, policy: policy}
+		for _, url := range urls {
+			pool.backends = append(pool.backends, &Backend{URL: url, healthy: true})
+		}
+		b.pools[agent] = pool
+	}
+	return b
+}
+
+// Pool returns the replica pool of an agent, or nil if it has a single URL
+func (b *Balancer) Pool(agent string) *Pool {
+	if b == nil {
+		return nil
+	}
+	return b.pools[agent]
+}
+
+// Status reports every pool and replica, sorted by agent name
+func (b *Balancer) Status() []types.UpstreamPool {
+	var pools []types.UpstreamPool
+	for _, pool := range b.pools {
+		status := types.UpstreamPool{Agent: pool.Agent, Policy: pool.policy}
+		for _, backend := range pool.backends {
+			status.Backends = append(status.Backends, backend.status())
+		}
+		pools = append(pools, status)
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Agent < pools[j].Agent })
+	return pools
+}
+
+// Run probes every replica each HEALTH_CHECK_INTERVAL until ctx is done.
+// It returns immediately when probing is disabled or nothing is balanced.
+func (b *Balancer) Run(ctx context.Context) {
+	if b.interval <= 0 || len(b.pools) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		b.CheckAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll probes every replica once, concurrently
+func (b *Balancer) CheckAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, pool := range b.pools {
+		for _, backend := range pool.backends {
+			wg.Add(1)
+			go func(agent string, backend *Backend) {
+				defer wg.Done()
+				err := b.probe(ctx, backend)
+				changed, healthy := backend.recordProbe(err, b.failures)
+				switch {
+				case changed && healthy:
+					logger.Info("✅ %s replica %s is healthy again", agent, backend.URL)
+				case changed:
+					logger.Warn("❌ Ejecting %s replica %s: %v", agent, backend.URL, err)
+				}
+			}(pool.Agent, backend)
+		}
+	}
+	wg.Wait()
+}
+
+// probe requests the health path; any answer below 500 counts as healthy
+func (b *Balancer) probe(ctx context.Context, backend *Backend) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(backend.URL, "/")+b.path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func testPool(policy string, urls ...string) *Pool {
+	cfg := &config.Config{LBPolicy: policy, AgentUpstreams: map[string][]string{"planning": urls}}
+	return NewBalancer(cfg).Pool("planning")
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	pool := testPool(LBRoundRobin, "http://a", "http://b", "http://c")
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		got = append(got, pool.Pick("").URL)
+	}
+	want := []string{"http://a", "http://b", "http://c", "http://a", "http://b", "http://c"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Round robin order: got %v, want %v", got, want)
+	}
+}
+
+func TestPool_LeastConn(t *testing.T) {
+	pool := testPool(LBLeastConn, "http://a", "http://b", "http://c")
+	pool.backends[0].acquire()
+	pool.backends[0].acquire()
+	pool.backends[2].acquire()
+
+	for i := 0; i < 3; i++ {
+		if got := pool.Pick("").URL; got != "http://b" {
+			t.Errorf("Expected the idle replica http://b, got %s", got)
+		}
+	}
+}
+
+func TestPool_ConsistentHash(t *testing.T) {
+	pool := testPool(LBConsistentHash, "http://a", "http://b", "http://c")
+
+	assigned := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("ctx-%d", i)
+		assigned[key] = pool.Pick(key).URL
+		if again := pool.Pick(key).URL; again != assigned[key] {
+			t.Fatalf("Context %s moved from %s to %s", key, assigned[key], again)
+		}
+	}
+
+	// Ejecting one replica only moves the contexts it served
+	pool.backends[1].recordProbe(fmt.Errorf("down"), 1)
+	for key, before := range assigned {
+		after := pool.Pick(key).URL
+		if before != "http://b" && after != before {
+			t.Errorf("Context %s moved from %s to %s although its replica is healthy", key, before, after)
+		}
+		if after == "http://b" {
+			t.Errorf("Context %s was sent to the ejected replica", key)
+		}
+	}
+}
+
+func TestBalancer_HealthChecks(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer flaky.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("Probe path: got %s, want /health", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	balancer := NewBalancer(&config.Config{
+		AgentUpstreams:      map[string][]string{"planning": {flaky.URL, healthy.URL}},
+		HealthCheckPath:     "/health",
+		HealthCheckFailures: 2,
+	})
+	pool := balancer.Pool("planning")
+
+	balancer.CheckAll(context.Background())
+	if !pool.backends[0].Healthy() {
+		t.Fatal("One failure should not eject a replica with a threshold of 2")
+	}
+
+	balancer.CheckAll(context.Background())
+	if pool.backends[0].Healthy() {
+		t.Fatal("Replica should be ejected after 2 failed probes")
+	}
+	for i := 0; i < 4; i++ {
+		if got := pool.Pick("").URL; got != healthy.URL {
+			t.Errorf("Ejected replica was picked: %s", got)
+		}
+	}
+
+	failing.Store(false)
+	balancer.CheckAll(context.Background())
+	if !pool.backends[0].Healthy() {
+		t.Error("Replica should rejoin after a successful probe")
+	}
+
+	status := balancer.Status()
+	if len(status) != 1 || len(status[0].Backends) != 2 || status[0].Backends[0].LastCheck.IsZero() {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestProxyHandler_LoadBalancesReplicas(t *testing.T) {
+	var hits [2]atomic.Int64
+	var replicas []string
+	for i := range hits {
+		i := i
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i].Add(1)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		replicas = append(replicas, server.URL)
+	}
+
+	cfg := &config.Config{
+		AgentURLs:      map[string]string{"planning": replicas[0]},
+		AgentUpstreams: map[string][]string{"planning": replicas},
+		LBPolicy:       LBRoundRobin,
+	}
+	handler := NewProxyHandler(cfg)
+
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest("POST", "/process", bytes.NewBufferString(`{"to":"planning","content":"plan a trip"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.HandleRequest(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("HandleRequest() status code: got %d, want %d", w.Code, http.StatusOK)
+		}
+	}
+	if hits[0].Load() != 2 || hits[1].Load() != 2 {
+		t.Errorf("Expected 2 requests per replica, got %d and %d", hits[0].Load(), hits[1].Load())
+	}
+
+	// /status reports the replicas
+	w := httptest.NewRecorder()
+	handler.HandleStatus(w, httptest.NewRequest("GET", "/status", nil))
+	var status types.ProxyResponse
+	json.NewDecoder(w.Body).Decode(&status)
+	if len(status.Upstreams) != 1 || status.Upstreams[0].Agent != "planning" || len(status.Upstreams[0].Backends) != 2 {
+		t.Fatalf("Unexpected upstream status: %+v", status.Upstreams)
+	}
+	for _, backend := range status.Upstreams[0].Backends {
+		if !backend.Healthy || backend.TotalRequests != 2 || backend.ActiveRequests != 0 {
+			t.Errorf("Unexpected backend status: %+v", backend)
+		}
+	}
+}
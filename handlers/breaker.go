@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// Circuit breaker defaults, used for zero BreakerConfig fields
+const (
+	defaultBreakerFailureRatio = 0.5
+	defaultBreakerMinRequests  = 5
+	defaultBreakerWindow       = 30 * time.Second
+	defaultBreakerCoolDown     = 15 * time.Second
+)
+
+// ErrCircuitOpen is matched (errors.Is) by CircuitOpenError
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitOpenError is returned without contacting an upstream whose breaker is open
+type CircuitOpenError struct {
+	Upstream   string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open for %s (retry in %s)", e.Upstream, e.RetryAfter.Round(time.Second))
+}
+
+// Is makes errors.Is(err, ErrCircuitOpen) match
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// BreakerConfig holds circuit breaker settings
+type BreakerConfig struct {
+	FailureRatio float64       // failed/total attempts in the window that opens the breaker
+	MinRequests  int           // attempts in the window before the ratio applies
+	Window       time.Duration // counting window of the closed state
+	CoolDown     time.Duration // time open before a half-open trial request
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.FailureRatio <= 0 {
+		c.FailureRatio = defaultBreakerFailureRatio
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = defaultBreakerMinRequests
+	}
+	if c.Window <= 0 {
+		c.Window = defaultBreakerWindow
+	}
+	if c.CoolDown <= 0 {
+		c.CoolDown = defaultBreakerCoolDown
+	}
+	return c
+}
+
+// CircuitBreaker guards one upstream. Closed counts attempts in a window and
+// opens once the failure ratio is reached; open rejects until the cool-down
+// has passed; half-open lets one trial attempt decide between the two.
+type CircuitBreaker struct {
+	upstream string
+	config   BreakerConfig
+
+	mu          sync.Mutex
+	state       string
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	trial       bool // half-open trial attempt in flight
+
+	// Counters for /metrics
+	successes   int64
+	failed      int64
+	rejected    int64
+	transitions map[string]int64
+}
+
+func newCircuitBreaker(upstream string, config BreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		upstream:    upstream,
+		config:      config,
+		state:       BreakerClosed,
+		windowStart: time.Now(),
+		transitions: make(map[string]int64),
+	}
+}
+
+// Allow reports whether an attempt may be sent, returning a
+// *CircuitOpenError when the breaker rejects it
+func (b *CircuitBreaker) Allow() error {
+	var transition *breakerTransition
+	defer func() { transition.emit() }() // after the unlock below
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if wait := b.config.CoolDown - time.Since(b.openedAt); wait > 0 {
+			b.rejected++
+			return &CircuitOpenError{Upstream: b.upstream, RetryAfter: wait}
+		}
+		transition = b.setState(BreakerHalfOpen, "cool-down elapsed")
+		b.trial = true
+	case BreakerHalfOpen:
+		if b.trial {
+			b.rejected++
+			return &CircuitOpenError{Upstream: b.upstream, RetryAfter: time.Second}
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed attempt
+func (b *CircuitBreaker) Record(success bool) {
+	var transition *breakerTransition
+	defer func() { transition.emit() }() // after the unlock below
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.successes++
+	} else {
+		b.failed++
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.trial = false
+		if success {
+			transition = b.setState(BreakerClosed, "trial request succeeded")
+		} else {
+			transition = b.setState(BreakerOpen, "trial request failed")
+		}
+	case BreakerClosed:
+		if time.Since(b.windowStart) > b.config.Window {
+			b.windowStart, b.requests, b.failures = time.Now(), 0, 0
+		}
+		b.requests++
+		if !success {
+			b.failures++
+		}
+		if b.requests >= b.config.MinRequests && float64(b.failures)/float64(b.requests) >= b.config.FailureRatio {
+			transition = b.setState(BreakerOpen, fmt.Sprintf("%d of %d attempts failed", b.failures, b.requests))
+		}
+	}
+}
+
+// State returns the current state
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// breakerTransition is a state change, emitted once b.mu is released so the
+// log broadcast never runs under the lock
+type breakerTransition struct {
+	upstream, from, to, reason string
+}
+
+func (t *breakerTransition) emit() {
+	if t != nil {
+		logger.LogBreaker(t.upstream, t.from, t.to, t.reason)
+	}
+}
+
+// setState switches state and returns the transition to emit; callers hold b.mu
+func (b *CircuitBreaker) setState(state, reason string) *breakerTransition {
+	from := b.state
+	b.state = state
+	b.transitions[state]++
+	switch state {
+	case BreakerOpen:
+		b.openedAt = time.Now()
+	case BreakerClosed:
+		b.windowStart, b.requests, b.failures = time.Now(), 0, 0
+	}
+	return &breakerTransition{upstream: b.upstream, from: from, to: state, reason: reason}
+}
+
+func (b *CircuitBreaker) status() types.BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return types.BreakerStatus{
+		Upstream:  b.upstream,
+		State:     b.state,
+		Requests:  b.requests,
+		Failures:  b.failures,
+		Successes: b.successes,
+		Failed:    b.failed,
+		Rejected:  b.rejected,
+		Opened:    b.transitions[BreakerOpen],
+	}
+}
+
+// BreakerSet holds one circuit breaker per upstream host
+type BreakerSet struct {
+	config   BreakerConfig
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewBreakerSet creates breakers on demand with the given settings
+func NewBreakerSet(config BreakerConfig) *BreakerSet {
+	return &BreakerSet{config: config.withDefaults(), breakers: make(map[string]*CircuitBreaker)}
+}
+
+// Get returns the breaker of an upstream, creating it if needed
+func (s *BreakerSet) Get(upstream string) *CircuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.breakers[upstream]
+	if !ok {
+		b = newCircuitBreaker(upstream, s.config)
+		s.breakers[upstream] = b
+	}
+	return b
+}
+
+// Status reports every breaker, sorted by upstream
+func (s *BreakerSet) Status() []types.BreakerStatus {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	breakers := make([]*CircuitBreaker, 0, len(s.breakers))
+	for _, b := range s.breakers {
+		breakers = append(breakers, b)
+	}
+	s.mu.Unlock()
+
+	statuses := make([]types.BreakerStatus, 0, len(breakers))
+	for _, b := range breakers {
+		statuses = append(statuses, b.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Upstream < statuses[j].Upstream })
+	return statuses
+}
+
+// WriteMetrics writes the breaker metrics in the Prometheus text format
+func (s *BreakerSet) WriteMetrics(w io.Writer) {
+	statuses := s.Status()
+	stateValue := map[string]int{BreakerClosed: 0, BreakerHalfOpen: 1, BreakerOpen: 2}
+
+	fmt.Fprintln(w, "# HELP gateway_circuit_breaker_state Circuit breaker state (0 closed, 1 half-open, 2 open)")
+	fmt.Fprintln(w, "# TYPE gateway_circuit_breaker_state gauge")
+	for _, st := range statuses {
+		fmt.Fprintf(w, "gateway_circuit_breaker_state{upstream=%q} %d\n", st.Upstream, stateValue[st.State])
+	}
+	fmt.Fprintln(w, "# HELP gateway_circuit_breaker_opened_total Times the circuit breaker opened")
+	fmt.Fprintln(w, "# TYPE gateway_circuit_breaker_opened_total counter")
+	for _, st := range statuses {
+		fmt.Fprintf(w, "gateway_circuit_breaker_opened_total{upstream=%q} %d\n", st.Upstream, st.Opened)
+	}
+	fmt.Fprintln(w, "# HELP gateway_circuit_breaker_rejected_total Attempts rejected by an open circuit breaker")
+	fmt.Fprintln(w, "# TYPE gateway_circuit_breaker_rejected_total counter")
+	for _, st := range statuses {
+		fmt.Fprintf(w, "gateway_circuit_breaker_rejected_total{upstream=%q} %d\n", st.Upstream, st.Rejected)
+	}
+	fmt.Fprintln(w, "# HELP gateway_upstream_attempts_total Upstream attempts by result")
+	fmt.Fprintln(w, "# TYPE gateway_upstream_attempts_total counter")
+	for _, st := range statuses {
+		fmt.Fprintf(w, "gateway_upstream_attempts_total{upstream=%q,result=\"success\"} %d\n", st.Upstream, st.Successes)
+		fmt.Fprintf(w, "gateway_upstream_attempts_total{upstream=%q,result=\"failure\"} %d\n", st.Upstream, st.Failed)
+	}
+}
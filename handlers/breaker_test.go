@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func TestCircuitBreaker_States(t *testing.T) {
+	b := NewBreakerSet(BreakerConfig{FailureRatio: 0.5, MinRequests: 4, CoolDown: 20 * time.Millisecond}).Get("agent.local")
+
+	// Below MinRequests the ratio does not apply
+	for i := 0; i < 3; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Closed breaker rejected attempt %d: %v", i, err)
+		}
+		b.Record(false)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("State after 3 attempts: got %s, want %s", b.State(), BreakerClosed)
+	}
+
+	b.Allow()
+	b.Record(true)
+	if b.State() != BreakerOpen {
+		t.Fatalf("3 of 4 failed attempts should open the breaker, got %s", b.State())
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Open breaker should reject, got %v", err)
+	}
+
+	// After the cool-down a single trial attempt is let through
+	time.Sleep(30 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Trial attempt rejected: %v", err)
+	}
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("State during trial: got %s, want %s", b.State(), BreakerHalfOpen)
+	}
+	if err := b.Allow(); err == nil {
+		t.Fatal("Half-open breaker should reject a second concurrent attempt")
+	}
+	b.Record(false)
+	if b.State() != BreakerOpen {
+		t.Fatalf("Failed trial should reopen the breaker, got %s", b.State())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	b.Allow()
+	b.Record(true)
+	if b.State() != BreakerClosed {
+		t.Fatalf("Successful trial should close the breaker, got %s", b.State())
+	}
+
+	st := b.status()
+	if st.Opened != 2 || st.Rejected != 2 || st.Failed != 4 || st.Successes != 2 {
+		t.Errorf("Unexpected counters: %+v", st)
+	}
+}
+
+// stateHub reads the breaker state while a transition is broadcast, which
+// deadlocks if the broadcast runs under the breaker lock
+type stateHub struct {
+	breaker *CircuitBreaker
+	states  []string
+}
+
+func (h *stateHub) BroadcastLog(level, eventType, message string, data map[string]interface{}) {
+	if eventType == "circuit_breaker" {
+		h.states = append(h.states, h.breaker.State())
+	}
+}
+
+func (h *stateHub) GetClientCount() int { return 1 }
+
+func TestCircuitBreaker_EmitsAfterUnlock(t *testing.T) {
+	b := NewBreakerSet(BreakerConfig{FailureRatio: 0.5, MinRequests: 1, CoolDown: time.Hour}).Get("agent.local")
+	hub := &stateHub{breaker: b}
+	logger.SetWebSocketHub(hub)
+	defer logger.SetWebSocketHub(nil)
+
+	done := make(chan struct{})
+	go func() {
+		b.Allow()
+		b.Record(false)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Transition broadcast blocked on the breaker lock")
+	}
+	if len(hub.states) != 1 || hub.states[0] != BreakerOpen {
+		t.Errorf("States seen by the broadcast: %v", hub.states)
+	}
+}
+
+func TestRetryableHTTPClient_BreakerFailsFast(t *testing.T) {
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{
+		MaxRetries:  5,
+		BackoffBase: 1,
+		HTTPTimeout: 5,
+		Breaker:     &BreakerConfig{FailureRatio: 1, MinRequests: 3, CoolDown: time.Minute},
+	})
+
+	// The breaker opens between attempts: the retries stop with the open
+	// breaker error rather than the last 500, whose body is already closed
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if !errors.Is(err, ErrCircuitOpen) || resp != nil {
+		t.Fatalf("Expected ErrCircuitOpen and no response, got %v, %v", resp, err)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("Breaker should stop the retries after 3 attempts, got %d", hits.Load())
+	}
+
+	req, _ = http.NewRequest("GET", server.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("Open breaker should not contact the upstream, got %d hits", hits.Load())
+	}
+}
+
+func TestRetryableHTTPClient_BreakerIgnoresCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{
+		HTTPTimeout: 5,
+		Breaker:     &BreakerConfig{FailureRatio: 1, MinRequests: 1, CoolDown: time.Minute},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("Expected the cancelled attempt to fail")
+	}
+	if state := client.Breakers().Get(req.URL.Host).State(); state != BreakerClosed {
+		t.Errorf("A cancelled attempt should not count against the upstream, breaker %s", state)
+	}
+}
+
+func TestProxyHandler_CircuitOpensDuringRetries(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer target.Close()
+
+	handler := NewProxyHandler(&config.Config{
+		TargetAgentURL:      target.URL,
+		MaxRetries:          3,
+		RetryBackoffBase:    1,
+		BreakerEnabled:      true,
+		BreakerFailureRatio: 1,
+		BreakerMinRequests:  1,
+		BreakerCoolDown:     30,
+	})
+
+	req := httptest.NewRequest("GET", "/status", nil)
+	w := httptest.NewRecorder()
+	handler.HandleRequest(w, req)
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "circuit breaker open") {
+		t.Errorf("Response: got %d %q, want 503 with the open breaker message", w.Code, w.Body.String())
+	}
+}
+
+func TestProxyHandler_CircuitOpen(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer target.Close()
+
+	cfg := &config.Config{
+		TargetAgentURL:      target.URL,
+		BreakerEnabled:      true,
+		BreakerFailureRatio: 1,
+		BreakerMinRequests:  2,
+		BreakerCoolDown:     30,
+	}
+	handler := NewProxyHandler(cfg)
+
+	codes := make([]int, 3)
+	for i := range codes {
+		req := httptest.NewRequest("POST", "/process", bytes.NewBufferString(`{"amount":100}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.HandleRequest(w, req)
+		codes[i] = w.Code
+		if i == 2 && w.Header().Get("Retry-After") == "" {
+			t.Error("Fail-fast response should carry Retry-After")
+		}
+	}
+	if codes[0] != http.StatusBadGateway || codes[1] != http.StatusBadGateway || codes[2] != http.StatusServiceUnavailable {
+		t.Errorf("Status codes: got %v, want [502 502 503]", codes)
+	}
+
+	w := httptest.NewRecorder()
+	handler.HandleStatus(w, httptest.NewRequest("GET", "/status", nil))
+	var status types.ProxyResponse
+	json.NewDecoder(w.Body).Decode(&status)
+	if len(status.Breakers) != 1 || status.Breakers[0].State != BreakerOpen || status.Breakers[0].Rejected != 1 {
+		t.Errorf("Unexpected breaker status: %+v", status.Breakers)
+	}
+
+	w = httptest.NewRecorder()
+	handler.HandleMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+	host := strings.TrimPrefix(target.URL, "http://")
+	for _, want := range []string{
+		`gateway_circuit_breaker_state{upstream="` + host + `"} 2`,
+		`gateway_circuit_breaker_opened_total{upstream="` + host + `"} 1`,
+		`gateway_upstream_attempts_total{upstream="` + host + `",result="failure"} 2`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Metrics missing %q:\n%s", want, w.Body.String())
+		}
+	}
+}
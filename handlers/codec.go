@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BodyCodec converts a request body to the generic tree the attacks operate on
+// (map[string]interface{} with float64 numbers, like encoding/json produces)
+// and back into the same wire format.
+type BodyCodec interface {
+	// Decode parses body into a generic tree
+	Decode(body []byte, contentType string) (map[string]interface{}, error)
+
+	// Encode serialises msg in the codec's format. original is the body that
+	// was decoded, so formats with parts the tree cannot represent (multipart
+	// files, protobuf unknown fields) can carry them over.
+	Encode(msg map[string]interface{}, original []byte, contentType string) ([]byte, error)
+}
+
+// CodecRegistry selects a BodyCodec by media type
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	codecs map[string]BodyCodec
+}
+
+// NewCodecRegistry creates a registry with the built-in JSON, form,
+// multipart and CBOR codecs. Protobuf needs a descriptor set and is
+// registered separately.
+func NewCodecRegistry() *CodecRegistry {
+	r := &CodecRegistry{codecs: make(map[string]BodyCodec)}
+	r.Register("application/json", JSONCodec{})
+	r.Register("application/x-www-form-urlencoded", FormCodec{})
+	r.Register("multipart/form-data", MultipartCodec{})
+	r.Register("application/cbor", NewCBORCodec())
+	return r
+}
+
+// Register associates a codec with a media type (e.g. "application/cbor")
+func (r *CodecRegistry) Register(mediaType string, codec BodyCodec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codecs[strings.ToLower(mediaType)] = codec
+}
+
+// Lookup returns the codec for a Content-Type header, or nil if the body
+// cannot be tampered with. Structured syntax suffixes (+json, +cbor) map to
+// their base codec, and a missing Content-Type is tried as JSON.
+func (r *CodecRegistry) Lookup(contentType string) BodyCodec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if contentType == "" {
+		return r.codecs["application/json"]
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	if codec, ok := r.codecs[mediaType]; ok {
+		return codec
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		return r.codecs["application/"+mediaType[i+1:]]
+	}
+	return nil
+}
+
+// JSONCodec handles application/json bodies that are JSON objects
+type JSONCodec struct{}
+
+// Decode implements BodyCodec
+func (JSONCodec) Decode(body []byte, contentType string) (map[string]interface{}, error) {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		// "null" unmarshals without error but is not an object
+		return nil, errors.New("JSON body is null")
+	}
+	return msg, nil
+}
+
+// Encode implements BodyCodec
+func (JSONCodec) Encode(msg map[string]interface{}, original []byte, contentType string) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+// parseScalar turns a textual field value into the tree representation:
+// numbers become float64 when they round-trip exactly, everything else stays
+// a string
+func parseScalar(s string) interface{} {
+	if f, err := strconv.ParseFloat(s, 64); err == nil && formatScalar(f) == s {
+		return f
+	}
+	return s
+}
+
+// formatScalar is the inverse of parseScalar for re-encoding text formats
+func formatScalar(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case int:
+		return strconv.Itoa(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// setField stores a flat field in the tree. Bracketed keys such as
+// metadata[amount] become nested maps, and repeated keys become lists.
+func setField(tree map[string]interface{}, key string, value interface{}) {
+	path := splitFieldKey(key)
+	node := tree
+	for _, name := range path[:len(path)-1] {
+		child, ok := node[name].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			node[name] = child
+		}
+		node = child
+	}
+
+	last := path[len(path)-1]
+	switch existing := node[last].(type) {
+	case nil:
+		node[last] = value
+	case []interface{}:
+		node[last] = append(existing, value)
+	default:
+		node[last] = []interface{}{existing, value}
+	}
+}
+
+// splitFieldKey splits "a[b][c]" into ["a", "b", "c"]
+func splitFieldKey(key string) []string {
+	open := strings.IndexByte(key, '[')
+	if open <= 0 || !strings.HasSuffix(key, "]") {
+		return []string{key}
+	}
+	parts := []string{key[:open]}
+	for _, p := range strings.Split(key[open+1:len(key)-1], "][") {
+		parts = append(parts, p)
+	}
+	return parts
+}
+
+// fieldPair is one flattened name/value pair of a text form
+type fieldPair struct {
+	name  string
+	value string
+}
+
+// flattenFields turns a tree back into bracketed name/value pairs. Names in
+// order come first, in that order; any other names follow sorted.
+func flattenFields(tree map[string]interface{}, order []string) []fieldPair {
+	var pairs []fieldPair
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(val))
+			for k := range val {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(prefix+"["+k+"]", val[k])
+			}
+		case []interface{}:
+			for _, item := range val {
+				walk(prefix, item)
+			}
+		default:
+			pairs = append(pairs, fieldPair{name: prefix, value: formatScalar(val)})
+		}
+	}
+
+	keys := make([]string, 0, len(tree))
+	for k := range tree {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		walk(k, tree[k])
+	}
+
+	// Restore the original field order where possible
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, seen := rank[name]; !seen {
+			rank[name] = i
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		ri, iok := rank[pairs[i].name]
+		rj, jok := rank[pairs[j].name]
+		switch {
+		case iok && jok:
+			return ri < rj
+		case iok != jok:
+			return iok
+		}
+		return false
+	})
+	return pairs
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBORCodec handles application/cbor (RFC 8949) bodies whose top-level item
+// is a map with text keys. Integers are exposed as float64 like JSON numbers;
+// on encode, integral floats are written back as CBOR integers using the
+// core deterministic encoding.
+type CBORCodec struct {
+	dec cbor.DecMode
+	enc cbor.EncMode
+}
+
+// NewCBORCodec creates a CBOR codec
+func NewCBORCodec() *CBORCodec {
+	dec, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	enc, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return &CBORCodec{dec: dec, enc: enc}
+}
+
+// Decode implements BodyCodec
+func (c *CBORCodec) Decode(body []byte, contentType string) (map[string]interface{}, error) {
+	var msg map[string]interface{}
+	if err := c.dec.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, fmt.Errorf("CBOR body is not a map")
+	}
+	return normalizeCBOR(msg).(map[string]interface{}), nil
+}
+
+// Encode implements BodyCodec
+func (c *CBORCodec) Encode(msg map[string]interface{}, original []byte, contentType string) ([]byte, error) {
+	return c.enc.Marshal(denormalizeCBOR(msg))
+}
+
+// normalizeCBOR converts CBOR integers to float64
+func normalizeCBOR(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = normalizeCBOR(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalizeCBOR(item)
+		}
+		return val
+	case uint64:
+		return float64(val)
+	case int64:
+		return float64(val)
+	}
+	return v
+}
+
+// denormalizeCBOR converts integral float64 values back to integers
+func denormalizeCBOR(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = denormalizeCBOR(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = denormalizeCBOR(item)
+		}
+		return out
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int64(val)
+		}
+	}
+	return v
+}
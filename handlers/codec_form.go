@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// FormCodec handles application/x-www-form-urlencoded bodies
+type FormCodec struct{}
+
+// Decode implements BodyCodec
+func (FormCodec) Decode(body []byte, contentType string) (map[string]interface{}, error) {
+	pairs, err := parseFormPairs(string(body))
+	if err != nil {
+		return nil, err
+	}
+	tree := make(map[string]interface{})
+	for _, p := range pairs {
+		setField(tree, p.name, parseScalar(p.value))
+	}
+	return tree, nil
+}
+
+// Encode implements BodyCodec, keeping the original field order
+func (FormCodec) Encode(msg map[string]interface{}, original []byte, contentType string) ([]byte, error) {
+	originalPairs, _ := parseFormPairs(string(original))
+	order := make([]string, 0, len(originalPairs))
+	for _, p := range originalPairs {
+		order = append(order, p.name)
+	}
+
+	var b strings.Builder
+	for i, p := range flattenFields(msg, order) {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(url.QueryEscape(p.name))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(p.value))
+	}
+	return []byte(b.String()), nil
+}
+
+// parseFormPairs parses a urlencoded body preserving field order
+func parseFormPairs(body string) ([]fieldPair, error) {
+	var pairs []fieldPair
+	for _, part := range strings.Split(body, "&") {
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		n, err := url.QueryUnescape(name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := url.QueryUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, fieldPair{name: n, value: v})
+	}
+	if len(pairs) == 0 {
+		return nil, errors.New("empty form body")
+	}
+	return pairs, nil
+}
+
+// MultipartCodec handles multipart/form-data bodies. Only non-file fields
+// are exposed to attacks; file parts are carried over byte for byte.
+type MultipartCodec struct{}
+
+// Decode implements BodyCodec
+func (MultipartCodec) Decode(body []byte, contentType string) (map[string]interface{}, error) {
+	reader, err := multipartReader(body, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make(map[string]interface{})
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FileName() != "" || part.FormName() == "" {
+			continue
+		}
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		setField(tree, part.FormName(), parseScalar(string(value)))
+	}
+	return tree, nil
+}
+
+// Encode implements BodyCodec. Field parts keep their original headers and
+// position; removed fields are dropped and new fields are appended.
+func (MultipartCodec) Encode(msg map[string]interface{}, original []byte, contentType string) ([]byte, error) {
+	reader, err := multipartReader(original, contentType)
+	if err != nil {
+		return nil, err
+	}
+	_, params, _ := mime.ParseMediaType(contentType)
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.SetBoundary(params["boundary"]); err != nil {
+		return nil, err
+	}
+
+	// Queue the new values per field name
+	var order []string
+	values := make(map[string][]string)
+	for _, p := range flattenFields(msg, nil) {
+		if _, ok := values[p.name]; !ok {
+			order = append(order, p.name)
+		}
+		values[p.name] = append(values[p.name], p.value)
+	}
+
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		isField := part.FileName() == "" && part.FormName() != ""
+		var content []byte
+		if isField {
+			queued := values[part.FormName()]
+			if len(queued) == 0 {
+				continue // field removed by the attack
+			}
+			content = []byte(queued[0])
+			values[part.FormName()] = queued[1:]
+		} else if content, err = io.ReadAll(part); err != nil {
+			return nil, err
+		}
+
+		w, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return nil, err
+		}
+		w.Write(content)
+	}
+
+	for _, name := range order {
+		for _, value := range values[name] {
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, strings.ReplaceAll(name, `"`, `\"`)))
+			w, err := writer.CreatePart(header)
+			if err != nil {
+				return nil, err
+			}
+			w.Write([]byte(value))
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func multipartReader(body []byte, contentType string) (*multipart.Reader, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("multipart body without boundary")
+	}
+	return multipart.NewReader(bytes.NewReader(body), boundary), nil
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"os"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+)
+
+// ProtobufMediaTypes are the media types the protobuf codec is registered for
+var ProtobufMediaTypes = []string{"application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf"}
+
+// ProtobufCodec handles protobuf bodies using message descriptors loaded from
+// a FileDescriptorSet (protoc --descriptor_set_out --include_imports).
+//
+// The message type comes from the Content-Type "proto" or "messageType"
+// parameter, falling back to the configured default. Fields are keyed by
+// their proto names; numbers become float64, enums their value names and
+// bytes base64 strings. Unknown fields of the original message are kept.
+type ProtobufCodec struct {
+	files       *protoregistry.Files
+	defaultType protoreflect.FullName
+}
+
+// NewProtobufCodec loads a descriptor set file
+func NewProtobufCodec(descriptorSetPath, defaultType string) (*ProtobufCodec, error) {
+	data, err := os.ReadFile(descriptorSetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse descriptor set %s: %w", descriptorSetPath, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("load descriptor set %s: %w", descriptorSetPath, err)
+	}
+
+	codec := &ProtobufCodec{files: files, defaultType: protoreflect.FullName(defaultType)}
+	if defaultType != "" {
+		if _, err := codec.messageDescriptor(""); err != nil {
+			return nil, err
+		}
+	}
+	return codec, nil
+}
+
+// Decode implements BodyCodec
+func (c *ProtobufCodec) Decode(body []byte, contentType string) (map[string]interface{}, error) {
+	md, err := c.messageDescriptor(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return messageToTree(msg), nil
+}
+
+// Encode implements BodyCodec
+func (c *ProtobufCodec) Encode(tree map[string]interface{}, original []byte, contentType string) ([]byte, error) {
+	md, err := c.messageDescriptor(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start from the original message so unknown fields survive
+	msg := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(original, msg); err != nil {
+		return nil, err
+	}
+	if err := treeToMessage(tree, msg); err != nil {
+		return nil, err
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+}
+
+// messageDescriptor resolves the message type for a Content-Type
+func (c *ProtobufCodec) messageDescriptor(contentType string) (protoreflect.MessageDescriptor, error) {
+	name := c.defaultType
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		for _, key := range []string{"proto", "messagetype"} {
+			if v := params[key]; v != "" {
+				name = protoreflect.FullName(v)
+				break
+			}
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no protobuf message type in Content-Type %q and no default configured", contentType)
+	}
+
+	desc, err := c.files.FindDescriptorByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf message type %s: %w", name, err)
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a protobuf message type", name)
+	}
+	return md, nil
+}
+
+// messageToTree converts a message to the generic tree
+func messageToTree(msg protoreflect.Message) map[string]interface{} {
+	tree := make(map[string]interface{})
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			list := v.List()
+			items := make([]interface{}, list.Len())
+			for i := range items {
+				items[i] = scalarToTree(fd, list.Get(i))
+			}
+			tree[string(fd.Name())] = items
+		case fd.IsMap():
+			entries := make(map[string]interface{})
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				entries[k.String()] = scalarToTree(fd.MapValue(), mv)
+				return true
+			})
+			tree[string(fd.Name())] = entries
+		default:
+			tree[string(fd.Name())] = scalarToTree(fd, v)
+		}
+		return true
+	})
+	return tree
+}
+
+func scalarToTree(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool()
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return float64(v.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageToTree(v.Message())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return float64(v.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return float64(v.Uint())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float()
+	}
+	return nil
+}
+
+// treeToMessage writes the tree into msg. Fields absent from the tree are
+// cleared; tree keys without a matching field are ignored.
+func treeToMessage(tree map[string]interface{}, msg protoreflect.Message) error {
+	fields := msg.Descriptor().Fields()
+	for key := range tree {
+		if fields.ByName(protoreflect.Name(key)) == nil && fields.ByJSONName(key) == nil {
+			logger.Debug("Protobuf %s has no field %q, dropping it", msg.Descriptor().FullName(), key)
+		}
+	}
+
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		raw, ok := tree[string(fd.Name())]
+		if !ok {
+			raw, ok = tree[fd.JSONName()]
+		}
+		if !ok || raw == nil {
+			msg.Clear(fd)
+			continue
+		}
+
+		switch {
+		case fd.IsList():
+			items, ok := raw.([]interface{})
+			if !ok {
+				items = []interface{}{raw}
+			}
+			list := msg.NewField(fd).List()
+			for _, item := range items {
+				v, err := treeToValue(fd, item, list.NewElement)
+				if err != nil {
+					return err
+				}
+				list.Append(v)
+			}
+			msg.Set(fd, protoreflect.ValueOfList(list))
+		case fd.IsMap():
+			entries, ok := raw.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("field %s: expected a map, got %T", fd.Name(), raw)
+			}
+			m := msg.NewField(fd).Map()
+			for k, item := range entries {
+				key, err := treeToValue(fd.MapKey(), k, nil)
+				if err != nil {
+					return err
+				}
+				v, err := treeToValue(fd.MapValue(), item, m.NewValue)
+				if err != nil {
+					return err
+				}
+				m.Set(key.MapKey(), v)
+			}
+			msg.Set(fd, protoreflect.ValueOfMap(m))
+		default:
+			v, err := treeToValue(fd, raw, func() protoreflect.Value { return msg.NewField(fd) })
+			if err != nil {
+				return err
+			}
+			msg.Set(fd, v)
+		}
+	}
+	return nil
+}
+
+// treeToValue converts a tree value to a protobuf value of the field's kind.
+// newMessage allocates the value for message-typed fields.
+func treeToValue(fd protoreflect.FieldDescriptor, raw interface{}, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		switch v := raw.(type) {
+		case bool:
+			return protoreflect.ValueOfBool(v), nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			return protoreflect.ValueOfBool(b), err
+		}
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(formatScalar(raw)), nil
+	case protoreflect.BytesKind:
+		if s, ok := raw.(string); ok {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				b = []byte(s)
+			}
+			return protoreflect.ValueOfBytes(b), nil
+		}
+	case protoreflect.EnumKind:
+		if s, ok := raw.(string); ok {
+			if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+				return protoreflect.ValueOfEnum(ev.Number()), nil
+			}
+		}
+		if f, ok := toNumber(raw); ok {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(f)), nil
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if sub, ok := raw.(map[string]interface{}); ok && newMessage != nil {
+			v := newMessage()
+			return v, treeToMessage(sub, v.Message())
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if f, ok := toNumber(raw); ok {
+			return protoreflect.ValueOfInt32(int32(f)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if f, ok := toNumber(raw); ok {
+			return protoreflect.ValueOfInt64(int64(f)), nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if f, ok := toNumber(raw); ok {
+			return protoreflect.ValueOfUint32(uint32(f)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if f, ok := toNumber(raw); ok {
+			return protoreflect.ValueOfUint64(uint64(f)), nil
+		}
+	case protoreflect.FloatKind:
+		if f, ok := toNumber(raw); ok {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+	case protoreflect.DoubleKind:
+		if f, ok := toNumber(raw); ok {
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("field %s: cannot use %T as %s", fd.Name(), raw, fd.Kind())
+}
+
+func toNumber(raw interface{}) (float64, bool) {
+	switch v := raw.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func TestCodecRegistry_Lookup(t *testing.T) {
+	registry := NewCodecRegistry()
+
+	tests := []struct {
+		contentType string
+		want        BodyCodec
+	}{
+		{"", JSONCodec{}},
+		{"application/json; charset=utf-8", JSONCodec{}},
+		{"application/vnd.a2a+json", JSONCodec{}},
+		{"application/x-www-form-urlencoded", FormCodec{}},
+		{"multipart/form-data; boundary=xyz", MultipartCodec{}},
+		{"text/plain", nil},
+		{"application/octet-stream", nil},
+		{"not a media type;;", nil},
+	}
+
+	for _, tt := range tests {
+		if got := registry.Lookup(tt.contentType); got != tt.want {
+			t.Errorf("Lookup(%q): got %T, want %T", tt.contentType, got, tt.want)
+		}
+	}
+
+	if _, ok := registry.Lookup("application/senml+cbor").(*CBORCodec); !ok {
+		t.Error("Lookup(+cbor) should return the CBOR codec")
+	}
+}
+
+func TestJSONCodec_NotObject(t *testing.T) {
+	for _, body := range []string{"", "null", "[1,2]", `"text"`} {
+		if _, err := (JSONCodec{}).Decode([]byte(body), "application/json"); err == nil {
+			t.Errorf("Decode(%q) should fail", body)
+		}
+	}
+}
+
+func TestFormCodec_RoundTrip(t *testing.T) {
+	body := []byte("recipient=0xabc&amount=100&metadata%5Bamount%5D=5.5&tag=a&tag=b&note=007")
+
+	tree, err := FormCodec{}.Decode(body, "application/x-www-form-urlencoded")
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if tree["amount"] != 100.0 || tree["recipient"] != "0xabc" || tree["note"] != "007" {
+		t.Errorf("Unexpected scalars: %+v", tree)
+	}
+	if metadata, _ := tree["metadata"].(map[string]interface{}); metadata["amount"] != 5.5 {
+		t.Errorf("Bracketed keys should nest: %+v", tree["metadata"])
+	}
+	if tags, _ := tree["tag"].([]interface{}); len(tags) != 2 {
+		t.Errorf("Repeated keys should become a list: %+v", tree["tag"])
+	}
+
+	tree["amount"] = 10000.0
+	tree["description"] = "HACKED"
+	encoded, err := FormCodec{}.Encode(tree, body, "application/x-www-form-urlencoded")
+	if err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+
+	want := "recipient=0xabc&amount=10000&metadata%5Bamount%5D=5.5&tag=a&tag=b&note=007&description=HACKED"
+	if string(encoded) != want {
+		t.Errorf("Encode():\n got %s\nwant %s", encoded, want)
+	}
+}
+
+func multipartBody(t *testing.T) ([]byte, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	w.WriteField("amount", "100")
+	fw, _ := w.CreateFormFile("invoice", "invoice.pdf")
+	fw.Write([]byte{0x25, 0x50, 0x44, 0x46, 0x00, 0xff})
+	w.WriteField("recipient", "0xabc")
+	w.Close()
+	return buf.Bytes(), w.FormDataContentType()
+}
+
+func TestMultipartCodec_RoundTrip(t *testing.T) {
+	body, contentType := multipartBody(t)
+
+	tree, err := MultipartCodec{}.Decode(body, contentType)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if len(tree) != 2 || tree["amount"] != 100.0 || tree["recipient"] != "0xabc" {
+		t.Fatalf("Only non-file fields should be decoded: %+v", tree)
+	}
+
+	tree["amount"] = 10000.0
+	delete(tree, "recipient")
+	tree["description"] = "HACKED"
+	encoded, err := MultipartCodec{}.Encode(tree, body, contentType)
+	if err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(encoded))
+	req.Header.Set("Content-Type", contentType)
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("Encoded body is not valid multipart: %v", err)
+	}
+	if req.FormValue("amount") != "10000" || req.FormValue("description") != "HACKED" || req.FormValue("recipient") != "" {
+		t.Errorf("Unexpected fields: %v", req.MultipartForm.Value)
+	}
+
+	file, header, err := req.FormFile("invoice")
+	if err != nil {
+		t.Fatalf("File part lost: %v", err)
+	}
+	content, _ := io.ReadAll(file)
+	if header.Filename != "invoice.pdf" || !bytes.Equal(content, []byte{0x25, 0x50, 0x44, 0x46, 0x00, 0xff}) {
+		t.Errorf("File part not preserved: %s %x", header.Filename, content)
+	}
+}
+
+func TestCBORCodec_RoundTrip(t *testing.T) {
+	codec := NewCBORCodec()
+	body, _ := cbor.Marshal(map[string]interface{}{
+		"amount":   100,
+		"price":    1.5,
+		"metadata": map[string]interface{}{"amount": 7},
+	})
+
+	tree, err := codec.Decode(body, "application/cbor")
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if tree["amount"] != 100.0 || tree["price"] != 1.5 {
+		t.Errorf("Integers should decode as float64: %+v", tree)
+	}
+	if metadata, _ := tree["metadata"].(map[string]interface{}); metadata["amount"] != 7.0 {
+		t.Errorf("Nested maps should decode with string keys: %+v", tree["metadata"])
+	}
+
+	tree["amount"] = 10000.0
+	encoded, err := codec.Encode(tree, body, "application/cbor")
+	if err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	cbor.Unmarshal(encoded, &decoded)
+	if decoded["amount"] != uint64(10000) || decoded["price"] != 1.5 {
+		t.Errorf("Integral floats should be encoded as integers: %#v", decoded)
+	}
+
+	if _, err := codec.Decode([]byte{0x83, 0x01, 0x02, 0x03}, "application/cbor"); err == nil {
+		t.Error("Decode() should reject a top-level array")
+	}
+}
+
+// writeDescriptorSet writes a descriptor set with demo.Payment and demo.Status
+func writeDescriptorSet(t *testing.T) string {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	status := field("status", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM, optional)
+	status.TypeName = proto.String(".demo.Status")
+
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("demo.proto"),
+		Package: proto.String("demo"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Status"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("PENDING"), Number: proto.Int32(0)},
+				{Name: proto.String("PAID"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Payment"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("amount", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional),
+				field("recipient", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional),
+				field("tags", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, repeated),
+				status,
+			},
+		}},
+	}}}
+
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("marshal descriptor set: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "demo.pb")
+	os.WriteFile(path, data, 0o644)
+	return path
+}
+
+// paymentProto encodes demo.Payment{amount: 100, recipient: "0xabc",
+// tags: ["express-delivery"], status: PAID} plus an unknown field 99
+func paymentProto() []byte {
+	b := []byte{
+		0x08, 0x64, // amount = 100
+		0x12, 0x05, '0', 'x', 'a', 'b', 'c', // recipient
+		0x1a, 0x10, // tags
+	}
+	b = append(b, "express-delivery"...)
+	return append(b,
+		0x20, 0x01, // status = PAID
+		0x98, 0x06, 0x01, // field 99 (unknown) = 1
+	)
+}
+
+func TestProtobufCodec_RoundTrip(t *testing.T) {
+	codec, err := NewProtobufCodec(writeDescriptorSet(t), "demo.Payment")
+	if err != nil {
+		t.Fatalf("NewProtobufCodec() error: %v", err)
+	}
+
+	body := paymentProto()
+	tree, err := codec.Decode(body, "application/x-protobuf")
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if tree["amount"] != 100.0 || tree["recipient"] != "0xabc" || tree["status"] != "PAID" {
+		t.Errorf("Unexpected tree: %+v", tree)
+	}
+
+	tree["amount"] = 10000.0
+	tree["recipient"] = "0xATTACKER"
+	tree["description"] = "not in schema"
+	encoded, err := codec.Encode(tree, body, "application/x-protobuf")
+	if err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+
+	roundTrip, _ := codec.Decode(encoded, "application/x-protobuf")
+	if roundTrip["amount"] != 10000.0 || roundTrip["recipient"] != "0xATTACKER" || roundTrip["status"] != "PAID" {
+		t.Errorf("Unexpected re-encoded message: %+v", roundTrip)
+	}
+	if !bytes.HasSuffix(encoded, []byte{0x98, 0x06, 0x01}) {
+		t.Errorf("Unknown field should be preserved: %x", encoded)
+	}
+}
+
+func TestProtobufCodec_MessageType(t *testing.T) {
+	path := writeDescriptorSet(t)
+
+	if _, err := NewProtobufCodec(path, "demo.Missing"); err == nil {
+		t.Error("NewProtobufCodec() should reject an unknown default type")
+	}
+	if _, err := NewProtobufCodec(filepath.Join(t.TempDir(), "missing.pb"), ""); err == nil {
+		t.Error("NewProtobufCodec() should fail for a missing file")
+	}
+
+	codec, _ := NewProtobufCodec(path, "")
+	if _, err := codec.Decode(paymentProto(), "application/x-protobuf"); err == nil {
+		t.Error("Decode() should fail without a message type")
+	}
+	if _, err := codec.Decode(paymentProto(), "application/x-protobuf; proto=demo.Payment"); err != nil {
+		t.Errorf("Decode() with proto parameter: %v", err)
+	}
+	if _, err := codec.Decode(paymentProto(), "application/x-protobuf; messageType=demo.Status"); err == nil {
+		t.Error("Decode() should reject an enum as message type")
+	}
+}
+
+func TestProxyHandler_PriceAttack_AcrossCodecs(t *testing.T) {
+	var received []byte
+	var receivedType string
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		receivedType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockTarget.Close()
+
+	cfg := &config.Config{
+		AttackEnabled:      true,
+		AttackType:         types.AttackTypePriceManipulation,
+		TargetAgentURL:     mockTarget.URL,
+		PriceMultiplier:    100.0,
+		AttackerWallet:     "0xATTACKER",
+		ProtoDescriptorSet: writeDescriptorSet(t),
+		ProtoMessageType:   "demo.Payment",
+	}
+	handler := NewProxyHandler(cfg)
+
+	cborBody, _ := cbor.Marshal(map[string]interface{}{"amount": 100, "recipient": "0xabc"})
+	multipart, multipartType := multipartBody(t)
+
+	tests := []struct {
+		contentType string
+		body        []byte
+	}{
+		{"application/x-www-form-urlencoded", []byte("amount=100&recipient=0xabc")},
+		{multipartType, multipart},
+		{"application/cbor", cborBody},
+		{"application/x-protobuf", paymentProto()},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.SplitN(tt.contentType, ";", 2)[0], func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/payment", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			handler.HandleRequest(httptest.NewRecorder(), req)
+
+			if receivedType != tt.contentType {
+				t.Errorf("Content-Type changed: got %s", receivedType)
+			}
+			tree, err := handler.interceptor.Codecs().Lookup(tt.contentType).Decode(received, tt.contentType)
+			if err != nil {
+				t.Fatalf("Forwarded body cannot be decoded: %v", err)
+			}
+			if tree["amount"] != 10000.0 || tree["recipient"] != "0xATTACKER" {
+				t.Errorf("Price attack not applied: %+v", tree)
+			}
+		})
+	}
+}
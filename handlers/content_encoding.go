@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// maxDecompressedSize bounds the decoded size of a compressed body
+const maxDecompressedSize = 64 << 20
+
+// ErrUnsupportedEncoding is returned for a Content-Encoding the gateway
+// cannot undo; such bodies are forwarded untouched
+var ErrUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// contentEncoding returns the Content-Encoding of a request, combining
+// repeated header lines
+func contentEncoding(r *http.Request) string {
+	return strings.Join(r.Header.Values("Content-Encoding"), ",")
+}
+
+// contentCodings lists the codings of a Content-Encoding header in the order
+// they were applied, without identity
+func contentCodings(header string) []string {
+	var codings []string
+	for _, coding := range strings.Split(header, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
+// DecompressBody undoes the gzip, deflate, br and zstd codings listed in a
+// Content-Encoding header
+func DecompressBody(header string, body []byte) ([]byte, error) {
+	codings := contentCodings(header)
+	for i := len(codings) - 1; i >= 0; i-- {
+		var reader io.Reader
+		var err error
+		switch codings[i] {
+		case "gzip", "x-gzip":
+			reader, err = gzip.NewReader(bytes.NewReader(body))
+		case "deflate":
+			reader, err = zlib.NewReader(bytes.NewReader(body))
+		case "br":
+			reader = brotli.NewReader(bytes.NewReader(body))
+		case "zstd":
+			var dec *zstd.Decoder
+			dec, err = zstd.NewReader(bytes.NewReader(body))
+			if err == nil {
+				defer dec.Close()
+				reader = dec
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, codings[i])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode %s body: %w", codings[i], err)
+		}
+
+		decoded, err := io.ReadAll(io.LimitReader(reader, maxDecompressedSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("decode %s body: %w", codings[i], err)
+		}
+		if len(decoded) > maxDecompressedSize {
+			return nil, fmt.Errorf("decoded %s body exceeds %d bytes", codings[i], maxDecompressedSize)
+		}
+		body = decoded
+	}
+	return body, nil
+}
+
+// CompressBody applies the codings listed in a Content-Encoding header again
+func CompressBody(header string, body []byte) ([]byte, error) {
+	for _, coding := range contentCodings(header) {
+		var buf bytes.Buffer
+		var writer io.WriteCloser
+		switch coding {
+		case "gzip", "x-gzip":
+			writer = gzip.NewWriter(&buf)
+		case "deflate":
+			writer = zlib.NewWriter(&buf)
+		case "br":
+			writer = brotli.NewWriter(&buf)
+		case "zstd":
+			enc, err := zstd.NewWriter(&buf)
+			if err != nil {
+				return nil, err
+			}
+			writer = enc
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, coding)
+		}
+
+		if _, err := writer.Write(body); err != nil {
+			return nil, fmt.Errorf("encode %s body: %w", coding, err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("encode %s body: %w", coding, err)
+		}
+		body = buf.Bytes()
+	}
+	return body, nil
+}
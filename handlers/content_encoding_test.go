@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCompressBody_RoundTrip(t *testing.T) {
+	body := []byte(`{"amount":100,"recipient":"0xabc"}`)
+
+	for _, encoding := range []string{"", "identity", "gzip", "x-gzip", "deflate", "br", "zstd", "gzip, br"} {
+		t.Run(encoding, func(t *testing.T) {
+			encoded, err := CompressBody(encoding, body)
+			if err != nil {
+				t.Fatalf("CompressBody() error: %v", err)
+			}
+			if encoding != "" && encoding != "identity" && bytes.Equal(encoded, body) {
+				t.Error("CompressBody() returned the body unencoded")
+			}
+
+			decoded, err := DecompressBody(encoding, encoded)
+			if err != nil {
+				t.Fatalf("DecompressBody() error: %v", err)
+			}
+			if !bytes.Equal(decoded, body) {
+				t.Errorf("Round trip: got %s, want %s", decoded, body)
+			}
+		})
+	}
+}
+
+func TestDecompressBody_Errors(t *testing.T) {
+	if _, err := DecompressBody("compress", []byte("x")); !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Errorf("Expected ErrUnsupportedEncoding, got %v", err)
+	}
+	if _, err := DecompressBody("gzip", []byte("not gzip")); err == nil {
+		t.Error("Expected an error for a corrupt gzip body")
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/report"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// The uncovered_components attack changes only what the signature checked by
+// verifiers (A2AStatus.Signature) leaves out, so the signature stays valid. A
+// nil signature covers nothing.
+
+// coversBody reports whether the body is bound to the signature through a
+// covered digest
+func coversBody(sig *sage.SignatureParams) bool {
+	return sig != nil && (sig.Covers("content-digest") || sig.Covers("repr-digest"))
+}
+
+// coversPath reports whether the request path is covered, directly or as
+// part of the target URI
+func coversPath(sig *sage.SignatureParams) bool {
+	return sig != nil && (sig.Covers("@path") || sig.Covers("@target-uri") || sig.Covers("@request-target"))
+}
+
+// coversQueryParam reports whether a query parameter is covered
+func coversQueryParam(sig *sage.SignatureParams, name string) bool {
+	return sig != nil && (sig.CoversQueryParam(name) || sig.Covers("@target-uri") || sig.Covers("@request-target"))
+}
+
+// TamperUncovered sets the COVERAGE_HEADERS, COVERAGE_PATH and COVERAGE_QUERY
+// values on r wherever sig does not cover them and returns what was changed
+func TamperUncovered(r *http.Request, sig *sage.SignatureParams, cfg *config.Config) []types.Change {
+	var changes []types.Change
+
+	headers, err := config.ParseHeaderList(cfg.CoverageHeaders)
+	if err != nil {
+		logger.Warn("Ignoring COVERAGE_HEADERS: %v", err)
+	}
+	for _, name := range sortedKeys(headers) {
+		values := headers[name]
+		if sig != nil && sig.Covers(name) {
+			logger.Info("🛡️  Header %s is covered by the signature - leaving it untouched", name)
+			continue
+		}
+		old := strings.Join(r.Header.Values(name), ", ")
+		r.Header[name] = values
+		changes = append(changes, types.Change{
+			Field: "header " + name, OriginalValue: old, ModifiedValue: strings.Join(values, ", "),
+		})
+	}
+
+	if path := cfg.CoveragePath; path != "" && path != r.URL.Path {
+		if coversPath(sig) {
+			logger.Info("🛡️  @path is covered by the signature - leaving it untouched")
+		} else {
+			changes = append(changes, types.Change{Field: "@path", OriginalValue: r.URL.Path, ModifiedValue: path})
+			r.URL.Path, r.URL.RawPath = path, ""
+		}
+	}
+
+	params, err := url.ParseQuery(cfg.CoverageQuery)
+	if err != nil {
+		logger.Warn("Ignoring COVERAGE_QUERY: %v", err)
+	}
+	if len(params) > 0 {
+		query := r.URL.Query()
+		changed := false
+		for _, name := range sortedKeys(params) {
+			values := params[name]
+			if coversQueryParam(sig, name) {
+				logger.Info("🛡️  Query parameter %s is covered by the signature - leaving it untouched", name)
+				continue
+			}
+			changes = append(changes, types.Change{
+				Field: "@query-param " + name, OriginalValue: query.Get(name), ModifiedValue: strings.Join(values, ", "),
+			})
+			query[name] = values
+			changed = true
+		}
+		if changed {
+			r.URL.RawQuery = query.Encode()
+		}
+	}
+
+	return changes
+}
+
+// uncoveredStep is uncovered_components: the body is tampered with by
+// COVERAGE_BODY_TAMPER only when the signature covers no digest, and headers,
+// path and query of the request are changed on top (see TamperRequest).
+type uncoveredStep struct {
+	tamper *bodyStep
+	config *config.Config
+}
+
+func (s *uncoveredStep) Apply(msg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{}) {
+	if coversBody(a2aStatus.Signature) {
+		logger.Info("🛡️  Body is covered by the signature (content-digest) - leaving it untouched")
+		return nil, msg
+	}
+	if a2aStatus.HPKEEnabled {
+		logger.Info("🔐 Body is HPKE-encrypted - leaving it untouched")
+		return nil, msg
+	}
+	attackLog, modifiedMsg := s.tamper.tamper(msg, a2aStatus, "🎯 SAGE signature does not cover content-digest - body changes keep it valid")
+	if attackLog != nil {
+		attackLog.AttackType = string(types.AttackTypeUncoveredComponents)
+	}
+	return attackLog, modifiedMsg
+}
+
+func (s *uncoveredStep) Warn(attackLog *types.AttackLog, a2aStatus *A2AStatus) {
+	logger.Warn("⚠️  Only components the signature does not cover are changed - it stays valid")
+}
+
+func (s *uncoveredStep) Headers(outReq *http.Request, body []byte, msg map[string]interface{}, attackLog *types.AttackLog, a2aStatus *A2AStatus) []types.HeaderChange {
+	// Verifiers check a present digest even when it is not covered
+	return RewriteHeaders(outReq.Header, body, DigestRecompute)
+}
+
+// TamperRequest applies TamperUncovered to the outgoing request, whatever its
+// body, and merges the changes into the attack log of the body, creating one
+// if needed
+func (s *uncoveredStep) TamperRequest(ctx context.Context, outReq *http.Request, a2aStatus *A2AStatus, attackLog *types.AttackLog) (*types.AttackLog, error) {
+	changes := TamperUncovered(outReq, a2aStatus.Signature, s.config)
+	if len(changes) > 0 {
+		if attackLog == nil {
+			attackLog = &types.AttackLog{
+				Timestamp:      time.Now(),
+				AttackType:     string(types.AttackTypeUncoveredComponents),
+				TargetEndpoint: s.config.GetTargetURL(),
+			}
+		}
+		attackLog.Changes = append(attackLog.Changes, changes...)
+	}
+
+	if a2aStatus.Signature != nil && attackLog != nil && len(attackLog.Changes) > 0 {
+		fields := make([]string, len(attackLog.Changes))
+		for i, change := range attackLog.Changes {
+			fields[i] = change.Field
+		}
+		logger.Warn("🎯 Signature still valid, but changed: %s", strings.Join(fields, ", "))
+	}
+	return attackLog, nil
+}
+
+// NeedsBody is false: headers, path and query change whatever the body
+func (s *uncoveredStep) NeedsBody() bool {
+	return false
+}
+
+// uncoveredFinding explains uncovered_components by what changed without
+// breaking the signature
+func uncoveredFinding(e *report.Entry) string {
+	if !e.Protection.SAGE {
+		return ""
+	}
+	fields := strings.Join(e.ChangedFields(), ", ")
+	switch e.Upstream.Outcome {
+	case report.OutcomeAccepted:
+		return "signature still valid, but " + fields + " changed: the signing profile does not cover them"
+	case report.OutcomeRejected:
+		return "only uncovered components changed (" + fields + "), and the receiver rejected the message"
+	}
+	return ""
+}
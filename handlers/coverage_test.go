@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func coverageConfig(targetURL string) *config.Config {
+	return &config.Config{
+		AttackEnabled:   true,
+		AttackType:      types.AttackTypeUncoveredComponents,
+		TargetAgentURL:  targetURL,
+		PriceMultiplier: 100.0,
+		AttackerWallet:  "0xATTACKER",
+		CoverageHeaders: "X-Forwarded-User: admin",
+		CoveragePath:    "/internal/approve",
+		CoverageQuery:   "role=admin",
+	}
+}
+
+func TestTamperUncovered(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []string
+		want       []string
+	}{
+		{"unsigned", nil, []string{"header X-Forwarded-User", "@path", "@query-param role"}},
+		{"default profile", sage.DefaultRequestComponents, []string{"header X-Forwarded-User"}},
+		{"header covered", []string{"@method", "@target-uri", "x-forwarded-user"}, nil},
+		{"single query param", []string{"@method", "@path", `@query-param;name="id"`}, []string{"header X-Forwarded-User", "@query-param role"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/payment?id=7", nil)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Forwarded-User", "alice")
+			var sig *sage.SignatureParams
+			if tt.components != nil {
+				var err error
+				sig, err = sage.SignRequest(req, nil, sage.DemoKeyPair("root"), &sage.SignOptions{Components: tt.components})
+				if err != nil {
+					t.Fatalf("SignRequest() error: %v", err)
+				}
+			}
+
+			changes := TamperUncovered(req, sig, coverageConfig(""))
+
+			if len(changes) != len(tt.want) {
+				t.Fatalf("Changes: got %+v, want fields %v", changes, tt.want)
+			}
+			for i, field := range tt.want {
+				if changes[i].Field != field {
+					t.Errorf("Change %d: got %s, want %s", i, changes[i].Field, field)
+				}
+			}
+			if sig != nil {
+				if _, err := (&sage.Verifier{}).VerifyRequest(req, nil); err != nil {
+					t.Errorf("Signature should stay valid: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyHandler_UncoveredComponents(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []string
+		amount     float64
+		path       string
+	}{
+		// content-digest and @path are covered: only the unsigned header changes
+		{"default profile", nil, 100, "/payment"},
+		// no digest covered: the body is fair game as well
+		{"body not covered", []string{"@method", "content-type"}, 10000, "/internal/approve"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct {
+				verified bool
+				amount   interface{}
+				path     string
+				user     string
+			}
+			target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				_, err := (&sage.Verifier{}).VerifyRequest(r, body)
+				var msg types.AgentMessage
+				json.Unmarshal(body, &msg)
+				got.verified, got.amount, got.path, got.user = err == nil, msg.Metadata["amount"], r.URL.Path, r.Header.Get("X-Forwarded-User")
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer target.Close()
+			handler := NewProxyHandler(coverageConfig(target.URL))
+
+			body, _ := json.Marshal(types.AgentMessage{
+				ID: "msg-1", From: "root", To: "payment", Type: "request",
+				Metadata: map[string]interface{}{"amount": 100.0, "recipient": "0xVENDOR"},
+			})
+			req := httptest.NewRequest("POST", "/payment", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if _, err := sage.SignRequest(req, body, sage.DemoKeyPair("root"), &sage.SignOptions{Components: tt.components}); err != nil {
+				t.Fatalf("SignRequest() error: %v", err)
+			}
+			handler.HandleRequest(httptest.NewRecorder(), req)
+
+			if !got.verified {
+				t.Error("Signature should still verify at the target")
+			}
+			if got.amount != tt.amount || got.path != tt.path || got.user != "admin" {
+				t.Errorf("Target got amount %v, path %s, user %q; want %v, %s, admin", got.amount, got.path, got.user, tt.amount, tt.path)
+			}
+
+			entry := handler.Recorder().Entries()[0]
+			if entry.Scenario != string(types.AttackTypeUncoveredComponents) || entry.Verdict() != "attack succeeded" {
+				t.Errorf("Entry: got scenario %s, verdict %s", entry.Scenario, entry.Verdict())
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// defaultBodyBufferLimit is used when BODY_BUFFER_LIMIT is 0
+const defaultBodyBufferLimit = 10 << 20
+
+// exchangeKey carries the *exchange of a request through the reverse proxy
+type exchangeKey struct{}
+
+// exchange carries the state of one proxied request through the reverse
+// proxy hooks, for the report and the HAR capture
+type exchange struct {
+	start        time.Time
+	request      *http.Request
+	body         []byte // nil when the request body was streamed
+	streamed     *recordingBody
+	originalMsg  map[string]interface{}
+	target       *url.URL
+	targetURL    string
+	route        *RouteMatch
+	attackLog    *types.AttackLog
+	activation   *types.Activation // nil when attack mode is off or cannot apply
+	a2aStatus    *A2AStatus
+	forwardReq   *http.Request
+	forwardBody  []byte
+	forwardStart time.Time
+	wait         time.Duration
+	attempts     []types.UpstreamAttempt
+}
+
+// attacking reports whether the attack fired for this exchange
+func (ex *exchange) attacking() bool {
+	return ex.activation != nil && ex.activation.Fired
+}
+
+func exchangeFrom(ctx context.Context) *exchange {
+	ex, _ := ctx.Value(exchangeKey{}).(*exchange)
+	return ex
+}
+
+// newReverseProxy builds the forwarding core. The retrying client is the
+// transport; hop-by-hop headers are stripped by httputil.ReverseProxy.
+func (p *ProxyHandler) newReverseProxy() *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      p.client,
+		FlushInterval:  time.Duration(p.config.StreamFlushInterval) * time.Millisecond,
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler:   p.handleForwardError,
+	}
+}
+
+// bufferLimit returns the largest request body buffered for inspection
+func (p *ProxyHandler) bufferLimit() int64 {
+	if p.config.BodyBufferLimit > 0 {
+		return int64(p.config.BodyBufferLimit)
+	}
+	return defaultBodyBufferLimit
+}
+
+// needsBody reports whether the request body has to be buffered. Only bodies
+// a codec can decode are inspected, and only when an attack may tamper with
+// them or routing reads their "to" or metadata fields; anything else is
+// streamed to the target.
+func (p *ProxyHandler) needsBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return false
+	}
+	if p.interceptor.Codecs().Lookup(r.Header.Get("Content-Type")) == nil {
+		return false
+	}
+	return p.modifier.ShouldModify() || p.router.NeedsBody(r)
+}
+
+// setRequestBody replaces the body of an outgoing request
+func setRequestBody(r *http.Request, body []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+// rewrite points the outgoing request at the exchange target, keeping the
+// path and query, and adds X-Forwarded-* and Forwarded (RFC 7239) headers
+func (p *ProxyHandler) rewrite(pr *httputil.ProxyRequest) {
+	ex := exchangeFrom(pr.In.Context())
+	pr.SetURL(ex.target)
+	// Extend, rather than replace, the chain of earlier proxies
+	pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+	pr.SetXForwarded()
+	pr.Out.Header.Set("Forwarded", forwardedHeader(pr.In))
+	ex.forwardReq = pr.Out
+}
+
+// forwardedHeader appends this hop to the inbound Forwarded header
+func forwardedHeader(r *http.Request) string {
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+
+	hop := "for=" + forwardedNode(r.RemoteAddr) + ";host=" + quoteForwarded(r.Host) + ";proto=" + proto
+	if prior := r.Header.Values("Forwarded"); len(prior) > 0 {
+		return strings.Join(prior, ", ") + ", " + hop
+	}
+	return hop
+}
+
+// forwardedNode formats a client address as a Forwarded node: IPv6
+// addresses are bracketed and quoted, unknown addresses are "unknown"
+func forwardedNode(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "unknown"
+	case ip.To4() == nil:
+		return `"[` + ip.String() + `]"`
+	}
+	return ip.String()
+}
+
+// quoteForwarded quotes a Forwarded value unless it is a plain token
+func quoteForwarded(v string) string {
+	for _, c := range v {
+		if !(c == '-' || c == '.' || c == '_' || c == '~' ||
+			'0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+		}
+	}
+	return v
+}
+
+// modifyResponse tees the response body as it streams to the client so the
+// outcome can be recorded once it has been relayed
+func (p *ProxyHandler) modifyResponse(resp *http.Response) error {
+	ex := exchangeFrom(resp.Request.Context())
+	ex.wait = time.Since(ex.forwardStart)
+	logger.Info("Response from target agent: %d %s", resp.StatusCode, resp.Status)
+
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		limit:      p.bufferLimit(),
+		done: func(body []byte, err error) {
+			p.finishExchange(ex, resp, body, err)
+		},
+	}
+	return nil
+}
+
+// finishExchange records a relayed response for the report and the capture
+func (p *ProxyHandler) finishExchange(ex *exchange, resp *http.Response, body []byte, err error) {
+	if err != nil {
+		logger.Error("Failed to relay response from target: %v", err)
+	}
+	logger.Debug("Response body: %s", string(body))
+
+	receive := time.Since(ex.forwardStart) - ex.wait
+	p.captureExchange(ex, resp, body, ex.wait, receive, err)
+	p.recordOutcome(ex, resp.StatusCode, body)
+}
+
+// handleForwardError answers 502 when the target cannot be reached, or 503
+// when its circuit breaker is open
+func (p *ProxyHandler) handleForwardError(w http.ResponseWriter, r *http.Request, err error) {
+	ex := exchangeFrom(r.Context())
+	p.recordOutcome(ex, 0, []byte(err.Error()))
+	p.captureExchange(ex, nil, nil, time.Since(ex.forwardStart), 0, err)
+
+	var openErr *CircuitOpenError
+	if errors.As(err, &openErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+		http.Error(w, "Target agent unavailable (circuit breaker open)", http.StatusServiceUnavailable)
+		return
+	}
+	logger.Error("Failed to forward request to target: %v", err)
+	http.Error(w, "Failed to reach target agent", http.StatusBadGateway)
+}
+
+// recordingBody keeps the first limit bytes of a streamed body and calls
+// done, if set, once the body hits EOF, fails or is closed
+type recordingBody struct {
+	io.ReadCloser
+	limit int64
+	size  int64
+	buf   bytes.Buffer
+	once  sync.Once
+	done  func(body []byte, err error)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if room := b.limit - int64(b.buf.Len()); room > 0 {
+		b.buf.Write(p[:min(int64(n), room)])
+	}
+	switch {
+	case err == io.EOF:
+		b.finish(nil)
+	case err != nil:
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish(nil)
+	return err
+}
+
+func (b *recordingBody) finish(err error) {
+	b.once.Do(func() {
+		if b.done != nil {
+			b.done(b.buf.Bytes(), err)
+		}
+	})
+}
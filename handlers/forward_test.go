@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func TestProxyHandler_ForwardingHeaders(t *testing.T) {
+	var received http.Header
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Header().Set("Connection", "X-Upstream-Hop")
+		w.Header().Set("X-Upstream-Hop", "1")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockTarget.Close()
+
+	handler := NewProxyHandler(&config.Config{TargetAgentURL: mockTarget.URL})
+
+	req := httptest.NewRequest("GET", "http://gateway.example/tasks/1", nil)
+	req.Header.Set("Connection", "X-Hop")
+	req.Header.Set("X-Hop", "secret")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("Forwarded", "for=203.0.113.7")
+	w := httptest.NewRecorder()
+
+	handler.HandleRequest(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("HandleRequest() status code: got %d, want %d", w.Code, http.StatusOK)
+	}
+	for _, name := range []string{"X-Hop", "Keep-Alive"} {
+		if v := received.Get(name); v != "" {
+			t.Errorf("Hop-by-hop header %s forwarded: %q", name, v)
+		}
+	}
+	if got := w.Header().Get("X-Upstream-Hop"); got != "" {
+		t.Errorf("Hop-by-hop response header relayed: %q", got)
+	}
+	if got, want := received.Get("X-Forwarded-For"), "203.0.113.7, 192.0.2.1"; got != want {
+		t.Errorf("X-Forwarded-For: got %q, want %q", got, want)
+	}
+	if got, want := received.Get("Forwarded"), "for=203.0.113.7, for=192.0.2.1;host=gateway.example;proto=http"; got != want {
+		t.Errorf("Forwarded: got %q, want %q", got, want)
+	}
+}
+
+func TestForwardedNode(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.1:1234":     "192.0.2.1",
+		"[2001:db8::1]:8080": `"[2001:db8::1]"`,
+		"@":                  "unknown",
+	}
+	for addr, want := range tests {
+		if got := forwardedNode(addr); got != want {
+			t.Errorf("forwardedNode(%q) = %s, want %s", addr, got, want)
+		}
+	}
+	if got := quoteForwarded("localhost:8090"); got != `"localhost:8090"` {
+		t.Errorf("quoteForwarded() = %s", got)
+	}
+}
+
+func TestProxyHandler_StreamsBodyAboveBufferLimit(t *testing.T) {
+	var received []byte
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockTarget.Close()
+
+	cfg := &config.Config{
+		AttackEnabled:   true,
+		AttackType:      types.AttackTypePriceManipulation,
+		TargetAgentURL:  mockTarget.URL,
+		PriceMultiplier: 100.0,
+		BodyBufferLimit: 16,
+	}
+	handler := NewProxyHandler(cfg)
+
+	body := `{"amount":100,"recipient":"0xabc"}`
+	for _, chunked := range []bool{false, true} {
+		req := httptest.NewRequest("POST", "/payment", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+
+		handler.HandleRequest(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("HandleRequest() status code: got %d, want %d", w.Code, http.StatusOK)
+		}
+		if string(received) != body {
+			t.Errorf("chunked=%v: body above the limit should be streamed untouched, got %s", chunked, received)
+		}
+
+		entry := handler.Capture().Last()
+		if entry.Attack != nil {
+			t.Errorf("chunked=%v: streamed body should not be tampered", chunked)
+		}
+		if entry.Request.BodySize != len(body) {
+			t.Errorf("chunked=%v: captured body size %d, want %d", chunked, entry.Request.BodySize, len(body))
+		}
+		if got, _ := entry.Request.Body(); !bytes.Equal(got, []byte(body)[:16]) {
+			t.Errorf("chunked=%v: captured body should be cut at the limit, got %s", chunked, got)
+		}
+	}
+}
+
+func TestProxyHandler_StreamsResponse(t *testing.T) {
+	release := make(chan struct{})
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "data: second\n\n")
+	}))
+	defer mockTarget.Close()
+
+	handler := NewProxyHandler(&config.Config{TargetAgentURL: mockTarget.URL, StreamFlushInterval: -1})
+	gateway := httptest.NewServer(http.HandlerFunc(handler.HandleRequest))
+	defer gateway.Close()
+
+	resp, err := http.Get(gateway.URL + "/events")
+	if err != nil {
+		t.Fatalf("GET through gateway: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The first event must arrive while the target is still writing
+	lines := make(chan string)
+	go func() {
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		lines <- line
+	}()
+	select {
+	case line := <-lines:
+		if line != "data: first\n" {
+			t.Errorf("First streamed line: got %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Response was not streamed before the target finished")
+	}
+	close(release)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// Digest policies (DIGEST_POLICY) for a tampered body
+const (
+	DigestRecompute = "recompute" // replace the digest with one of the modified body
+	DigestStrip     = "strip"     // remove the digest header
+	DigestKeep      = "keep"      // forward the stale digest unchanged
+)
+
+// Header rewrite actions recorded in types.HeaderChange
+const (
+	HeaderSet        = "set"
+	HeaderRecomputed = "recomputed"
+	HeaderStripped   = "stripped"
+	HeaderResigned   = "resigned"
+)
+
+// digestHeaders are the RFC 9530 integrity fields computed over the body as
+// sent, i.e. after content coding
+var digestHeaders = []string{"Content-Digest", "Repr-Digest"}
+
+// RewriteHeaders brings the headers of a request whose body was replaced in
+// line with the new body (as sent on the wire) and returns what was changed.
+// Content-Length is recomputed; digest headers follow digestPolicy, with ""
+// meaning DigestRecompute.
+func RewriteHeaders(header http.Header, body []byte, digestPolicy string) []types.HeaderChange {
+	var changes []types.HeaderChange
+
+	length := strconv.Itoa(len(body))
+	if old := header.Get("Content-Length"); old != length {
+		header.Set("Content-Length", length)
+		changes = append(changes, types.HeaderChange{
+			Header: "Content-Length", Action: HeaderSet, OriginalValue: old, ModifiedValue: length,
+		})
+	}
+
+	for _, name := range digestHeaders {
+		old := strings.Join(header.Values(name), ", ")
+		if old == "" {
+			continue
+		}
+		switch digestPolicy {
+		case DigestKeep:
+		case DigestStrip:
+			header.Del(name)
+			changes = append(changes, types.HeaderChange{
+				Header: name, Action: HeaderStripped, OriginalValue: old,
+			})
+		default:
+			digest := sage.RecomputeDigest(old, body)
+			header.Set(name, digest)
+			changes = append(changes, types.HeaderChange{
+				Header: name, Action: HeaderRecomputed, OriginalValue: old, ModifiedValue: digest,
+			})
+		}
+	}
+
+	return changes
+}
+
+// signatureHeaders are the RFC 9421 fields removed by the strip_signature
+// attack, together with the digest headers they usually cover
+var signatureHeaders = []string{"Signature", "Signature-Input"}
+
+// StripSignature removes the RFC 9421 signature and the digest headers so a
+// tampered request looks like an unsigned one, and returns what was removed.
+// A receiver that only verifies signatures when present accepts the result.
+func StripSignature(header http.Header) []types.HeaderChange {
+	var changes []types.HeaderChange
+	for _, name := range append(append([]string{}, signatureHeaders...), digestHeaders...) {
+		old := strings.Join(header.Values(name), ", ")
+		if old == "" {
+			continue
+		}
+		header.Del(name)
+		changes = append(changes, types.HeaderChange{
+			Header: name, Action: HeaderStripped, OriginalValue: old,
+		})
+	}
+	return changes
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func TestRewriteHeaders(t *testing.T) {
+	original := []byte(`{"amount":100}`)
+	modified := []byte(`{"amount":10000}`)
+
+	tests := []struct {
+		policy  string
+		digest  string
+		actions []string
+	}{
+		{"", sage.ContentDigest(modified), []string{HeaderSet, HeaderRecomputed}},
+		{DigestRecompute, sage.ContentDigest(modified), []string{HeaderSet, HeaderRecomputed}},
+		{DigestStrip, "", []string{HeaderSet, HeaderStripped}},
+		{DigestKeep, sage.ContentDigest(original), []string{HeaderSet}},
+	}
+
+	for _, tt := range tests {
+		t.Run("policy="+tt.policy, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Content-Length", strconv.Itoa(len(original)))
+			header.Set("Content-Digest", sage.ContentDigest(original))
+
+			changes := RewriteHeaders(header, modified, tt.policy)
+
+			if got := header.Get("Content-Length"); got != strconv.Itoa(len(modified)) {
+				t.Errorf("Content-Length: got %q, want %d", got, len(modified))
+			}
+			if got := header.Get("Content-Digest"); got != tt.digest {
+				t.Errorf("Content-Digest: got %q, want %q", got, tt.digest)
+			}
+			if len(changes) != len(tt.actions) {
+				t.Fatalf("Expected %d header changes, got %+v", len(tt.actions), changes)
+			}
+			for i, action := range tt.actions {
+				if changes[i].Action != action {
+					t.Errorf("Change %d: got action %s, want %s", i, changes[i].Action, action)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteHeaders_Unchanged(t *testing.T) {
+	body := []byte("same")
+	header := http.Header{}
+	header.Set("Content-Length", "4")
+
+	if changes := RewriteHeaders(header, body, DigestRecompute); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
+
+func TestStripSignature(t *testing.T) {
+	body := []byte(`{"amount":100}`)
+	req := httptest.NewRequest("POST", "/payment", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := sage.SignRequest(req, body, sage.DemoKeyPair("root"), nil); err != nil {
+		t.Fatalf("SignRequest() error: %v", err)
+	}
+
+	changes := StripSignature(req.Header)
+
+	for _, name := range []string{"Signature", "Signature-Input", "Content-Digest"} {
+		if req.Header.Get(name) != "" {
+			t.Errorf("%s should be removed", name)
+		}
+	}
+	if req.Header.Get("Content-Type") != "application/json" {
+		t.Error("Unrelated headers should be kept")
+	}
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 stripped headers, got %+v", changes)
+	}
+	for _, change := range changes {
+		if change.Action != HeaderStripped || change.OriginalValue == "" {
+			t.Errorf("Unexpected change: %+v", change)
+		}
+	}
+
+	if changes := StripSignature(http.Header{}); len(changes) != 0 {
+		t.Errorf("Unsigned request: expected no changes, got %+v", changes)
+	}
+}
+
+func TestProxyHandler_PriceAttack_EncodedBody(t *testing.T) {
+	for _, encoding := range []string{"gzip", "br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			var received []byte
+			var receivedHeader http.Header
+			mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received, _ = io.ReadAll(r.Body)
+				receivedHeader = r.Header.Clone()
+				receivedHeader.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer mockTarget.Close()
+
+			handler := NewProxyHandler(&config.Config{
+				AttackEnabled:   true,
+				AttackType:      types.AttackTypePriceManipulation,
+				TargetAgentURL:  mockTarget.URL,
+				PriceMultiplier: 100.0,
+			})
+
+			body, err := CompressBody(encoding, []byte(`{"amount":100,"recipient":"0xabc"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest("POST", "/payment", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Content-Encoding", encoding)
+			req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			req.Header.Set("Content-Digest", sage.ContentDigest(body))
+			w := httptest.NewRecorder()
+
+			handler.HandleRequest(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("HandleRequest() status code: got %d, want %d", w.Code, http.StatusOK)
+			}
+			if got := receivedHeader.Get("Content-Encoding"); got != encoding {
+				t.Errorf("Content-Encoding: got %q, want %q", got, encoding)
+			}
+			if got := receivedHeader.Get("Content-Length"); got != strconv.Itoa(len(received)) {
+				t.Errorf("Content-Length: got %s, want %d", got, len(received))
+			}
+			if err := sage.VerifyContentDigest(receivedHeader.Get("Content-Digest"), received); err != nil {
+				t.Errorf("Content-Digest should match the forwarded body: %v", err)
+			}
+
+			plain, err := DecompressBody(encoding, received)
+			if err != nil {
+				t.Fatalf("Forwarded body is not %s encoded: %v", encoding, err)
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(plain, &msg); err != nil {
+				t.Fatalf("Forwarded body is not JSON: %v", err)
+			}
+			if msg["amount"] != 10000.0 {
+				t.Errorf("Amount: got %v, want 10000", msg["amount"])
+			}
+
+			entry := handler.Capture().Last()
+			if entry.Attack == nil || len(entry.Attack.HeaderChanges) != 2 {
+				t.Fatalf("Expected Content-Length and Content-Digest header changes in the attack log")
+			}
+		})
+	}
+}
@@ -2,19 +2,32 @@ package handlers
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
 )
 
+// ErrNoBodyCodec is returned by InterceptRequest when the body was read but
+// no codec can decode it (empty, JSON array, binary, unknown content type...).
+// Such requests are forwarded untouched.
+var ErrNoBodyCodec = errors.New("body cannot be decoded by any codec")
+
 // MessageInterceptor intercepts and parses HTTP messages
-type MessageInterceptor struct{}
+type MessageInterceptor struct {
+	codecs *CodecRegistry
+}
 
-// NewMessageInterceptor creates a new message interceptor
+// NewMessageInterceptor creates a new message interceptor with the built-in codecs
 func NewMessageInterceptor() *MessageInterceptor {
-	return &MessageInterceptor{}
+	return &MessageInterceptor{codecs: NewCodecRegistry()}
+}
+
+// Codecs returns the codec registry, e.g. to register the protobuf codec
+func (i *MessageInterceptor) Codecs() *CodecRegistry {
+	return i.codecs
 }
 
 // InterceptRequest reads and parses the incoming request
@@ -32,10 +45,8 @@ func (i *MessageInterceptor) InterceptRequest(r *http.Request) (map[string]inter
 	// Restore the body for further processing
 	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
-	// Parse JSON
-	var message map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &message); err != nil {
-		logger.Error("Failed to parse JSON: %v", err)
+	message, err := i.DecodeBody(r, bodyBytes)
+	if err != nil {
 		return nil, bodyBytes, err
 	}
 
@@ -43,17 +54,96 @@ func (i *MessageInterceptor) InterceptRequest(r *http.Request) (map[string]inter
 	return message, bodyBytes, nil
 }
 
+// BufferRequest reads the request body into memory if it is at most limit
+// bytes. Larger bodies are left for streaming: ok is false and r.Body still
+// yields the complete body, including the bytes read while probing.
+func (i *MessageInterceptor) BufferRequest(r *http.Request, limit int64) (body []byte, ok bool, err error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+	if r.ContentLength > limit {
+		return nil, false, nil
+	}
+
+	body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		logger.Error("Failed to read request body: %v", err)
+		return nil, false, err
+	}
+	if int64(len(body)) > limit {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false, nil
+	}
+
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true, nil
+}
+
+// DecodeBody decodes a buffered body with the codec for the request
+// Content-Type, undoing any Content-Encoding first. It returns
+// ErrNoBodyCodec if the body cannot be decoded.
+func (i *MessageInterceptor) DecodeBody(r *http.Request, body []byte) (map[string]interface{}, error) {
+	contentType := r.Header.Get("Content-Type")
+	codec := i.codecs.Lookup(contentType)
+	if codec == nil {
+		return nil, fmt.Errorf("%w: no codec for %q", ErrNoBodyCodec, contentType)
+	}
+	plain, err := DecompressBody(contentEncoding(r), body)
+	if err != nil {
+		logger.Debug("Body could not be decompressed: %v", err)
+		return nil, fmt.Errorf("%w: %v", ErrNoBodyCodec, err)
+	}
+	message, err := codec.Decode(plain, contentType)
+	if err != nil {
+		logger.Debug("Body could not be decoded as %q: %v", contentType, err)
+		return nil, fmt.Errorf("%w: %v", ErrNoBodyCodec, err)
+	}
+	return message, nil
+}
+
+// EncodeBody encodes a modified message in the wire format of the original
+// body, falling back to JSON when the Content-Type has no codec, and applies
+// the original Content-Encoding again
+func (i *MessageInterceptor) EncodeBody(r *http.Request, msg map[string]interface{}, original []byte) ([]byte, error) {
+	contentType := r.Header.Get("Content-Type")
+	codec := i.codecs.Lookup(contentType)
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	encoding := contentEncoding(r)
+	plain, err := DecompressBody(encoding, original)
+	if err != nil {
+		return nil, err
+	}
+	body, err := codec.Encode(msg, plain, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return CompressBody(encoding, body)
+}
+
 // CreateModifiedRequest creates a new HTTP request with modified message
+//
+// Deprecated: ProxyHandler forwards through its reverse proxy; use EncodeBody
 func (i *MessageInterceptor) CreateModifiedRequest(originalReq *http.Request, modifiedMsg map[string]interface{}, targetURL string) (*http.Request, error) {
-	// Marshal modified message to JSON
-	modifiedBody, err := json.Marshal(modifiedMsg)
+	// Encode the modified message in the original body format
+	var originalBody []byte
+	if originalReq.Body != nil {
+		originalBody, _ = io.ReadAll(originalReq.Body)
+		originalReq.Body = io.NopCloser(bytes.NewReader(originalBody))
+	}
+	modifiedBody, err := i.EncodeBody(originalReq, modifiedMsg, originalBody)
 	if err != nil {
-		logger.Error("Failed to marshal modified message: %v", err)
+		logger.Error("Failed to encode modified message: %v", err)
 		return nil, err
 	}
 
 	// Create new request with modified body
-	newReq, err := http.NewRequest(originalReq.Method, targetURL+originalReq.URL.Path, bytes.NewBuffer(modifiedBody))
+	newReq, err := http.NewRequest(originalReq.Method, targetURL+originalReq.URL.RequestURI(), bytes.NewBuffer(modifiedBody))
 	if err != nil {
 		logger.Error("Failed to create new request: %v", err)
 		return nil, err
@@ -66,15 +156,16 @@ func (i *MessageInterceptor) CreateModifiedRequest(originalReq *http.Request, mo
 		}
 	}
 
-	// Update Content-Length
-	newReq.Header.Set("Content-Length", string(rune(len(modifiedBody))))
-	newReq.ContentLength = int64(len(modifiedBody))
+	// Bring Content-Length and digests in line with the new body
+	RewriteHeaders(newReq.Header, modifiedBody, DigestRecompute)
 
-	logger.Debug("Created modified request to: %s", targetURL+originalReq.URL.Path)
+	logger.Debug("Created modified request to: %s", newReq.URL)
 	return newReq, nil
 }
 
 // ForwardOriginalRequest forwards the original request without modification
+//
+// Deprecated: ProxyHandler forwards through its reverse proxy
 func (i *MessageInterceptor) ForwardOriginalRequest(originalReq *http.Request, targetURL string) (*http.Request, error) {
 	// Read original body
 	bodyBytes, err := io.ReadAll(originalReq.Body)
@@ -84,7 +175,7 @@ func (i *MessageInterceptor) ForwardOriginalRequest(originalReq *http.Request, t
 	originalReq.Body.Close()
 
 	// Create new request with same body
-	newReq, err := http.NewRequest(originalReq.Method, targetURL+originalReq.URL.Path, bytes.NewBuffer(bodyBytes))
+	newReq, err := http.NewRequest(originalReq.Method, targetURL+originalReq.URL.RequestURI(), bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +187,6 @@ func (i *MessageInterceptor) ForwardOriginalRequest(originalReq *http.Request, t
 		}
 	}
 
-	logger.Debug("Forwarding original request to: %s", targetURL+originalReq.URL.Path)
+	logger.Debug("Forwarding original request to: %s", newReq.URL)
 	return newReq, nil
 }
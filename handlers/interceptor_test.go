@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 )
 
@@ -69,8 +71,8 @@ func TestInterceptRequest_InvalidJSON(t *testing.T) {
 	message, bodyBytes, err := interceptor.InterceptRequest(req)
 
 	// Should return error for invalid JSON
-	if err == nil {
-		t.Error("InterceptRequest() should return error for invalid JSON")
+	if !errors.Is(err, ErrNoBodyCodec) {
+		t.Errorf("InterceptRequest() should return ErrNoBodyCodec for invalid JSON, got %v", err)
 	}
 
 	// Message should be nil
@@ -159,6 +161,11 @@ func TestCreateModifiedRequest(t *testing.T) {
 		t.Fatalf("Failed to read new request body: %v", err)
 	}
 
+	// Check Content-Length is the decimal body length
+	if got, want := newReq.Header.Get("Content-Length"), strconv.Itoa(len(body)); got != want {
+		t.Errorf("Content-Length header: got %q, want %q", got, want)
+	}
+
 	if !bytes.Contains(body, []byte("10000")) {
 		t.Error("New request body doesn't contain modified amount")
 	}
@@ -219,6 +226,22 @@ func TestForwardOriginalRequest(t *testing.T) {
 	}
 }
 
+func TestForwardOriginalRequest_PreservesQuery(t *testing.T) {
+	interceptor := NewMessageInterceptor()
+
+	originalReq := httptest.NewRequest("GET", "/tasks/42?historyLength=5", nil)
+
+	newReq, err := interceptor.ForwardOriginalRequest(originalReq, "http://localhost:8091")
+	if err != nil {
+		t.Fatalf("ForwardOriginalRequest() error: %v", err)
+	}
+
+	expectedURL := "http://localhost:8091/tasks/42?historyLength=5"
+	if newReq.URL.String() != expectedURL || newReq.Method != "GET" {
+		t.Errorf("Forwarded request: got %s %s, want GET %s", newReq.Method, newReq.URL, expectedURL)
+	}
+}
+
 func TestCreateModifiedRequest_InvalidMessage(t *testing.T) {
 	interceptor := NewMessageInterceptor()
 
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"time"
+
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/attacks"
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
@@ -14,24 +16,54 @@ type MessageModifier struct {
 	addressAttack   *attacks.AddressAttack
 	productAttack   *attacks.ProductAttack
 	encryptedAttack *attacks.EncryptedAttack
+	downgradeAttack *attacks.DowngradeAttack
+	spoofAttack     *attacks.SpoofAttack
+	injectionAttack *attacks.InjectionAttack
+	salamiAttack    *attacks.SalamiAttack
+	activator       *Activator
+	step            attackStep // the configured attack, nil when unknown
 }
 
 // NewMessageModifier creates a new message modifier
 func NewMessageModifier(cfg *config.Config) *MessageModifier {
-	return &MessageModifier{
+	activator := NewActivator(cfg)
+	if cfg.IsAttackEnabled() && cfg.IsActivationConditional() {
+		logger.Info("🎲 Attack activation seed: %d (set ATTACK_SEED to reproduce this run)", activator.Seed())
+	}
+	m := &MessageModifier{
 		config:          cfg,
 		priceAttack:     attacks.NewPriceAttack(cfg),
 		addressAttack:   attacks.NewAddressAttack(cfg),
 		productAttack:   attacks.NewProductAttack(cfg),
 		encryptedAttack: attacks.NewEncryptedAttack(cfg),
+		downgradeAttack: attacks.NewDowngradeAttack(cfg),
+		spoofAttack:     attacks.NewSpoofAttack(cfg),
+		injectionAttack: attacks.NewInjectionAttack(cfg),
+		salamiAttack:    attacks.NewSalamiAttack(cfg),
+		activator:       activator,
 	}
+	m.step = m.newStep(cfg.GetAttackType())
+	return m
 }
 
-// ShouldModify determines if the message should be modified
+// ShouldModify determines if messages may be modified at all; whether the
+// attack fires for a given message is decided by Activate
 func (m *MessageModifier) ShouldModify() bool {
 	return m.config.IsAttackEnabled()
 }
 
+// Activate decides whether the attack fires for a message received at now,
+// from ATTACK_PROBABILITY, ATTACK_RATE_LIMIT and ATTACK_SCHEDULE; nil when
+// attack mode is off. Call it once per message the attack can apply to: every
+// call takes a draw and fired calls count toward the rate limit.
+func (m *MessageModifier) Activate(now time.Time) *types.Activation {
+	if !m.ShouldModify() {
+		return nil
+	}
+	activation := m.activator.Decide(now)
+	return &activation
+}
+
 // ModifyMessage modifies the message based on configured attack type
 // Deprecated: Use ModifyMessageWithA2A for A2A-aware attack branching
 func (m *MessageModifier) ModifyMessage(originalMsg map[string]interface{}) (*types.AttackLog, map[string]interface{}) {
@@ -71,67 +103,52 @@ func (m *MessageModifier) ModifyMessage(originalMsg map[string]interface{}) (*ty
 
 // ModifyMessageWithA2A modifies the message based on A2A protocol state
 // This method implements state-based attack branching:
-// - SAGE OFF: Normal JSON modification
-// - SAGE ON + HPKE OFF: JSON modification (will invalidate signature)
-// - SAGE ON + HPKE ON: Bit-flip attack on encrypted payload
+//   - SAGE OFF: Normal JSON modification
+//   - SAGE ON + HPKE OFF: JSON modification (will invalidate signature)
+//   - SAGE ON + HPKE ON: Bit-flip attack on encrypted payload, or with
+//     hpke_downgrade the payload is replaced by forged plaintext
+//
+// The configured attack is applied by its attackStep (see attackSteps).
 func (m *MessageModifier) ModifyMessageWithA2A(originalMsg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{}) {
 	if !m.ShouldModify() {
 		logger.Info("Attack disabled - message will pass through unmodified")
 		return nil, originalMsg
 	}
-
-	// Branch based on A2A protocol state
-	if a2aStatus.HPKEEnabled {
-		// HPKE is enabled - use bit-flip attack on encrypted payload
-		logger.Info("🔐 HPKE detected - applying encrypted payload bit-flip attack")
-		attackLog, modifiedMsg := m.encryptedAttack.ModifyMessage(originalMsg)
-		if attackLog != nil {
-			attackLog.TargetEndpoint = m.config.GetTargetURL()
-		}
-		return attackLog, modifiedMsg
-	}
-
-	// No HPKE - apply normal JSON modification attack
-	attackType := m.config.GetAttackType()
-	logger.Info("📝 No HPKE - applying JSON modification attack: %s", attackType)
-
-	if a2aStatus.SAGEEnabled {
-		logger.Warn("⚠️  SAGE signature detected - JSON modification will invalidate signature")
-	}
-
-	var attackLog *types.AttackLog
-	var modifiedMsg map[string]interface{}
-
-	switch attackType {
-	case types.AttackTypePriceManipulation:
-		attackLog, modifiedMsg = m.priceAttack.ModifyMessage(originalMsg)
-
-	case types.AttackTypeAddressManipulation:
-		attackLog, modifiedMsg = m.addressAttack.ModifyMessage(originalMsg)
-
-	case types.AttackTypeProductSubstitution:
-		attackLog, modifiedMsg = m.productAttack.ModifyMessage(originalMsg)
-
-	default:
-		logger.Warn("Unknown attack type: %s, passing message through", attackType)
+	if m.step == nil {
+		logger.Warn("Unknown attack type: %s, passing message through", m.config.GetAttackType())
 		return nil, originalMsg
 	}
+	if conditional, ok := m.step.(conditionalStep); ok {
+		if reason := conditional.Skip(a2aStatus); reason != "" {
+			logger.Info("%s does not apply: %s - passing message through", m.config.GetAttackType(), reason)
+			return nil, originalMsg
+		}
+	}
 
-	// Set target endpoint in attack log
+	attackLog, modifiedMsg := m.step.Apply(originalMsg, a2aStatus)
 	if attackLog != nil {
 		attackLog.TargetEndpoint = m.config.GetTargetURL()
 	}
-
 	return attackLog, modifiedMsg
 }
 
+// ActsWithoutBody reports whether the configured attack also applies to
+// messages whose body is streamed or has no codec, as uncovered_components
+// and stale_signature do: their step acts on the request itself.
+func (m *MessageModifier) ActsWithoutBody() bool {
+	rs, ok := m.step.(requestStep)
+	return ok && !rs.NeedsBody()
+}
+
 // GetAttackSummary returns a summary of the attack configuration
 func (m *MessageModifier) GetAttackSummary() map[string]interface{} {
+	profile := m.config.GetAttackerProfile(m.config.GetAttackType())
 	return map[string]interface{}{
-		"attack_enabled":    m.config.IsAttackEnabled(),
-		"attack_type":       string(m.config.GetAttackType()),
-		"target_url":        m.config.GetTargetURL(),
-		"price_multiplier":  m.config.PriceMultiplier,
-		"attacker_wallet":   m.config.AttackerWallet,
+		"attack_enabled":   m.config.IsAttackEnabled(),
+		"attack_type":      string(m.config.GetAttackType()),
+		"target_url":       m.config.GetTargetURL(),
+		"price_multiplier": m.config.PriceMultiplier,
+		"attacker_profile": profile.Name,
+		"attacker_wallet":  profile.Wallet,
 	}
 }
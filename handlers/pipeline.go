@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/report"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// Pipeline step statuses (types.PipelineStep.Status)
+const (
+	StepApplied = "applied"
+	StepSkipped = "skipped"
+)
+
+// RunsPipeline reports whether the configured attack is an ATTACK_PIPELINE
+func (m *MessageModifier) RunsPipeline() bool {
+	return m.config.GetAttackType() == types.AttackTypePipeline
+}
+
+// chainedStep is one ATTACK_PIPELINE step, nil when its attack cannot be chained
+type chainedStep struct {
+	attackType types.AttackType
+	step       attackStep
+}
+
+// pipelineStep returns the step of the pipeline attack, which runs the steps
+// of the chained attacks
+func (m *MessageModifier) pipelineStep() *pipelineAttackStep {
+	s := &pipelineAttackStep{config: m.config}
+	for _, attackType := range m.config.AttackPipeline {
+		chained := chainedStep{attackType: attackType}
+		if newStep := attackSteps[attackType]; newStep != nil {
+			chained.step = newStep(m)
+		}
+		s.steps = append(s.steps, chained)
+	}
+	return s
+}
+
+// runPipeline applies the ATTACK_PIPELINE steps in order, each to the output
+// of the previous one, and merges their changes into one AttackLog with every
+// change attributed to its step.
+//
+// A step does not apply when its attack skips the message or finds nothing to
+// change. With PIPELINE_ON_SKIP=continue such a step is recorded as skipped;
+// with abort the original message is forwarded untouched. Once a step leaves
+// no HPKE payload (hpke_downgrade), the following steps see plaintext.
+func (s *pipelineAttackStep) runPipeline(originalMsg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{}) {
+	merged := &types.AttackLog{
+		Timestamp:      time.Now(),
+		AttackType:     string(types.AttackTypePipeline),
+		OriginalMsg:    originalMsg,
+		Changes:        []types.Change{},
+		TargetEndpoint: s.config.GetTargetURL(),
+	}
+
+	// Some attacks change nested maps in place; work on a copy so an abort
+	// really forwards the original
+	msg := cloneMessage(originalMsg)
+	status := a2aStatus
+	for _, chained := range s.steps {
+		stepLog, stepMsg, reason := chained.apply(msg, status)
+		if stepLog == nil {
+			logger.Info("⛓️  Pipeline step %s skipped: %s", chained.attackType, reason)
+			merged.Steps = append(merged.Steps, types.PipelineStep{AttackType: string(chained.attackType), Status: StepSkipped, Reason: reason})
+			if s.config.GetPipelineOnSkip() == config.PipelineOnSkipAbort {
+				logger.Warn("⛓️  Pipeline aborted at step %s - forwarding the original message untouched", chained.attackType)
+				return nil, originalMsg
+			}
+			continue
+		}
+
+		logger.Info("⛓️  Pipeline step %s applied (%d change(s))", chained.attackType, len(stepLog.Changes))
+		merged.Steps = append(merged.Steps, types.PipelineStep{AttackType: string(chained.attackType), Status: StepApplied})
+		mergePipelineStep(merged, stepLog, chained.attackType)
+		msg = stepMsg
+		if status.HPKEEnabled && !hpkePayload(msg) {
+			plaintext := *status
+			plaintext.HPKEEnabled = false
+			status = &plaintext
+		}
+	}
+
+	merged.ModifiedMsg = msg
+	return merged, msg
+}
+
+// apply runs the step on msg as if it were the configured attack; it returns
+// a nil log and the reason when the step does not apply. A step that acts on
+// the request later applies with an empty log.
+func (c chainedStep) apply(msg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{}, string) {
+	if c.step == nil {
+		return nil, msg, "cannot be chained"
+	}
+	if conditional, ok := c.step.(conditionalStep); ok {
+		if reason := conditional.Skip(a2aStatus); reason != "" {
+			return nil, msg, reason
+		}
+	}
+
+	attackLog, modifiedMsg := c.step.Apply(msg, a2aStatus)
+	if attackLog != nil && len(attackLog.Changes) > 0 {
+		return attackLog, modifiedMsg, ""
+	}
+	if _, ok := c.step.(requestStep); ok {
+		return &types.AttackLog{}, msg, ""
+	}
+	return nil, msg, "nothing to change in this message"
+}
+
+// mergePipelineStep appends the changes of one step to a pipeline log,
+// attributed to the step
+func mergePipelineStep(merged, stepLog *types.AttackLog, step types.AttackType) {
+	for _, change := range stepLog.Changes {
+		change.Step = string(step)
+		merged.Changes = append(merged.Changes, change)
+	}
+	merged.HeaderChanges = append(merged.HeaderChanges, stepLog.HeaderChanges...)
+}
+
+// cloneMessage deep-copies the maps and slices of a decoded JSON message
+func cloneMessage(msg map[string]interface{}) map[string]interface{} {
+	clone := make(map[string]interface{}, len(msg))
+	for k, v := range msg {
+		clone[k] = cloneValue(v)
+	}
+	return clone
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return cloneMessage(v)
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneValue(item)
+		}
+		return clone
+	default:
+		return v
+	}
+}
+
+// pipelineSummary lists the applied steps, e.g. "strip_signature -> price_manipulation"
+func pipelineSummary(steps []types.PipelineStep) string {
+	var applied []string
+	for _, step := range steps {
+		if step.Status == StepApplied {
+			applied = append(applied, step.AttackType)
+		}
+	}
+	return strings.Join(applied, " -> ")
+}
+
+// pipelineAttackStep is pipeline: the ATTACK_PIPELINE steps run in order
+// (see runPipeline), and so do their headers and their actions on the request
+type pipelineAttackStep struct {
+	steps  []chainedStep
+	config *config.Config
+}
+
+func (s *pipelineAttackStep) Apply(msg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{}) {
+	return s.runPipeline(msg, a2aStatus)
+}
+
+func (s *pipelineAttackStep) Warn(attackLog *types.AttackLog, a2aStatus *A2AStatus) {
+	logger.Warn("⚠️  Attack pipeline applied: %s - the receiver sees all steps at once", pipelineSummary(attackLog.Steps))
+}
+
+// applied returns the steps that applied to the message of attackLog
+func (s *pipelineAttackStep) applied(attackLog *types.AttackLog) []chainedStep {
+	var applied []chainedStep
+	for i, step := range attackLog.Steps {
+		if i < len(s.steps) && step.Status == StepApplied {
+			applied = append(applied, s.steps[i])
+		}
+	}
+	return applied
+}
+
+func (s *pipelineAttackStep) Headers(outReq *http.Request, body []byte, msg map[string]interface{}, attackLog *types.AttackLog, a2aStatus *A2AStatus) []types.HeaderChange {
+	var changes []types.HeaderChange
+	for _, chained := range s.applied(attackLog) {
+		changes = mergeHeaderChanges(changes, chained.step.Headers(outReq, body, msg, attackLog, a2aStatus))
+	}
+	return changes
+}
+
+// mergeHeaderChanges adds the header changes of a later step to those of the
+// earlier ones, so each header is listed once with its original and final
+// value, and drops the changes that turned out to be no-ops
+func mergeHeaderChanges(changes, later []types.HeaderChange) []types.HeaderChange {
+	for _, change := range later {
+		merged := false
+		for i := range changes {
+			if changes[i].Header == change.Header {
+				changes[i].Action = change.Action
+				changes[i].ModifiedValue = change.ModifiedValue
+				merged = true
+			}
+		}
+		if !merged {
+			changes = append(changes, change)
+		}
+	}
+
+	kept := changes[:0]
+	for _, change := range changes {
+		if change.Action == HeaderStripped || change.OriginalValue != change.ModifiedValue {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+func (s *pipelineAttackStep) TamperRequest(ctx context.Context, outReq *http.Request, a2aStatus *A2AStatus, attackLog *types.AttackLog) (*types.AttackLog, error) {
+	// An aborted pipeline forwards the original request as well
+	if attackLog == nil {
+		return nil, nil
+	}
+	for _, chained := range s.applied(attackLog) {
+		rs, ok := chained.step.(requestStep)
+		if !ok {
+			continue
+		}
+		stepLog, err := rs.TamperRequest(ctx, outReq, a2aStatus, nil)
+		if err != nil {
+			return attackLog, err
+		}
+		if stepLog != nil {
+			mergePipelineStep(attackLog, stepLog, chained.attackType)
+		}
+	}
+	return attackLog, nil
+}
+
+// NeedsBody is true: a pipeline only runs on decoded messages
+func (s *pipelineAttackStep) NeedsBody() bool {
+	return true
+}
+
+// pipelineFinding explains pipeline by the steps that applied and were skipped
+func pipelineFinding(e *report.Entry) string {
+	var applied, skipped []string
+	for _, step := range e.Steps {
+		if step.Status == StepSkipped {
+			skipped = append(skipped, step.AttackType+" ("+step.Reason+")")
+		} else {
+			applied = append(applied, step.AttackType)
+		}
+	}
+	note := ""
+	if len(skipped) > 0 {
+		note = "; skipped: " + strings.Join(skipped, ", ")
+	}
+	switch e.Upstream.Outcome {
+	case report.OutcomeAccepted:
+		return "pipeline " + strings.Join(applied, " -> ") + " reached the receiver" + note
+	case report.OutcomeRejected:
+		return "pipeline " + strings.Join(applied, " -> ") + " was rejected by the receiver" + note
+	}
+	return ""
+}
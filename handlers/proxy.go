@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"io"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
 
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/capture"
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/report"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/tlsutil"
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
 )
 
@@ -16,6 +23,12 @@ type ProxyHandler struct {
 	interceptor *MessageInterceptor
 	modifier    *MessageModifier
 	client      *RetryableHTTPClient
+	recorder    *report.Recorder
+	capture     *capture.Recorder
+	router      *Router
+	balancer    *Balancer
+	proxy       *httputil.ReverseProxy
+	background  sync.WaitGroup // exchanges sent after the client's, see Wait
 }
 
 // NewProxyHandler creates a new proxy handler
@@ -24,37 +37,114 @@ func NewProxyHandler(cfg *config.Config) *ProxyHandler {
 		MaxRetries:  cfg.MaxRetries,
 		BackoffBase: cfg.RetryBackoffBase,
 		HTTPTimeout: cfg.HTTPTimeout,
+
+		RetryStatuses:      cfg.RetryStatusCodes,
+		RetryNonIdempotent: cfg.RetryNonIdempotent,
+		Budget:             time.Duration(cfg.RetryBudget) * time.Second,
+		BodyBufferLimit:    int64(cfg.BodyBufferLimit),
+		TLS:                upstreamTLSConfig(cfg),
+	}
+	if cfg.BreakerEnabled {
+		retryConfig.Breaker = &BreakerConfig{
+			FailureRatio: cfg.BreakerFailureRatio,
+			MinRequests:  cfg.BreakerMinRequests,
+			Window:       time.Duration(cfg.BreakerWindow) * time.Second,
+			CoolDown:     time.Duration(cfg.BreakerCoolDown) * time.Second,
+		}
+	}
+
+	interceptor := NewMessageInterceptor()
+	if cfg.ProtoDescriptorSet != "" {
+		codec, err := NewProtobufCodec(cfg.ProtoDescriptorSet, cfg.ProtoMessageType)
+		if err != nil {
+			logger.Error("Protobuf codec disabled: %v", err)
+		} else {
+			for _, mediaType := range ProtobufMediaTypes {
+				interceptor.Codecs().Register(mediaType, codec)
+			}
+		}
 	}
 
-	return &ProxyHandler{
+	p := &ProxyHandler{
 		config:      cfg,
-		interceptor: NewMessageInterceptor(),
+		interceptor: interceptor,
 		modifier:    NewMessageModifier(cfg),
 		client:      NewRetryableHTTPClient(retryConfig),
+		recorder:    report.NewRecorder(cfg.ReportMaxEntries, Finding),
+		capture:     capture.NewRecorder(cfg.CaptureMaxEntries),
+		balancer:    NewBalancer(cfg),
+	}
+	p.router = NewRouter(cfg, p.balancer)
+	p.proxy = p.newReverseProxy()
+	return p
+}
+
+// Recorder returns the recorder correlating attacks with upstream outcomes
+func (p *ProxyHandler) Recorder() *report.Recorder {
+	return p.recorder
+}
+
+// Balancer returns the load balancer of agents with several replicas
+func (p *ProxyHandler) Balancer() *Balancer {
+	return p.balancer
+}
+
+// Capture returns the recorder holding proxied exchanges for HAR export
+func (p *ProxyHandler) Capture() *capture.Recorder {
+	return p.capture
+}
+
+// Wait blocks until the exchanges the gateway sends on its own, such as
+// identity_spoofing duplicates, are done, or until ctx is
+func (p *ProxyHandler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
 // HandleRequest is the main proxy handler
 func (p *ProxyHandler) HandleRequest(w http.ResponseWriter, r *http.Request) {
-	logger.Info("Incoming request: %s %s", r.Method, r.URL.Path)
+	start := time.Now()
+	logger.Info("Incoming request: %s %s", r.Method, r.URL.RequestURI())
 
-	// Only handle POST requests for now
-	if r.Method != http.MethodPost {
-		logger.Warn("Method not allowed: %s", r.Method)
-		http.Error(w, "Only POST requests are supported", http.StatusMethodNotAllowed)
-		return
+	// Buffer and decode the body only when it has to be inspected; any other
+	// body, or one larger than the buffer limit, is streamed untouched
+	var originalMsg map[string]interface{}
+	var rawBody []byte
+	passthrough, streamed := true, true
+	if p.needsBody(r) {
+		body, ok, err := p.interceptor.BufferRequest(r, p.bufferLimit())
+		if err != nil {
+			logger.Error("Failed to intercept request: %v", err)
+			http.Error(w, "Failed to process request", http.StatusBadRequest)
+			return
+		}
+		if ok {
+			rawBody, streamed = body, false
+			originalMsg, err = p.interceptor.DecodeBody(r, body)
+			passthrough = err != nil
+		}
 	}
 
-	// Intercept and parse the request
-	originalMsg, rawBody, err := p.interceptor.InterceptRequest(r)
-	if err != nil {
-		logger.Error("Failed to intercept request: %v", err)
-		http.Error(w, "Failed to process request", http.StatusBadRequest)
-		return
+	if passthrough {
+		if streamed {
+			logger.Info("Passthrough: streaming %s body unmodified (%s)", r.Method, contentTypeOrNone(r))
+		} else {
+			logger.Info("Passthrough: %s body has no codec, forwarding unmodified (%d bytes, %s)",
+				r.Method, len(rawBody), contentTypeOrNone(r))
+		}
+	} else {
+		logger.Debug("Original message: %+v", originalMsg)
 	}
 
-	logger.Debug("Original message: %+v", originalMsg)
-
 	// Detect A2A protocol (SAGE + HPKE)
 	a2aStatus := DetectA2AProtocol(r, rawBody)
 	logger.Info("Protocol detection: %s", a2aStatus.GetStatusString())
@@ -72,105 +162,204 @@ func (p *ProxyHandler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		logger.Info("✅ HPKE encrypted payload detected")
 	}
 
-	// Try to parse as AgentMessage to extract "To" field for dynamic routing
-	var agentMsg types.AgentMessage
-	var targetURL string
+	// Pick the target: routing table, then the AgentMessage "to" field (in
+	// any body format), then the default route
+	route := p.router.Route(r, originalMsg)
+	targetURL := route.TargetURL
+	logger.LogRoute(r.Method, r.URL.RequestURI(), route.Route, route.Reason, targetURL)
 
-	if err := json.Unmarshal(rawBody, &agentMsg); err == nil && agentMsg.To != "" {
-		// Dynamic routing based on "To" field
-		targetURL = p.config.GetAgentURL(agentMsg.To)
-		if targetURL == "" {
-			logger.Warn("Unknown agent in 'to' field: %s, falling back to default target", agentMsg.To)
-			targetURL = p.config.GetTargetURL()
-		} else {
-			logger.Info("Dynamic routing: message to '%s' -> %s", agentMsg.To, targetURL)
-		}
-	} else {
-		// Fallback to legacy TARGET_AGENT_URL if "To" field not found
-		targetURL = p.config.GetTargetURL()
-		logger.Debug("Using legacy target URL: %s", targetURL)
+	target, err := url.Parse(targetURL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		logger.Error("Invalid target URL %q: %v", targetURL, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	var forwardReq *http.Request
+	ex := &exchange{
+		start:       start,
+		request:     r,
+		body:        rawBody,
+		originalMsg: originalMsg,
+		target:      target,
+		targetURL:   targetURL,
+		route:       route,
+		a2aStatus:   a2aStatus,
+		forwardBody: rawBody,
+	}
+	// Only messages the attack can apply to take a draw and a rate-limit slot
+	if !passthrough || p.modifier.ActsWithoutBody() {
+		ex.activation = p.modifier.Activate(start)
+	}
+	if ex.activation != nil && p.config.IsActivationConditional() {
+		logger.LogActivation(ex.activation)
+	}
+	outReq := r.Clone(context.WithValue(r.Context(), exchangeKey{}, ex))
+	if route.StripPrefix != "" {
+		stripPathPrefix(outReq.URL, route.StripPrefix)
+	}
+	if !streamed {
+		setRequestBody(outReq, rawBody)
+	} else if outReq.Body != nil && outReq.Body != http.NoBody {
+		// Keep a bounded copy of the streamed body for the capture
+		ex.streamed = &recordingBody{ReadCloser: outReq.Body, limit: p.bufferLimit()}
+		outReq.Body = ex.streamed
+	}
 
-	// Check if attack is enabled
-	if p.modifier.ShouldModify() {
+	// Check if attack is enabled and the body can be tampered with
+	if ex.attacking() && !passthrough {
 		// Apply A2A-aware attack modification
 		attackLog, modifiedMsg := p.modifier.ModifyMessageWithA2A(originalMsg, a2aStatus)
+		ex.attackLog = attackLog
 
 		if attackLog != nil && len(attackLog.Changes) > 0 {
-			// Additional warnings for encrypted payloads
-			if a2aStatus.SAGEEnabled && a2aStatus.HPKEEnabled {
-				logger.Warn("⚠️  Target agent will REJECT this request due to:")
-				logger.Warn("   - Signature verification failure (signature invalidated)")
-				logger.Warn("   - HPKE decryption failure (integrity check will fail)")
-			} else if a2aStatus.SAGEEnabled {
-				logger.Warn("⚠️  Target agent will REJECT this request due to signature verification failure")
-			} else if a2aStatus.HPKEEnabled {
-				logger.Warn("⚠️  Target agent will FAIL to decrypt this message (HPKE integrity broken)")
-			}
-
-			// Log the attack
-			logger.LogAttack(attackLog)
+			// The attack's step tells how the receiver should react and
+			// which headers change along with the body
+			step := p.modifier.step
+			step.Warn(attackLog, a2aStatus)
 
-			// Create modified request
-			forwardReq, err = p.interceptor.CreateModifiedRequest(r, modifiedMsg, targetURL)
+			// Re-encode the modified message in the original body format and
+			// bring the headers in line with it
+			modifiedBody, err := p.interceptor.EncodeBody(r, modifiedMsg, rawBody)
 			if err != nil {
-				logger.Error("Failed to create modified request: %v", err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				return
-			}
-		} else {
-			// No modifications made, forward original
-			forwardReq, err = p.interceptor.ForwardOriginalRequest(r, targetURL)
-			if err != nil {
-				logger.Error("Failed to forward request: %v", err)
+				logger.Error("Failed to encode modified message: %v", err)
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
 				return
 			}
+			setRequestBody(outReq, modifiedBody)
+			attackLog.HeaderChanges = step.Headers(outReq, modifiedBody, modifiedMsg, attackLog, a2aStatus)
+			ex.forwardBody = modifiedBody
 		}
-	} else {
-		// Attack disabled, forward original request
+	} else if !passthrough && ex.activation != nil {
+		logger.Info("Forwarding original message (attack not activated: %s)", ex.activation.Reason)
+	} else if !passthrough {
 		logger.Info("Forwarding original message (attack disabled)")
-		forwardReq, err = p.interceptor.ForwardOriginalRequest(r, targetURL)
+	}
+
+	// Some attacks act on the request itself, some even on bodies they
+	// cannot decode: uncovered_components changes headers, path and query,
+	// stale_signature holds the message
+	if rs, ok := p.modifier.step.(requestStep); ok && ex.attacking() && (!passthrough || !rs.NeedsBody()) {
+		attackLog, err := rs.TamperRequest(r.Context(), outReq, a2aStatus, ex.attackLog)
 		if err != nil {
-			logger.Error("Failed to forward request: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			logger.Warn("Client gave up before the request was forwarded: %v", err)
 			return
 		}
+		ex.attackLog = attackLog
 	}
 
-	// Forward the request to target agent
-	logger.Info("Forwarding request to: %s%s", targetURL, r.URL.Path)
-	resp, err := p.client.Do(forwardReq)
-	if err != nil {
-		logger.Error("Failed to forward request to target: %v", err)
-		http.Error(w, "Failed to reach target agent", http.StatusBadGateway)
-		return
+	// Log the attack
+	if ex.attackLog != nil && len(ex.attackLog.Changes) > 0 {
+		ex.attackLog.Activation = ex.activation
+		logger.LogAttack(ex.attackLog)
 	}
-	defer resp.Body.Close()
 
-	// Read response from target
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Error("Failed to read response from target: %v", err)
-		http.Error(w, "Failed to read response", http.StatusInternalServerError)
-		return
+	// Forward the request to target agent; the response is streamed back
+	logger.Info("Forwarding request to: %s%s", targetURL, outReq.URL.RequestURI())
+	if route.Backend != nil {
+		route.Backend.acquire()
+		defer route.Backend.release()
+	}
+	ex.forwardStart = time.Now()
+	p.proxy.ServeHTTP(w, outReq)
+
+	// Some attacks send messages of their own once the client has its answer,
+	// e.g. identity_spoofing in duplicate mode replays a copy into another
+	// conversation
+	if fs, ok := p.modifier.step.(followupStep); ok && ex.attacking() && !passthrough {
+		fs.Followup(p, r, ex)
+	}
+}
+
+// recordOutcome correlates the exchange with the upstream response and stores
+// it for the SAGE ON/OFF report. statusCode 0 means the target was unreachable.
+func (p *ProxyHandler) recordOutcome(ex *exchange, statusCode int, body []byte) {
+	upstream := &types.UpstreamResult{
+		StatusCode: statusCode,
+		Body:       string(body),
+		Outcome:    report.ClassifyOutcome(statusCode, body),
 	}
 
-	logger.Info("Response from target agent: %d %s", resp.StatusCode, resp.Status)
-	logger.Debug("Response body: %s", string(respBody))
+	protection := report.Protection{SAGE: ex.a2aStatus.SAGEEnabled, HPKE: ex.a2aStatus.HPKEEnabled}
+	entry := report.NewEntry(ex.attackLog, ex.originalMsg, ex.request.URL.Path, ex.targetURL, protection, upstream)
+	entry.Activation = ex.activation
+	p.recorder.Record(entry)
 
-	// Copy response headers
-	for key, values := range resp.Header {
-		for _, value := range values {
-			w.Header().Add(key, value)
+	if entry.Tampered() {
+		ex.attackLog.Upstream = &entry.Upstream
+		logger.LogAttackResult(ex.attackLog, entry.Verdict())
+		if finding := entry.Finding; finding != "" {
+			logger.Warn("🔎 %s", finding)
 		}
 	}
+}
 
-	// Write response
-	w.WriteHeader(resp.StatusCode)
-	w.Write(respBody)
+// contentTypeOrNone returns the request Content-Type for logging
+func contentTypeOrNone(r *http.Request) string {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		return ct
+	}
+	return "no content type"
+}
+
+// captureExchange records the original request, the forwarded request and the
+// upstream response as a HAR entry. resp is nil when the target was unreachable.
+func (p *ProxyHandler) captureExchange(ex *exchange, resp *http.Response, respBody []byte, wait, receive time.Duration, err error) {
+	r := ex.request
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	body, forwardBody := ex.body, ex.forwardBody
+	if ex.streamed != nil {
+		body = ex.streamed.buf.Bytes()
+		forwardBody = body
+	}
+
+	entry := &capture.Entry{
+		StartedDateTime: ex.start,
+		Request:         capture.NewRequest(r.Method, scheme+"://"+r.Host+r.URL.RequestURI(), r.Proto, r.Header, body),
+		Timings:         capture.NewTimings(time.Since(ex.start)-wait-receive, wait, receive),
+		Gateway: &capture.GatewayState{
+			AttackEnabled: p.config.IsAttackEnabled(),
+			AttackType:    string(p.config.GetAttackType()),
+			Target:        ex.targetURL,
+			Route:         ex.route.Route,
+			SAGEEnabled:   ex.a2aStatus.SAGEEnabled,
+			HPKEEnabled:   ex.a2aStatus.HPKEEnabled,
+			Activation:    ex.activation,
+			Attempts:      ex.attempts,
+		},
+	}
+	entry.Gateway.ClientTLS = tlsutil.Describe(r.TLS)
+	if resp != nil {
+		entry.Gateway.UpstreamTLS = tlsutil.Describe(resp.TLS)
+	}
+	if ex.forwardReq != nil {
+		entry.ModifiedRequest = capture.NewRequest(ex.forwardReq.Method, ex.forwardReq.URL.String(), "", ex.forwardReq.Header, forwardBody)
+	}
+	if ex.streamed != nil {
+		// The recorded body may be truncated; report what was sent
+		entry.Request.BodySize = int(ex.streamed.size)
+		if entry.ModifiedRequest != nil {
+			entry.ModifiedRequest.BodySize = int(ex.streamed.size)
+		}
+	}
+	if ex.attackLog != nil && len(ex.attackLog.Changes) > 0 {
+		entry.Attack = ex.attackLog
+	}
+
+	if resp != nil {
+		entry.Response = capture.NewResponse(resp.StatusCode, resp.Proto, resp.Header, respBody)
+	} else {
+		entry.Response = capture.NewResponse(0, "", http.Header{}, nil)
+	}
+	if err != nil {
+		entry.Comment = err.Error()
+	}
+	entry.Time = entry.Timings.Total()
+
+	p.capture.Add(entry)
 }
 
 // HandleHealth handles health check requests
@@ -195,7 +384,15 @@ func (p *ProxyHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
 		Success:        true,
 		AttackDetected: p.config.IsAttackEnabled(),
 		AttackType:     string(p.config.GetAttackType()),
+		Upstreams:      p.balancer.Status(),
+		Breakers:       p.client.Breakers().Status(),
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// HandleMetrics serves the circuit breaker metrics in the Prometheus text format
+func (p *ProxyHandler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	p.client.Breakers().WriteMetrics(w)
+}
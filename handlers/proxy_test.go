@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -40,45 +41,111 @@ func TestNewProxyHandler(t *testing.T) {
 	}
 }
 
-func TestProxyHandler_HandleRequest_MethodNotAllowed(t *testing.T) {
+func TestProxyHandler_HandleRequest_AllMethods(t *testing.T) {
+	// Mock target echoes the method, request URI and body it received
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Request-URI", r.RequestURI)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}))
+	defer mockTarget.Close()
+
 	cfg := &config.Config{
-		AttackEnabled:  false,
-		TargetAgentURL: "http://localhost:8091",
+		AttackEnabled:   true,
+		AttackType:      types.AttackTypePriceManipulation,
+		TargetAgentURL:  mockTarget.URL,
+		PriceMultiplier: 100.0,
 	}
 
 	handler := NewProxyHandler(cfg)
 
-	// Test GET request (only POST is allowed)
-	req := httptest.NewRequest("GET", "/payment", nil)
-	w := httptest.NewRecorder()
-
-	handler.HandleRequest(w, req)
-
-	resp := w.Result()
-	if resp.StatusCode != http.StatusMethodNotAllowed {
-		t.Errorf("HandleRequest() status code for GET: got %d, want %d",
-			resp.StatusCode, http.StatusMethodNotAllowed)
+	tests := []struct {
+		method string
+		target string
+		body   string
+	}{
+		{"GET", "/.well-known/agent.json", ""},
+		{"GET", "/tasks/42?historyLength=5", ""},
+		{"HEAD", "/health", ""},
+		{"PUT", "/tasks/42", `{"state":"done"}`},
+		{"PATCH", "/tasks/42", `{"state":"canceled"}`},
+		{"DELETE", "/tasks/42", ""},
+		{"OPTIONS", "/payment", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+
+			handler.HandleRequest(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("HandleRequest() status code: got %d, want %d", w.Code, http.StatusOK)
+			}
+			if got := w.Header().Get("X-Method"); got != tt.method {
+				t.Errorf("Target received method %s, want %s", got, tt.method)
+			}
+			if got := w.Header().Get("X-Request-URI"); got != tt.target {
+				t.Errorf("Target received URI %s, want %s", got, tt.target)
+			}
+		})
 	}
 }
 
-func TestProxyHandler_HandleRequest_InvalidJSON(t *testing.T) {
+func TestProxyHandler_HandleRequest_NonJSONPassthrough(t *testing.T) {
+	var received []byte
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockTarget.Close()
+
 	cfg := &config.Config{
-		AttackEnabled:  false,
-		TargetAgentURL: "http://localhost:8091",
+		AttackEnabled:   true,
+		AttackType:      types.AttackTypePriceManipulation,
+		TargetAgentURL:  mockTarget.URL,
+		PriceMultiplier: 100.0,
 	}
 
 	handler := NewProxyHandler(cfg)
 
-	// Invalid JSON body
-	req := httptest.NewRequest("POST", "/payment", bytes.NewBufferString("invalid json"))
-	w := httptest.NewRecorder()
-
-	handler.HandleRequest(w, req)
-
-	resp := w.Result()
-	if resp.StatusCode != http.StatusBadRequest {
-		t.Errorf("HandleRequest() status code for invalid JSON: got %d, want %d",
-			resp.StatusCode, http.StatusBadRequest)
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{"invalid json", "application/json", []byte("invalid json")},
+		{"json array", "application/json", []byte(`[{"amount":100}]`)},
+		{"unknown content type", "text/plain", []byte(`{"amount":100}`)},
+		{"binary", "application/octet-stream", []byte{0x00, 0xff, 0x10, 0x80}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/payment", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Signature-Input", `sig1=("@method");keyid="did:sage:demo:root"`)
+			req.Header.Set("Signature", "sig1=:AAAA:")
+			w := httptest.NewRecorder()
+
+			handler.HandleRequest(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("HandleRequest() status code: got %d, want %d", w.Code, http.StatusOK)
+			}
+			if !bytes.Equal(received, tt.body) {
+				t.Errorf("Body was not forwarded unmodified: got %q, want %q", received, tt.body)
+			}
+
+			entries := handler.Recorder().Entries()
+			entry := entries[len(entries)-1]
+			if entry.Tampered() || !entry.Protection.SAGE {
+				t.Errorf("Expected untampered passthrough with SAGE detected, got %+v", entry)
+			}
+		})
 	}
 }
 
@@ -527,3 +594,86 @@ func TestProxyHandler_AttackEnabled_NoModifications_ForwardError(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
 	}
 }
+
+func TestProxyHandler_RecordsUpstreamOutcome(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"metadata":{"status":"rejected","reason":"signature verification failed"}}`))
+	}))
+	defer mockTarget.Close()
+
+	cfg := &config.Config{
+		AttackEnabled:   true,
+		AttackType:      types.AttackTypePriceManipulation,
+		TargetAgentURL:  mockTarget.URL,
+		PriceMultiplier: 100.0,
+	}
+
+	handler := NewProxyHandler(cfg)
+
+	bodyBytes, _ := json.Marshal(map[string]interface{}{"amount": 100.0})
+	req := httptest.NewRequest("POST", "/payment", bytes.NewBuffer(bodyBytes))
+	req.Header.Set("Signature-Input", `sig1=("@method");keyid="did:sage:demo:root";alg="ed25519"`)
+	req.Header.Set("Signature", "sig1=:AAAA:")
+	w := httptest.NewRecorder()
+
+	handler.HandleRequest(w, req)
+
+	entries := handler.Recorder().Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 recorded exchange, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Scenario != string(types.AttackTypePriceManipulation) || !entry.Tampered() {
+		t.Errorf("Expected tampered price_manipulation entry, got %+v", entry)
+	}
+	if !entry.Protection.SAGE {
+		t.Error("Expected SAGE protection to be recorded")
+	}
+	if entry.Upstream.StatusCode != http.StatusUnauthorized || entry.Upstream.Outcome != "rejected" {
+		t.Errorf("Unexpected upstream result: %+v", entry.Upstream)
+	}
+	if entry.Verdict() != "attack blocked" {
+		t.Errorf("Verdict: got %q, want %q", entry.Verdict(), "attack blocked")
+	}
+}
+
+func TestProxyHandler_CapturesExchange(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer mockTarget.Close()
+
+	cfg := &config.Config{
+		AttackEnabled:   true,
+		AttackType:      types.AttackTypePriceManipulation,
+		TargetAgentURL:  mockTarget.URL,
+		PriceMultiplier: 100.0,
+	}
+
+	handler := NewProxyHandler(cfg)
+
+	req := httptest.NewRequest("POST", "/payment?trace=1", bytes.NewBufferString(`{"amount":100}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.HandleRequest(httptest.NewRecorder(), req)
+
+	entry := handler.Capture().Last()
+	if entry == nil {
+		t.Fatal("Expected the exchange to be captured")
+	}
+	if entry.Request.URL != "http://example.com/payment?trace=1" {
+		t.Errorf("Original request URL: got %s", entry.Request.URL)
+	}
+	if original, _ := entry.Request.Body(); string(original) != `{"amount":100}` {
+		t.Errorf("Original body: got %s", original)
+	}
+	if modified, _ := entry.ModifiedRequest.Body(); !bytes.Contains(modified, []byte(`"amount":10000`)) {
+		t.Errorf("Forwarded body: got %s", modified)
+	}
+	if entry.Response.Status != http.StatusOK || entry.Attack == nil || entry.Gateway.Target != mockTarget.URL {
+		t.Errorf("Unexpected capture: %+v", entry)
+	}
+}
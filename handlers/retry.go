@@ -1,59 +1,175 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
 )
 
 // RetryConfig holds retry configuration
 type RetryConfig struct {
-	MaxRetries       int
-	BackoffBase      int // milliseconds
-	HTTPTimeout      int // seconds
+	MaxRetries  int
+	BackoffBase int            // milliseconds
+	HTTPTimeout int            // seconds to wait for response headers (0 = no limit)
+	Breaker     *BreakerConfig // per-upstream circuit breaker (nil = disabled)
+
+	// Retry policy
+	RetryStatuses      []int         // statuses retried (empty = 429 and 5xx)
+	RetryNonIdempotent bool          // also retry POST/PATCH without an Idempotency-Key
+	Budget             time.Duration // total time for all attempts and waits (0 = unlimited)
+
+	// TLS configures https upstreams (nil = Go defaults)
+	TLS *tls.Config
+
+	// BodyBufferLimit is the largest body without GetBody that is buffered
+	// so it can be resent (0 = default); larger bodies are sent only once
+	BodyBufferLimit int64
 }
 
+// IdempotencyKeyHeader marks a non-idempotent request as safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 // RetryableHTTPClient wraps http.Client with retry logic
 type RetryableHTTPClient struct {
 	client      *http.Client
 	retryConfig *RetryConfig
+	breakers    *BreakerSet
 }
 
 // NewRetryableHTTPClient creates a new retryable HTTP client
 func NewRetryableHTTPClient(retryConfig *RetryConfig) *RetryableHTTPClient {
+	var breakers *BreakerSet
+	if retryConfig.Breaker != nil {
+		breakers = NewBreakerSet(*retryConfig.Breaker)
+	}
 	return &RetryableHTTPClient{
+		// The client is the ReverseProxy transport: HTTPTimeout must not cover
+		// reading the body, or streamed responses would be cut off
 		client: &http.Client{
-			Timeout: time.Duration(retryConfig.HTTPTimeout) * time.Second,
+			Transport: upstreamTransport(retryConfig.TLS, time.Duration(retryConfig.HTTPTimeout)*time.Second),
+			// Redirects are relayed to the caller, not followed by the gateway
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
 		},
 		retryConfig: retryConfig,
+		breakers:    breakers,
 	}
 }
 
-// Do executes an HTTP request with retry logic and exponential backoff
+// Breakers returns the per-upstream circuit breakers, nil when disabled
+func (r *RetryableHTTPClient) Breakers() *BreakerSet {
+	return r.breakers
+}
+
+// Do executes an HTTP request with retry logic and exponential backoff.
+// Every attempt passes the circuit breaker of the upstream host first; an
+// open breaker fails the request without contacting the upstream. Retries
+// follow the policy of the RetryConfig: only retryable statuses, only safe
+// or keyed requests, honouring Retry-After and the total retry budget, and
+// stopping as soon as the request context is done.
 func (r *RetryableHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	var resp *http.Response
 	var err error
 
+	var breaker *CircuitBreaker
+	if r.breakers != nil {
+		breaker = r.breakers.Get(req.URL.Host)
+	}
+
 	maxRetries := r.retryConfig.MaxRetries
 	if maxRetries < 0 {
 		maxRetries = 0
 	}
+	start := time.Now()
+
+	// Every attempt needs its own copy of the body. A streamed body the
+	// policy would not resend is not buffered for it and goes out once.
+	var getBody func() (io.ReadCloser, error)
+	if req.GetBody == nil && req.Body != nil && req.Body != http.NoBody && !r.mayResend(req) {
+		maxRetries = 0
+	}
+	if maxRetries > 0 {
+		var replayable bool
+		if getBody, replayable, err = r.replayableBody(req); err != nil {
+			return nil, err
+		}
+		if !replayable {
+			logger.Warn("⚠️  Request body of %s %s exceeds %d bytes and cannot be resent, retries disabled",
+				req.Method, req.URL.Redacted(), r.bodyBufferLimit())
+			maxRetries = 0
+		}
+	}
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
-		// Clone request for retry (body can only be read once)
+		if breaker != nil {
+			if openErr := breaker.Allow(); openErr != nil {
+				if attempt == 0 {
+					logger.Warn("⛔ %v", openErr)
+					return nil, openErr
+				}
+				// Opened during the retries: fail fast like a first attempt
+				// and drop the last failure
+				if resp != nil {
+					resp.Body.Close()
+				}
+				logger.Warn("⛔ %v, giving up after %d attempts", openErr, attempt)
+				return nil, openErr
+			}
+		}
+
+		// The previous attempt failed and is being retried
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		// Clone the request with a fresh copy of the body
 		reqClone := req.Clone(req.Context())
+		if getBody != nil {
+			body, bodyErr := getBody()
+			if bodyErr != nil {
+				return nil, fmt.Errorf("rebuild request body: %w", bodyErr)
+			}
+			reqClone.Body = body
+		}
+		var sent *attemptBody
+		if reqClone.Body != nil && reqClone.Body != http.NoBody {
+			sent = newAttemptBody(reqClone.Body)
+			reqClone.Body = sent
+		}
 
+		attemptStart := time.Now()
 		resp, err = r.client.Do(reqClone)
+		// An attempt the caller cancelled says nothing about the upstream
+		if breaker != nil && req.Context().Err() == nil {
+			breaker.Record(err == nil && resp.StatusCode < 500)
+		}
+		recordAttempt(reqClone, attempt+1, attemptStart, sent, resp, err)
 
-		// Success - return immediately
-		if err == nil && resp.StatusCode < 500 {
-			if attempt > 0 {
+		retry, reason := r.shouldRetry(req, resp, err)
+		if !retry {
+			if attempt > 0 && err == nil && !r.retryableStatus(resp.StatusCode) {
 				logger.Info("✅ Request succeeded after %d retries", attempt)
+			} else if reason != "" {
+				logger.Warn("⚠️  Not retrying %s %s: %s", req.Method, req.URL.Redacted(), reason)
 			}
-			return resp, nil
+			return resp, err
 		}
 
 		// Last attempt - return error
@@ -66,29 +182,234 @@ func (r *RetryableHTTPClient) Do(req *http.Request) (*http.Response, error) {
 			return resp, err
 		}
 
-		// Calculate backoff with exponential backoff
-		backoffTime := r.calculateBackoff(attempt)
+		// Exponential backoff, or longer if the upstream asked for it
+		wait := time.Duration(r.calculateBackoff(attempt)) * time.Millisecond
+		if retryAfter, ok := parseRetryAfter(resp, time.Now()); ok && retryAfter > wait {
+			wait = retryAfter
+		}
+		if budget := r.retryConfig.Budget; budget > 0 && time.Since(start)+wait > budget {
+			logger.Warn("⚠️  Retry budget of %s exhausted after %d attempts (next wait %s)", budget, attempt+1, wait.Round(time.Millisecond))
+			return resp, err
+		}
 
 		// Log retry attempt
 		if err != nil {
 			logger.Warn("⚠️  Request failed (attempt %d/%d): %v - retrying in %dms...",
-				attempt+1, maxRetries+1, err, backoffTime)
+				attempt+1, maxRetries+1, err, wait.Milliseconds())
 		} else {
 			logger.Warn("⚠️  Request failed (attempt %d/%d): HTTP %d - retrying in %dms...",
-				attempt+1, maxRetries+1, resp.StatusCode, backoffTime)
-			// Close failed response body
+				attempt+1, maxRetries+1, resp.StatusCode, wait.Milliseconds())
+		}
+
+		// Wait before retry, unless the caller gives up first
+		if ctxErr := sleepContext(req.Context(), wait); ctxErr != nil {
 			if resp != nil && resp.Body != nil {
 				resp.Body.Close()
 			}
+			return nil, ctxErr
 		}
-
-		// Wait before retry
-		time.Sleep(time.Duration(backoffTime) * time.Millisecond)
 	}
 
 	return resp, err
 }
 
+// replayableBody returns a function producing a fresh copy of the request
+// body for every attempt. GetBody is used when the request has one; other
+// bodies are buffered up to the body buffer limit. A larger body is restored
+// unread and reported as not replayable.
+func (r *RetryableHTTPClient) replayableBody(req *http.Request) (func() (io.ReadCloser, error), bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true, nil
+	}
+	if req.GetBody != nil {
+		return req.GetBody, true, nil
+	}
+
+	limit := r.bodyBufferLimit()
+	if req.ContentLength > limit {
+		return nil, false, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("buffer request body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}
+		return nil, false, nil
+	}
+	req.Body.Close()
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}, true, nil
+}
+
+func (r *RetryableHTTPClient) bodyBufferLimit() int64 {
+	if r.retryConfig.BodyBufferLimit > 0 {
+		return r.retryConfig.BodyBufferLimit
+	}
+	return defaultBodyBufferLimit
+}
+
+// shouldRetry applies the retry policy to the outcome of one attempt. The
+// reason explains a refusal to retry a failed attempt and is empty otherwise.
+func (r *RetryableHTTPClient) shouldRetry(req *http.Request, resp *http.Response, err error) (bool, string) {
+	if ctxErr := req.Context().Err(); ctxErr != nil {
+		return false, ""
+	}
+	if err == nil && !r.retryableStatus(resp.StatusCode) {
+		return false, ""
+	}
+	// A request that never reached the upstream is always safe to resend
+	if err != nil && isDialError(err) {
+		return true, ""
+	}
+	if !r.mayResend(req) {
+		return false, "non-idempotent request without " + IdempotencyKeyHeader
+	}
+	return true, ""
+}
+
+// mayResend reports whether the policy allows sending a request that may
+// have reached the upstream again
+func (r *RetryableHTTPClient) mayResend(req *http.Request) bool {
+	return r.retryConfig.RetryNonIdempotent || isIdempotent(req)
+}
+
+// retryableStatus reports whether a response status is retried
+func (r *RetryableHTTPClient) retryableStatus(statusCode int) bool {
+	if len(r.retryConfig.RetryStatuses) == 0 {
+		return isRetryable(statusCode)
+	}
+	for _, code := range r.retryConfig.RetryStatuses {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdempotent reports whether resending a request cannot apply it twice:
+// safe and idempotent methods (RFC 9110 section 9.2.2), or any request
+// carrying an Idempotency-Key
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// isDialError reports whether err happened before a connection was made
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// attemptBodyPreview is how much of each sent body an attempt record keeps
+const attemptBodyPreview = 1024
+
+// attemptBody fingerprints the bytes the transport reads from a request body.
+// The transport may still be writing when the response arrives, hence the lock.
+type attemptBody struct {
+	io.ReadCloser
+	mu      sync.Mutex
+	hash    hash.Hash
+	size    int64
+	preview bytes.Buffer
+}
+
+func newAttemptBody(body io.ReadCloser) *attemptBody {
+	return &attemptBody{ReadCloser: body, hash: sha256.New()}
+}
+
+func (b *attemptBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	b.hash.Write(p[:n])
+	b.size += int64(n)
+	if room := attemptBodyPreview - b.preview.Len(); room > 0 {
+		b.preview.Write(p[:min(n, room)])
+	}
+	b.mu.Unlock()
+	return n, err
+}
+
+// recordAttempt reports what one attempt sent and how the upstream answered,
+// as a WebSocket event and on the captured exchange
+func recordAttempt(req *http.Request, number int, start time.Time, sent *attemptBody, resp *http.Response, err error) {
+	attempt := types.UpstreamAttempt{
+		Attempt:    number,
+		StartedAt:  start,
+		Method:     req.Method,
+		URL:        req.URL.Redacted(),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if sent != nil {
+		sent.mu.Lock()
+		attempt.BodySize = sent.size
+		attempt.BodySHA256 = hex.EncodeToString(sent.hash.Sum(nil))
+		attempt.Body = sent.preview.String()
+		sent.mu.Unlock()
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	} else {
+		attempt.StatusCode = resp.StatusCode
+	}
+
+	logger.LogUpstreamAttempt(&attempt)
+	if ex := exchangeFrom(req.Context()); ex != nil {
+		ex.attempts = append(ex.attempts, attempt)
+	}
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RoundTrip implements http.RoundTripper so the client can serve as the
+// transport of the reverse proxy
+func (r *RetryableHTTPClient) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	return r.Do(out)
+}
+
 // calculateBackoff calculates exponential backoff time in milliseconds
 // Formula: backoffBase * (2 ^ attempt) with jitter
 func (r *RetryableHTTPClient) calculateBackoff(attempt int) int {
@@ -117,9 +438,9 @@ func isRetryable(statusCode int) bool {
 	return statusCode >= 500 || statusCode == 429
 }
 
-// GetHTTPTimeout returns the configured HTTP timeout
+// GetHTTPTimeout returns how long an attempt waits for response headers
 func (r *RetryableHTTPClient) GetHTTPTimeout() time.Duration {
-	return r.client.Timeout
+	return time.Duration(r.retryConfig.HTTPTimeout) * time.Second
 }
 
 // GetRetryConfig returns the retry configuration
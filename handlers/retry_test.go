@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
 )
 
 func TestNewRetryableHTTPClient(t *testing.T) {
@@ -207,6 +213,7 @@ func TestRetryableHTTPClient_Do_WithRequestBody(t *testing.T) {
 	client := NewRetryableHTTPClient(retryConfig)
 
 	req, _ := http.NewRequest("POST", server.URL, strings.NewReader("test body"))
+	req.Header.Set(IdempotencyKeyHeader, "payment-42")
 	resp, err := client.Do(req)
 
 	if err != nil {
@@ -327,3 +334,339 @@ func TestRetryableHTTPClient_GetRetryConfig(t *testing.T) {
 		t.Errorf("HTTPTimeout: got %d, want 60", config.HTTPTimeout)
 	}
 }
+
+func TestRetryableHTTPClient_Do_NoRetryForUnkeyedPost(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{MaxRetries: 3, BackoffBase: 1, HTTPTimeout: 5})
+
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader(`{"amount":100}`))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || attempts != 1 {
+		t.Errorf("POST without %s: got HTTP %d after %d attempts, want 503 after 1", IdempotencyKeyHeader, resp.StatusCode, attempts)
+	}
+
+	// Connection refused: nothing was sent, so even a POST is resent. The
+	// client has its own transport, which server.Close does not clean up.
+	server.Close()
+	client.client.CloseIdleConnections()
+	req, _ = http.NewRequest("POST", server.URL, strings.NewReader(`{"amount":100}`))
+	_, err = client.client.Do(req)
+	if !isDialError(err) {
+		t.Fatalf("Expected a dial error, got %v", err)
+	}
+	if retry, reason := client.shouldRetry(req, nil, err); !retry {
+		t.Errorf("Dial errors should be retried for POST too: %s", reason)
+	}
+}
+
+func TestRetryableHTTPClient_Do_RetryAfter(t *testing.T) {
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		times = append(times, time.Now())
+		if len(times) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{MaxRetries: 2, BackoffBase: 1, HTTPTimeout: 5})
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(times) != 2 {
+		t.Fatalf("Expected 429 then 200, got HTTP %d after %d attempts", resp.StatusCode, len(times))
+	}
+	if gap := times[1].Sub(times[0]); gap < time.Second {
+		t.Errorf("Retry-After: 1 not honoured, retried after %s", gap)
+	}
+}
+
+func TestRetryableHTTPClient_Do_Budget(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{MaxRetries: 3, BackoffBase: 1, HTTPTimeout: 5, Budget: time.Second})
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || attempts != 1 {
+		t.Errorf("Got HTTP %d after %d attempts, want 503 after 1", resp.StatusCode, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("A wait beyond the budget should not be started, took %s", elapsed)
+	}
+}
+
+func TestRetryableHTTPClient_Do_ContextCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{MaxRetries: 3, BackoffBase: 5000, HTTPTimeout: 5})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+
+	start := time.Now()
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Backoff ignored the cancelled context, took %s", elapsed)
+	}
+}
+
+func TestRetryableHTTPClient_Do_RetryStatuses(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{MaxRetries: 3, BackoffBase: 1, HTTPTimeout: 5, RetryStatuses: []int{502, 503}})
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	if _, err := client.Do(req); err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("500 is not in RetryStatuses, got %d attempts", attempts)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 11, 4, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{"Tue, 04 Nov 2025 12:00:30 GMT", 30 * time.Second, true},
+		{"Tue, 04 Nov 2025 11:00:00 GMT", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.value != "" {
+			resp.Header.Set("Retry-After", tt.value)
+		}
+		got, ok := parseRetryAfter(resp, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v; want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// onceReader is a request body without GetBody, as streamed bodies are
+type onceReader struct{ io.Reader }
+
+func (onceReader) Close() error { return nil }
+
+func TestRetryableHTTPClient_Do_ReplaysBodyWithoutGetBody(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{MaxRetries: 3, BackoffBase: 1, HTTPTimeout: 5})
+
+	req, _ := http.NewRequest("PUT", server.URL, onceReader{strings.NewReader("streamed body")})
+	ex := &exchange{}
+	req = req.WithContext(context.WithValue(req.Context(), exchangeKey{}, ex))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode: got %d, want 200", resp.StatusCode)
+	}
+	for i, body := range bodies {
+		if body != "streamed body" {
+			t.Errorf("Attempt %d sent %q, want %q", i+1, body, "streamed body")
+		}
+	}
+
+	if len(ex.attempts) != 3 {
+		t.Fatalf("Expected 3 recorded attempts, got %d", len(ex.attempts))
+	}
+	for i, attempt := range ex.attempts {
+		if attempt.Attempt != i+1 || attempt.BodySize != 13 || attempt.Body != "streamed body" || attempt.BodySHA256 != ex.attempts[0].BodySHA256 {
+			t.Errorf("Unexpected attempt record: %+v", attempt)
+		}
+	}
+	if ex.attempts[0].StatusCode != http.StatusServiceUnavailable || ex.attempts[2].StatusCode != http.StatusOK {
+		t.Errorf("Attempt statuses: got %d and %d", ex.attempts[0].StatusCode, ex.attempts[2].StatusCode)
+	}
+}
+
+func TestRetryableHTTPClient_Do_OversizedBodyNotRetried(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{MaxRetries: 3, BackoffBase: 1, HTTPTimeout: 5, BodyBufferLimit: 4})
+
+	req, _ := http.NewRequest("PUT", server.URL, onceReader{strings.NewReader("larger than the limit")})
+	if _, err := client.Do(req); err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	if len(bodies) != 1 || bodies[0] != "larger than the limit" {
+		t.Errorf("Expected the whole body to be sent once, got %q", bodies)
+	}
+}
+
+func TestRetryableHTTPClient_Do_UnkeyedPostNotBuffered(t *testing.T) {
+	received := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadFull(r.Body, make([]byte, 5))
+		close(received)
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{MaxRetries: 3, BackoffBase: 1, HTTPTimeout: 5})
+
+	// The body only ends once the upstream has seen its start, which never
+	// happens if it is buffered for retries first
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("start"))
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Error("A POST the policy never resends should be streamed, not buffered")
+		}
+		pw.Close()
+	}()
+
+	req, _ := http.NewRequest("POST", server.URL, pr)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestProxyHandler_RetriedTamperedPayment(t *testing.T) {
+	var amounts []interface{}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&msg)
+		amounts = append(amounts, msg["amount"])
+		if len(amounts) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	cfg := &config.Config{
+		AttackEnabled:    true,
+		AttackType:       types.AttackTypePriceManipulation,
+		TargetAgentURL:   target.URL,
+		PriceMultiplier:  100.0,
+		MaxRetries:       2,
+		RetryBackoffBase: 1,
+		HTTPTimeout:      5,
+	}
+	handler := NewProxyHandler(cfg)
+
+	req := httptest.NewRequest("POST", "/payment", strings.NewReader(`{"amount":100,"currency":"USD"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, "payment-42")
+	w := httptest.NewRecorder()
+	handler.HandleRequest(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("HandleRequest() status code: got %d, want %d", w.Code, http.StatusOK)
+	}
+	if len(amounts) != 2 || amounts[0] != 10000.0 || amounts[1] != 10000.0 {
+		t.Errorf("Both attempts should carry the tampered amount, got %v", amounts)
+	}
+
+	attempts := handler.Capture().Last().Gateway.Attempts
+	if len(attempts) != 2 {
+		t.Fatalf("Expected 2 captured attempts, got %d", len(attempts))
+	}
+	if attempts[0].BodySHA256 == "" || attempts[0].BodySHA256 != attempts[1].BodySHA256 || !strings.Contains(attempts[1].Body, "10000") {
+		t.Errorf("Attempts sent different bodies: %+v", attempts)
+	}
+}
+
+func TestRetryableHTTPClient_Do_StreamsPastTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-headers" {
+			time.Sleep(1500 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("chunk\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(500 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	client := NewRetryableHTTPClient(&RetryConfig{MaxRetries: 0, HTTPTimeout: 1})
+
+	req, _ := http.NewRequest("GET", server.URL+"/stream", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || strings.Count(string(body), "chunk") != 3 {
+		t.Errorf("Streamed body cut off after the header timeout: %q, %v", body, err)
+	}
+
+	req, _ = http.NewRequest("GET", server.URL+"/slow-headers", nil)
+	if resp, err := client.Do(req); err == nil {
+		resp.Body.Close()
+		t.Error("Expected the header timeout to fail a slow upstream")
+	}
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+)
+
+// Route names of the built-in routing steps
+const (
+	RouteTo      = "to"      // AgentMessage "to" field via AGENT_URLS
+	RouteDefault = "default" // ROUTE_DEFAULT
+	RouteTarget  = "target"  // legacy TARGET_AGENT_URL
+)
+
+// RouteMatch is the routing decision for one request
+type RouteMatch struct {
+	Route       string // route name, or RouteTo / RouteDefault / RouteTarget
+	Reason      string // which conditions matched, for logs and events
+	TargetURL   string
+	StripPrefix string   // path prefix to remove before forwarding
+	Backend     *Backend // replica chosen by the balancer, if any
+}
+
+// Router picks the target of a request: the routing table first, by
+// priority, then the AgentMessage "to" field, then the default route
+type Router struct {
+	config   *config.Config
+	routes   []config.Route
+	balancer *Balancer
+}
+
+// NewRouter creates a router over the configured routing table. Agents with
+// a pool in balancer (which may be nil) resolve to one of their replicas.
+func NewRouter(cfg *config.Config, balancer *Balancer) *Router {
+	routes := append([]config.Route(nil), cfg.Routes...)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Priority > routes[j].Priority
+	})
+	return &Router{config: cfg, routes: routes, balancer: balancer}
+}
+
+// NeedsBody reports whether routing r depends on its body: the first route
+// whose path, host and headers match also matches on body metadata, pins
+// conversations to a replica by their ContextID, or no route matches and
+// the "to" field is looked up in AGENT_URLS
+func (rt *Router) NeedsBody(r *http.Request) bool {
+	for _, route := range rt.routes {
+		withoutBody := route
+		withoutBody.Metadata = nil
+		if _, ok := matchRoute(withoutBody, r, nil); !ok {
+			continue
+		}
+		if len(route.Metadata) > 0 {
+			return true
+		}
+		return route.Agent != "" && rt.pinsByContext(route.Agent)
+	}
+	return len(rt.config.AgentURLs) > 0
+}
+
+// pinsByContext reports whether the replica of agent is picked by ContextID
+func (rt *Router) pinsByContext(agent string) bool {
+	pool := rt.balancer.Pool(agent)
+	return pool != nil && pool.policy == LBConsistentHash
+}
+
+// Route returns the routing decision for a request and its decoded body
+// (nil when the body was not decoded)
+func (rt *Router) Route(r *http.Request, msg map[string]interface{}) *RouteMatch {
+	for _, route := range rt.routes {
+		reason, ok := matchRoute(route, r, msg)
+		if !ok {
+			continue
+		}
+		match := &RouteMatch{Route: route.DisplayName(), Reason: reason, TargetURL: route.URL}
+		if route.Agent != "" {
+			rt.resolveAgent(match, route.Agent, msg)
+		}
+		if route.StripPrefix {
+			match.StripPrefix = route.PathPrefix
+		}
+		return match
+	}
+
+	if to, _ := msg["to"].(string); to != "" {
+		if rt.config.GetAgentURL(to) != "" {
+			return rt.resolveAgent(&RouteMatch{Route: RouteTo, Reason: "to=" + to}, to, msg)
+		}
+		logger.Warn("Unknown agent in 'to' field: %s, falling back to default target", to)
+	}
+
+	if def := rt.config.RouteDefault; def != "" {
+		if rt.config.GetAgentURL(def) != "" {
+			return rt.resolveAgent(&RouteMatch{Route: RouteDefault, Reason: "agent " + def}, def, msg)
+		}
+		return &RouteMatch{Route: RouteDefault, Reason: "url", TargetURL: def}
+	}
+	return &RouteMatch{Route: RouteTarget, Reason: "TARGET_AGENT_URL", TargetURL: rt.config.GetTargetURL()}
+}
+
+// resolveAgent sets the target of match to an agent URL, picking a replica
+// when the agent is load balanced
+func (rt *Router) resolveAgent(match *RouteMatch, agent string, msg map[string]interface{}) *RouteMatch {
+	pool := rt.balancer.Pool(agent)
+	if pool == nil {
+		match.TargetURL = rt.config.GetAgentURL(agent)
+		return match
+	}
+	match.Backend = pool.Pick(contextID(msg))
+	match.TargetURL = match.Backend.URL
+	match.Reason += fmt.Sprintf(" [%s of %d replicas]", pool.policy, len(pool.backends))
+	return match
+}
+
+// contextID returns the conversation ID of an AgentMessage or a JSON-RPC
+// (A2A) message, used to pin conversations to a replica
+func contextID(msg map[string]interface{}) string {
+	if id, ok := msg["contextId"].(string); ok {
+		return id
+	}
+	params, _ := msg["params"].(map[string]interface{})
+	message, _ := params["message"].(map[string]interface{})
+	id, _ := message["contextId"].(string)
+	return id
+}
+
+// matchRoute checks every condition of a route, describing those that matched
+func matchRoute(route config.Route, r *http.Request, msg map[string]interface{}) (string, bool) {
+	var reasons []string
+
+	if route.PathPrefix != "" {
+		if !hasPathPrefix(r.URL.Path, route.PathPrefix) {
+			return "", false
+		}
+		reasons = append(reasons, "path "+route.PathPrefix)
+	}
+
+	if route.Host != "" {
+		if !matchHost(r.Host, route.Host) {
+			return "", false
+		}
+		reasons = append(reasons, "host "+route.Host)
+	}
+
+	for _, name := range sortedKeys(route.Headers) {
+		want := route.Headers[name]
+		got := r.Header.Get(name)
+		if got == "" || (want != "*" && got != want) {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("header %s=%s", name, got))
+	}
+
+	if len(route.Metadata) > 0 {
+		metadata := jsonRPCMetadata(msg)
+		for _, key := range sortedKeys(route.Metadata) {
+			want := route.Metadata[key]
+			value, ok := metadata[key]
+			if !ok || value == nil || (want != "*" && formatScalar(value) != want) {
+				return "", false
+			}
+			reasons = append(reasons, fmt.Sprintf("metadata %s=%s", key, formatScalar(value)))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return "catch-all", true
+	}
+	return strings.Join(reasons, ", "), true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hasPathPrefix matches whole path segments: /agents/payment matches
+// /agents/payment and /agents/payment/tasks but not /agents/payments
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// matchHost compares a Host header, ignoring the port, with a pattern that
+// may start with "*." to match any subdomain
+func matchHost(hostport, pattern string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(host, suffix)
+	}
+	return host == pattern
+}
+
+// jsonRPCMetadata returns params.message.metadata of a JSON-RPC (A2A) body
+func jsonRPCMetadata(msg map[string]interface{}) map[string]interface{} {
+	params, _ := msg["params"].(map[string]interface{})
+	message, _ := params["message"].(map[string]interface{})
+	metadata, _ := message["metadata"].(map[string]interface{})
+	return metadata
+}
+
+// stripPathPrefix removes a matched route prefix from the request URL
+func stripPathPrefix(u *url.URL, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	u.Path = ensureLeadingSlash(strings.TrimPrefix(u.Path, prefix))
+	if u.RawPath != "" {
+		if rest, ok := strings.CutPrefix(u.RawPath, prefix); ok {
+			u.RawPath = ensureLeadingSlash(rest)
+		} else {
+			u.RawPath = ""
+		}
+	}
+}
+
+func ensureLeadingSlash(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return "/" + path
+	}
+	return path
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+)
+
+func routerConfig() *config.Config {
+	return &config.Config{
+		TargetAgentURL: "http://legacy.local",
+		AgentURLs: map[string]string{
+			"payment": "http://payment.local",
+			"medical": "http://medical.local",
+		},
+		Routes: []config.Route{
+			{Name: "payments", PathPrefix: "/agents/payment", StripPrefix: true, Agent: "payment"},
+			{Name: "medical-host", Host: "*.medical.example", Agent: "medical"},
+			{Name: "tenant", Headers: map[string]string{"X-Tenant": "acme"}, URL: "http://acme.local"},
+			{Name: "skill", Metadata: map[string]string{"skill": "payment"}, Agent: "payment"},
+			{Name: "override", Priority: 10, Headers: map[string]string{"X-Debug-Route": "*"}, URL: "http://debug.local"},
+		},
+	}
+}
+
+func TestRouter_Route(t *testing.T) {
+	router := NewRouter(routerConfig(), nil)
+
+	jsonRPC := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"params": map[string]interface{}{
+			"message": map[string]interface{}{
+				"metadata": map[string]interface{}{"skill": "payment"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		host       string
+		header     map[string]string
+		msg        map[string]interface{}
+		wantRoute  string
+		wantTarget string
+		wantStrip  string
+	}{
+		{"path prefix", "/agents/payment/tasks/1", "", nil, nil, "payments", "http://payment.local", "/agents/payment"},
+		{"path prefix exact", "/agents/payment", "", nil, nil, "payments", "http://payment.local", "/agents/payment"},
+		{"path prefix segment", "/agents/payments", "", nil, nil, RouteTarget, "http://legacy.local", ""},
+		{"host wildcard", "/", "eu.medical.example:8090", nil, nil, "medical-host", "http://medical.local", ""},
+		{"header", "/", "", map[string]string{"X-Tenant": "acme"}, nil, "tenant", "http://acme.local", ""},
+		{"header mismatch", "/", "", map[string]string{"X-Tenant": "other"}, nil, RouteTarget, "http://legacy.local", ""},
+		{"metadata", "/", "", nil, jsonRPC, "skill", "http://payment.local", ""},
+		{"priority", "/agents/payment/x", "", map[string]string{"X-Debug-Route": "1"}, nil, "override", "http://debug.local", ""},
+		{"to field", "/", "", nil, map[string]interface{}{"to": "medical"}, RouteTo, "http://medical.local", ""},
+		{"unknown to", "/", "", nil, map[string]interface{}{"to": "billing"}, RouteTarget, "http://legacy.local", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			match := router.Route(req, tt.msg)
+
+			if match.Route != tt.wantRoute || match.TargetURL != tt.wantTarget || match.StripPrefix != tt.wantStrip {
+				t.Errorf("Route() = %+v, want %s -> %s (strip %q)", match, tt.wantRoute, tt.wantTarget, tt.wantStrip)
+			}
+		})
+	}
+}
+
+func TestRouter_Default(t *testing.T) {
+	cfg := routerConfig()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	cfg.RouteDefault = "medical"
+	if match := NewRouter(cfg, nil).Route(req, nil); match.Route != RouteDefault || match.TargetURL != "http://medical.local" {
+		t.Errorf("Default agent route: got %+v", match)
+	}
+
+	cfg.RouteDefault = "http://fallback.local"
+	if match := NewRouter(cfg, nil).Route(req, nil); match.Route != RouteDefault || match.TargetURL != "http://fallback.local" {
+		t.Errorf("Default URL route: got %+v", match)
+	}
+}
+
+func TestRouter_NeedsBody(t *testing.T) {
+	cfg := routerConfig()
+
+	tests := []struct {
+		name   string
+		path   string
+		header map[string]string
+		want   bool
+	}{
+		{"path route picks the target", "/agents/payment/tasks/1", nil, false},
+		{"header route picks the target", "/", map[string]string{"X-Tenant": "acme"}, false},
+		{"priority route picks the target", "/", map[string]string{"X-Debug-Route": "1"}, false},
+		{"metadata route may match", "/other", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			if got := NewRouter(cfg, nil).NeedsBody(req); got != tt.want {
+				t.Errorf("NeedsBody() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Without a metadata route the "to" field decides, unless AGENT_URLS is empty
+	cfg.Routes = cfg.Routes[:3]
+	req := httptest.NewRequest("POST", "/other", nil)
+	if !NewRouter(cfg, nil).NeedsBody(req) {
+		t.Error("NeedsBody() should be true when the \"to\" field is looked up")
+	}
+	cfg.AgentURLs = nil
+	if NewRouter(cfg, nil).NeedsBody(req) {
+		t.Error("NeedsBody() should be false without AGENT_URLS")
+	}
+
+	// A conversation pinned by ContextID needs the body even on a path route
+	cfg = routerConfig()
+	cfg.LBPolicy = LBConsistentHash
+	cfg.AgentUpstreams = map[string][]string{"payment": {"http://payment-1.local", "http://payment-2.local"}}
+	req = httptest.NewRequest("POST", "/agents/payment/tasks/1", nil)
+	if !NewRouter(cfg, NewBalancer(cfg)).NeedsBody(req) {
+		t.Error("NeedsBody() should be true for a consistent_hash pool")
+	}
+}
+
+func TestStripPathPrefix(t *testing.T) {
+	tests := map[string]string{
+		"/agents/payment/tasks/1": "/tasks/1",
+		"/agents/payment":         "/",
+		"/agents/payment/":        "/",
+	}
+	for path, want := range tests {
+		u := &url.URL{Path: path}
+		stripPathPrefix(u, "/agents/payment/")
+		if u.Path != want {
+			t.Errorf("stripPathPrefix(%s) = %s, want %s", path, u.Path, want)
+		}
+	}
+
+	u, _ := url.Parse("/agents/payment/a%2Fb")
+	stripPathPrefix(u, "/agents/payment")
+	if u.EscapedPath() != "/a%2Fb" {
+		t.Errorf("Escaped path: got %s, want /a%%2Fb", u.EscapedPath())
+	}
+}
+
+func TestProxyHandler_RoutesByPathPrefix(t *testing.T) {
+	var gotURI string
+	payment := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURI = r.RequestURI
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer payment.Close()
+
+	cfg := &config.Config{
+		TargetAgentURL: "http://127.0.0.1:1",
+		AgentURLs:      map[string]string{"payment": payment.URL},
+		Routes: []config.Route{
+			{PathPrefix: "/agents/payment", StripPrefix: true, Agent: "payment"},
+		},
+	}
+	handler := NewProxyHandler(cfg)
+
+	req := httptest.NewRequest("POST", "/agents/payment/tasks/42?history=5", bytes.NewBufferString(`{"amount":100}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.HandleRequest(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("HandleRequest() status code: got %d, want %d", w.Code, http.StatusOK)
+	}
+	if gotURI != "/tasks/42?history=5" {
+		t.Errorf("Target received %s, want /tasks/42?history=5", gotURI)
+	}
+	if got := handler.Capture().Last().Gateway.Route; got != "agent:payment" {
+		t.Errorf("Captured route: got %s, want agent:payment", got)
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/attacks"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/report"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// The identity_spoofing attack changes who a message claims to be from, or
+// which conversation it belongs to. With SPOOF_SIGNER the gateway re-signs the
+// result with the key of an agent it controls, so the signature verifies and
+// only a receiver that binds the keyid (DID) to "from" catches it.
+
+// Resign replaces the signature on r with one by the demo key of signer over
+// body, covering the same components as sig (the defaults when sig is nil),
+// and returns the rewritten headers
+func Resign(r *http.Request, body []byte, sig *sage.SignatureParams, signer string) ([]types.HeaderChange, error) {
+	var components []string
+	if sig != nil {
+		for _, c := range sig.Components {
+			name := c.Name
+			if c.Param != "" {
+				name += ";name=" + c.Param
+			}
+			components = append(components, name)
+		}
+	}
+
+	names := append(append([]string{}, signatureHeaders...), "Content-Digest")
+	old := make(map[string]string, len(names))
+	for _, name := range names {
+		old[name] = strings.Join(r.Header.Values(name), ", ")
+	}
+
+	if _, err := sage.SignRequest(r, body, sage.DemoKeyPair(signer), &sage.SignOptions{Components: components}); err != nil {
+		return nil, err
+	}
+
+	var changes []types.HeaderChange
+	for _, name := range names {
+		if value := r.Header.Get(name); value != old[name] {
+			changes = append(changes, types.HeaderChange{
+				Header: name, Action: HeaderResigned, OriginalValue: old[name], ModifiedValue: value,
+			})
+		}
+	}
+	return changes, nil
+}
+
+// spoofHeaders re-signs a spoofed request as signer (SPOOF_SIGNER, none when
+// empty) and logs whether the signing key still matches the claimed sender
+func spoofHeaders(outReq *http.Request, body []byte, msg map[string]interface{}, a2aStatus *A2AStatus, signer string) []types.HeaderChange {
+	var changes []types.HeaderChange
+	if signer != "" {
+		var err error
+		if changes, err = Resign(outReq, body, a2aStatus.Signature, signer); err != nil {
+			logger.Error("Failed to re-sign spoofed message as %s: %v", signer, err)
+		} else {
+			logger.Warn("✍️  Re-signed spoofed message with the key of %s (%s)", signer, sage.DemoKeyID(signer))
+		}
+	}
+
+	logKeyBinding(outReq, msg, a2aStatus)
+	return changes
+}
+
+// logKeyBinding explains how a receiver can tell that the outgoing message
+// does not come from the sender it claims
+func logKeyBinding(outReq *http.Request, msg map[string]interface{}, a2aStatus *A2AStatus) {
+	from, _ := msg["from"].(string)
+	inputs, err := sage.ParseSignatureInput(outReq.Header.Get("Signature-Input"))
+	if !sage.HasSignature(outReq.Header) || err != nil {
+		logger.Warn("❌ No signature - nothing binds from=%s to a key, the receiver has to take it at face value", from)
+		return
+	}
+
+	keyID := inputs[0].KeyID
+	if a2aStatus.Signature != nil && a2aStatus.Signature.KeyID == keyID && coversBody(a2aStatus.Signature) {
+		logger.Warn("🛡️  Original signature kept - its content-digest no longer matches, signature verification rejects the message")
+		return
+	}
+	if agent, ok := sage.AgentFromKeyID(keyID); ok && agent != from {
+		logger.Warn("🪪 Signed by %s (agent %s) but claims from=%s - a receiver binding keyid to sender (DID) rejects it", keyID, agent, from)
+		return
+	}
+	logger.Warn("🪪 keyid %s matches from=%s - the DID binding cannot catch this message", keyID, from)
+}
+
+// duplicateTimeout bounds how long a duplicate may take, since no client is
+// waiting for it
+const duplicateTimeout = 30 * time.Second
+
+// forwardDuplicate sends dupMsg, a copy of the exchange tampered with by step,
+// in the background once the original has been answered. Its outcome is
+// recorded like any other exchange, but the response is not relayed to the
+// client; Wait blocks until it has been.
+func (p *ProxyHandler) forwardDuplicate(r *http.Request, ex *exchange, step attackStep, attackLog *types.AttackLog, dupMsg map[string]interface{}) {
+	body, err := p.interceptor.EncodeBody(r, dupMsg, ex.body)
+	if err != nil {
+		logger.Error("Failed to encode duplicate message: %v", err)
+		return
+	}
+
+	dup := &exchange{
+		start:       time.Now(),
+		request:     ex.request,
+		body:        ex.body,
+		originalMsg: ex.originalMsg,
+		target:      ex.target,
+		targetURL:   ex.targetURL,
+		route:       ex.route,
+		attackLog:   attackLog,
+		activation:  ex.activation,
+		a2aStatus:   ex.a2aStatus,
+		forwardBody: body,
+	}
+	// The client may leave as soon as it has its answer: the duplicate gets
+	// its own deadline instead of the request context
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), duplicateTimeout)
+	outReq := r.Clone(context.WithValue(ctx, exchangeKey{}, dup))
+	if ex.route.StripPrefix != "" {
+		stripPathPrefix(outReq.URL, ex.route.StripPrefix)
+	}
+	setRequestBody(outReq, body)
+	attackLog.HeaderChanges = step.Headers(outReq, body, dupMsg, attackLog, ex.a2aStatus)
+
+	attackLog.Activation = ex.activation
+	logger.LogAttack(attackLog)
+	logger.Info("📨 Forwarding duplicate to: %s%s", ex.targetURL, outReq.URL.RequestURI())
+	p.background.Add(1)
+	go func() {
+		defer p.background.Done()
+		defer cancel()
+		dup.forwardStart = time.Now()
+		p.proxy.ServeHTTP(&discardResponseWriter{header: http.Header{}}, outReq)
+	}()
+}
+
+// discardResponseWriter swallows the response to a request the client never sent
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+// spoofStep is identity_spoofing: "from" or "contextId" of a plaintext
+// message is rewritten. In duplicate mode the original passes untouched and a
+// rewritten copy follows it (see Followup).
+type spoofStep struct {
+	attack *attacks.SpoofAttack
+	config *config.Config
+}
+
+func (s *spoofStep) Skip(a2aStatus *A2AStatus) string {
+	if a2aStatus.HPKEEnabled {
+		return "payload is HPKE-encrypted"
+	}
+	return ""
+}
+
+func (s *spoofStep) Apply(msg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{}) {
+	if s.config.GetSpoofMode() == config.SpoofModeDuplicate {
+		logger.Info("📨 Forwarding the original untouched - a copy in context %s follows", s.config.SpoofContextID)
+		return nil, msg
+	}
+	return s.attack.ModifyMessage(msg)
+}
+
+func (s *spoofStep) Warn(attackLog *types.AttackLog, a2aStatus *A2AStatus) {
+	logger.Warn("⚠️  Claimed identity rewritten - only a receiver that binds the signing key to the sender catches a re-signed message")
+}
+
+func (s *spoofStep) Headers(outReq *http.Request, body []byte, msg map[string]interface{}, attackLog *types.AttackLog, a2aStatus *A2AStatus) []types.HeaderChange {
+	changes := RewriteHeaders(outReq.Header, body, s.config.DigestPolicy)
+	return append(changes, spoofHeaders(outReq, body, msg, a2aStatus, s.config.SpoofSigner)...)
+}
+
+// Followup sends the copy of duplicate mode, moved to SPOOF_CONTEXT_ID, once
+// the original has been answered
+func (s *spoofStep) Followup(p *ProxyHandler, r *http.Request, ex *exchange) {
+	if s.config.GetSpoofMode() != config.SpoofModeDuplicate || s.Skip(ex.a2aStatus) != "" {
+		return
+	}
+	attackLog, dupMsg := s.attack.ModifyMessage(ex.originalMsg)
+	if attackLog == nil || len(attackLog.Changes) == 0 {
+		return
+	}
+	attackLog.TargetEndpoint = s.config.GetTargetURL()
+	p.forwardDuplicate(r, ex, s, attackLog, dupMsg)
+}
+
+// spoofFinding explains identity_spoofing by whether the receiver binds the
+// signing key to the claimed sender
+func spoofFinding(e *report.Entry) string {
+	if !e.Protection.SAGE {
+		return ""
+	}
+	rewritten := make([]string, len(e.Changes))
+	for i, change := range e.Changes {
+		rewritten[i] = fmt.Sprintf("%s %v -> %v", change.Field, change.OriginalValue, change.ModifiedValue)
+	}
+	switch e.Upstream.Outcome {
+	case report.OutcomeAccepted:
+		return "claimed identity rewritten (" + strings.Join(rewritten, ", ") + "), and the receiver accepted it: nothing binds it to the signing key"
+	case report.OutcomeRejected:
+		return "claimed identity rewritten (" + strings.Join(rewritten, ", ") + "), and the receiver rejected it"
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func TestResign(t *testing.T) {
+	body := []byte(`{"from":"root"}`)
+	req := httptest.NewRequest("POST", "/payment?id=7", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	original, err := sage.SignRequest(req, []byte(`{"from":"planning"}`), sage.DemoKeyPair("planning"), &sage.SignOptions{
+		Components: []string{"@method", `@query-param;name="id"`, "content-digest"},
+	})
+	if err != nil {
+		t.Fatalf("SignRequest() error: %v", err)
+	}
+
+	changes, err := Resign(req, body, original, "medical")
+	if err != nil {
+		t.Fatalf("Resign() error: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Errorf("Expected Signature, Signature-Input and Content-Digest to change, got %+v", changes)
+	}
+
+	params, err := (&sage.Verifier{}).VerifyRequest(req, body)
+	if err != nil {
+		t.Fatalf("Re-signed request should verify: %v", err)
+	}
+	if params.KeyID != sage.DemoKeyID("medical") || !params.CoversQueryParam("id") || params.Covers("@path") {
+		t.Errorf("Re-signed params: got keyid %s, components %v", params.KeyID, params.Components)
+	}
+}
+
+func TestProxyHandler_DuplicateMessage(t *testing.T) {
+	var mu sync.Mutex
+	var contexts []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var msg types.AgentMessage
+		json.Unmarshal(body, &msg)
+		mu.Lock()
+		contexts = append(contexts, msg.ContextID)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"contextId": msg.ContextID})
+	}))
+	defer target.Close()
+
+	handler := NewProxyHandler(&config.Config{
+		AttackEnabled:  true,
+		AttackType:     types.AttackTypeIdentitySpoofing,
+		SpoofMode:      config.SpoofModeDuplicate,
+		SpoofContextID: "ctx-hijacked",
+		TargetAgentURL: target.URL,
+	})
+
+	body, _ := json.Marshal(types.AgentMessage{ID: "msg-1", ContextID: "ctx-001", From: "root", To: "payment", Type: "request"})
+	req := httptest.NewRequest("POST", "/payment", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.HandleRequest(w, req)
+	handler.Wait(context.Background())
+
+	if len(contexts) != 2 || contexts[0] != "ctx-001" || contexts[1] != "ctx-hijacked" {
+		t.Fatalf("Target should get the original, then the copy: got %v", contexts)
+	}
+	var reply map[string]string
+	json.Unmarshal(w.Body.Bytes(), &reply)
+	if reply["contextId"] != "ctx-001" {
+		t.Errorf("Client should get the reply to the original, got %v", reply)
+	}
+
+	entries := handler.Recorder().Entries()
+	if len(entries) != 2 || entries[0].Tampered() || entries[1].Scenario != string(types.AttackTypeIdentitySpoofing) {
+		t.Errorf("Expected an untouched entry and an identity_spoofing entry, got %+v", entries)
+	}
+}
+
+func TestProxyHandler_DuplicateDoesNotDelayClient(t *testing.T) {
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg types.AgentMessage
+		json.NewDecoder(r.Body).Decode(&msg)
+		if msg.ContextID == "ctx-hijacked" {
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	handler := NewProxyHandler(&config.Config{
+		AttackEnabled:  true,
+		AttackType:     types.AttackTypeIdentitySpoofing,
+		SpoofMode:      config.SpoofModeDuplicate,
+		SpoofContextID: "ctx-hijacked",
+		TargetAgentURL: target.URL,
+	})
+
+	body, _ := json.Marshal(types.AgentMessage{ID: "msg-1", ContextID: "ctx-001", From: "root", To: "payment", Type: "request"})
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("POST", "/payment", bytes.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	done := make(chan struct{})
+	go func() {
+		handler.HandleRequest(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("The client's response waited for the duplicate")
+	}
+
+	// The client leaving does not cancel the duplicate
+	cancel()
+	close(release)
+	handler.Wait(context.Background())
+	entries := handler.Recorder().Entries()
+	if len(entries) != 2 || entries[1].Upstream.StatusCode != http.StatusOK {
+		t.Errorf("Expected the duplicate to be answered, got %+v", entries)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/report"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// The stale_signature attack forwards the message untouched, but only once the
+// signature checked by verifiers is no longer fresh. A target that still
+// accepts it does not enforce expires or an acceptance window on created.
+
+// StaleHold returns how long a message signed with sig has to be held at now
+// to arrive StaleMargin seconds past its deadline: expires, or created plus
+// StaleWindow, whichever comes first. ok is false when sig has neither.
+func StaleHold(sig *sage.SignatureParams, cfg *config.Config, now time.Time) (hold time.Duration, ok bool) {
+	if sig == nil {
+		return 0, false
+	}
+	deadline := sig.Deadline(time.Duration(cfg.StaleWindow) * time.Second)
+	if deadline.IsZero() {
+		return 0, false
+	}
+
+	hold = deadline.Add(time.Duration(cfg.StaleMargin) * time.Second).Sub(now)
+	if hold < 0 {
+		hold = 0
+	}
+	return hold, true
+}
+
+// holdStale delays the exchange until its signature is stale and returns an
+// attack log with the ages involved. It returns ctx's error when the client
+// gives up while the message is held.
+func (s *staleStep) holdStale(ctx context.Context, a2aStatus *A2AStatus) (*types.AttackLog, error) {
+	sig := a2aStatus.Signature
+	arrived := time.Now()
+	hold, ok := StaleHold(sig, s.config, arrived)
+	if !ok {
+		logger.Info("⏳ No signature with created or expires - forwarding without holding")
+		return nil, nil
+	}
+	if limit := time.Duration(s.config.StaleMaxHold) * time.Second; hold > limit {
+		logger.Warn("⏳ Holding for %s would exceed STALE_MAX_HOLD (%s) - forwarding without holding", hold.Round(time.Second), limit)
+		return nil, nil
+	}
+
+	logger.Warn("⏳ Holding signed message for %s (signature age %s) until it is no longer fresh",
+		hold.Round(time.Second), sig.Age(arrived).Round(time.Second))
+	timer := time.NewTimer(hold)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	forwarded := time.Now()
+	attackLog := &types.AttackLog{
+		Timestamp:      forwarded,
+		AttackType:     string(types.AttackTypeStaleSignature),
+		TargetEndpoint: s.config.GetTargetURL(),
+		Changes: []types.Change{
+			{Field: "delivery delay", OriginalValue: "0s", ModifiedValue: forwarded.Sub(arrived).Round(time.Second).String()},
+		},
+	}
+	if !sig.Created.IsZero() {
+		attackLog.Changes = append(attackLog.Changes, types.Change{
+			Field:         "signature age",
+			OriginalValue: sig.Age(arrived).Round(time.Second).String(),
+			ModifiedValue: sig.Age(forwarded).Round(time.Second).String(),
+		})
+	}
+	if !sig.Expires.IsZero() {
+		attackLog.Changes = append(attackLog.Changes, types.Change{
+			Field:         "time to expires",
+			OriginalValue: sig.Expires.Sub(arrived).Round(time.Second).String(),
+			ModifiedValue: sig.Expires.Sub(forwarded).Round(time.Second).String(),
+		})
+	}
+	return attackLog, nil
+}
+
+// staleStep is stale_signature: the body is left alone and the message is
+// held instead (see holdStale)
+type staleStep struct {
+	config *config.Config
+}
+
+func (s *staleStep) Skip(a2aStatus *A2AStatus) string {
+	if a2aStatus.Signature == nil {
+		return "message is not signed"
+	}
+	return ""
+}
+
+func (s *staleStep) Apply(msg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{}) {
+	logger.Info("⏳ Body forwarded untouched - the message is held until its signature is stale")
+	return nil, msg
+}
+
+func (s *staleStep) Warn(attackLog *types.AttackLog, a2aStatus *A2AStatus) {}
+
+func (s *staleStep) Headers(outReq *http.Request, body []byte, msg map[string]interface{}, attackLog *types.AttackLog, a2aStatus *A2AStatus) []types.HeaderChange {
+	return nil
+}
+
+func (s *staleStep) TamperRequest(ctx context.Context, outReq *http.Request, a2aStatus *A2AStatus, attackLog *types.AttackLog) (*types.AttackLog, error) {
+	held, err := s.holdStale(ctx, a2aStatus)
+	if err != nil || held == nil {
+		return attackLog, err
+	}
+	if attackLog == nil {
+		return held, nil
+	}
+	attackLog.Changes = append(attackLog.Changes, held.Changes...)
+	return attackLog, nil
+}
+
+// NeedsBody is false: the message is held whatever its body
+func (s *staleStep) NeedsBody() bool {
+	return false
+}
+
+// staleFinding explains stale_signature by whether the receiver enforces
+// freshness
+func staleFinding(e *report.Entry) string {
+	if !e.Protection.SAGE {
+		return ""
+	}
+	ages := make([]string, 0, len(e.Changes))
+	for _, change := range e.Changes {
+		ages = append(ages, fmt.Sprintf("%s %v", change.Field, change.ModifiedValue))
+	}
+	switch e.Upstream.Outcome {
+	case report.OutcomeAccepted:
+		return "signed message was held until stale (" + strings.Join(ages, ", ") + "), and the receiver still accepted it: it does not enforce freshness"
+	case report.OutcomeRejected:
+		return "signed message was held until stale (" + strings.Join(ages, ", ") + "), and the receiver rejected it: it enforces freshness"
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func TestStaleHold(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cfg := &config.Config{StaleWindow: 300, StaleMargin: 1}
+
+	tests := []struct {
+		name string
+		sig  *sage.SignatureParams
+		hold time.Duration
+		ok   bool
+	}{
+		{"unsigned", nil, 0, false},
+		{"no created or expires", &sage.SignatureParams{}, 0, false},
+		{"window after created", &sage.SignatureParams{Created: now.Add(-10 * time.Second)}, 291 * time.Second, true},
+		{"expires first", &sage.SignatureParams{Created: now, Expires: now.Add(30 * time.Second)}, 31 * time.Second, true},
+		{"already stale", &sage.SignatureParams{Created: now.Add(-time.Hour)}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hold, ok := StaleHold(tt.sig, cfg, now)
+			if hold != tt.hold || ok != tt.ok {
+				t.Errorf("StaleHold() = %v, %v; want %v, %v", hold, ok, tt.hold, tt.ok)
+			}
+		})
+	}
+}
+
+func TestProxyHandler_StaleSignature(t *testing.T) {
+	var forwarded []byte
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	handler := NewProxyHandler(&config.Config{
+		AttackEnabled:  true,
+		AttackType:     types.AttackTypeStaleSignature,
+		TargetAgentURL: target.URL,
+		StaleWindow:    10,
+		StaleMaxHold:   60,
+	})
+
+	// Signed 20s ago with a 10s window: already stale, so no actual wait
+	body := []byte(`{"id":"msg-1","from":"root","to":"payment","type":"request","content":"pay 100"}`)
+	req := httptest.NewRequest("POST", "/payment", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := sage.SignRequest(req, body, sage.DemoKeyPair("root"), &sage.SignOptions{Created: time.Now().Add(-20 * time.Second)}); err != nil {
+		t.Fatalf("SignRequest() error: %v", err)
+	}
+	handler.HandleRequest(httptest.NewRecorder(), req)
+
+	if !bytes.Equal(forwarded, body) {
+		t.Errorf("Body should be forwarded untouched, got %s", forwarded)
+	}
+
+	entry := handler.Recorder().Entries()[0]
+	if entry.Scenario != string(types.AttackTypeStaleSignature) {
+		t.Fatalf("Entry scenario: got %s", entry.Scenario)
+	}
+	if len(entry.Changes) != 2 || entry.Changes[0].Field != "delivery delay" || entry.Changes[1].Field != "signature age" {
+		t.Errorf("Changes should record the signature age, got %+v", entry.Changes)
+	}
+}
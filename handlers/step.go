@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/attacks"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/report"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// attackStep is one attack as the gateway runs it: the modifier applies it to
+// the decoded message, and the proxy warns about and re-signs or strips the
+// headers of what it changed. A pipeline runs its steps through the same
+// methods, so a chained attack behaves like the attack alone. A new attack is
+// a new step in attackSteps rather than another branch in the modifier or the
+// proxy.
+type attackStep interface {
+	// Apply tampers with the decoded message; a nil log means it does not apply
+	Apply(msg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{})
+
+	// Warn tells how the receiver is expected to react to the tampered message
+	Warn(attackLog *types.AttackLog, a2aStatus *A2AStatus)
+
+	// Headers brings the headers of the outgoing request, which already
+	// carries the re-encoded body, in line with the attack
+	Headers(outReq *http.Request, body []byte, msg map[string]interface{}, attackLog *types.AttackLog, a2aStatus *A2AStatus) []types.HeaderChange
+}
+
+// conditionalStep is implemented by steps that only apply to some messages
+type conditionalStep interface {
+	// Skip returns why the step does not apply to the message, "" when it does
+	Skip(a2aStatus *A2AStatus) string
+}
+
+// requestStep is implemented by steps that also act on the outgoing request
+// itself once its body and headers are settled, such as changing the path or
+// holding the request
+type requestStep interface {
+	// TamperRequest acts on outReq and returns attackLog with its changes
+	// added, or a new log when attackLog is nil and something changed. It
+	// returns ctx's error when the client gives up meanwhile.
+	TamperRequest(ctx context.Context, outReq *http.Request, a2aStatus *A2AStatus, attackLog *types.AttackLog) (*types.AttackLog, error)
+
+	// NeedsBody reports whether the step only acts on messages whose body was
+	// decoded; the others also act on streamed and undecodable bodies
+	NeedsBody() bool
+}
+
+// followupStep is implemented by steps that send more messages of their own
+// once the client has its answer
+type followupStep interface {
+	Followup(p *ProxyHandler, r *http.Request, ex *exchange)
+}
+
+// attackSteps builds the step of each attack type but pipeline (see newStep)
+var attackSteps = map[types.AttackType]func(m *MessageModifier) attackStep{
+	types.AttackTypePriceManipulation:   func(m *MessageModifier) attackStep { return m.bodyStep(types.AttackTypePriceManipulation) },
+	types.AttackTypeAddressManipulation: func(m *MessageModifier) attackStep { return m.bodyStep(types.AttackTypeAddressManipulation) },
+	types.AttackTypeProductSubstitution: func(m *MessageModifier) attackStep { return m.bodyStep(types.AttackTypeProductSubstitution) },
+	types.AttackTypePromptInjection:     func(m *MessageModifier) attackStep { return m.bodyStep(types.AttackTypePromptInjection) },
+	types.AttackTypeSalami:              func(m *MessageModifier) attackStep { return m.bodyStep(types.AttackTypeSalami) },
+	types.AttackTypeStripSignature: func(m *MessageModifier) attackStep {
+		// In a pipeline the other steps tamper with the body
+		if m.RunsPipeline() {
+			return &stripStep{}
+		}
+		return &stripStep{tamper: m.bodyStep(m.config.GetStripSignatureTamper())}
+	},
+	types.AttackTypeUncoveredComponents: func(m *MessageModifier) attackStep {
+		return &uncoveredStep{tamper: m.bodyStep(m.config.GetCoverageBodyTamper()), config: m.config}
+	},
+	types.AttackTypeHPKEDowngrade: func(m *MessageModifier) attackStep {
+		return &downgradeStep{attack: m.downgradeAttack, config: m.config}
+	},
+	types.AttackTypeStaleSignature: func(m *MessageModifier) attackStep { return &staleStep{config: m.config} },
+	types.AttackTypeIdentitySpoofing: func(m *MessageModifier) attackStep {
+		return &spoofStep{attack: m.spoofAttack, config: m.config}
+	},
+}
+
+// newStep returns the step of an attack type, nil when unknown. A pipeline is
+// built from the steps in attackSteps, so it has no entry of its own.
+func (m *MessageModifier) newStep(attackType types.AttackType) attackStep {
+	if attackType == types.AttackTypePipeline {
+		return m.pipelineStep()
+	}
+	if newStep := attackSteps[attackType]; newStep != nil {
+		return newStep(m)
+	}
+	return nil
+}
+
+// attackFindings explain what the outcome of an attack says about the
+// receiver, for the attacks whose verdict alone does not tell the story
+var attackFindings = map[types.AttackType]report.FindingFunc{
+	types.AttackTypePromptInjection:     injectionFinding,
+	types.AttackTypeSalami:              salamiFinding,
+	types.AttackTypeStripSignature:      stripFinding,
+	types.AttackTypeUncoveredComponents: uncoveredFinding,
+	types.AttackTypeHPKEDowngrade:       downgradeFinding,
+	types.AttackTypeStaleSignature:      staleFinding,
+	types.AttackTypeIdentitySpoofing:    spoofFinding,
+	types.AttackTypePipeline:            pipelineFinding,
+}
+
+// Finding is the report.FindingFunc of the gateway: it explains the outcome
+// of an exchange with the finding of its attack type
+func Finding(e *report.Entry) string {
+	if finding := attackFindings[types.AttackType(e.Scenario)]; finding != nil {
+		return finding(e)
+	}
+	return ""
+}
+
+// bodyStep changes fields of the decoded message with one of the body
+// attacks. An HPKE-encrypted payload gets its ciphertext bit-flipped instead.
+type bodyStep struct {
+	attackType types.AttackType
+	attack     interface {
+		ModifyMessage(map[string]interface{}) (*types.AttackLog, map[string]interface{})
+	}
+	encrypted *attacks.EncryptedAttack
+	config    *config.Config
+}
+
+// bodyStep returns the step of a body attack
+func (m *MessageModifier) bodyStep(attackType types.AttackType) *bodyStep {
+	s := &bodyStep{attackType: attackType, encrypted: m.encryptedAttack, config: m.config}
+	switch attackType {
+	case types.AttackTypePriceManipulation:
+		s.attack = m.priceAttack
+	case types.AttackTypeAddressManipulation:
+		s.attack = m.addressAttack
+	case types.AttackTypeProductSubstitution:
+		s.attack = m.productAttack
+	case types.AttackTypePromptInjection:
+		s.attack = m.injectionAttack
+	case types.AttackTypeSalami:
+		s.attack = m.salamiAttack
+	}
+	return s
+}
+
+func (s *bodyStep) Apply(msg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{}) {
+	return s.tamper(msg, a2aStatus, "⚠️  SAGE signature detected - JSON modification will invalidate signature")
+}
+
+// tamper applies the attack, logging sageWarning when the message is signed
+func (s *bodyStep) tamper(msg map[string]interface{}, a2aStatus *A2AStatus, sageWarning string) (*types.AttackLog, map[string]interface{}) {
+	if a2aStatus.HPKEEnabled {
+		logger.Info("🔐 HPKE detected - applying encrypted payload bit-flip attack")
+		return s.encrypted.ModifyMessage(msg)
+	}
+
+	logger.Info("📝 No HPKE - applying JSON modification attack: %s", s.attackType)
+	if a2aStatus.SAGEEnabled {
+		logger.Warn("%s", sageWarning)
+	}
+	if s.attack == nil {
+		logger.Warn("Unknown attack type: %s, passing message through", s.attackType)
+		return nil, msg
+	}
+	return s.attack.ModifyMessage(msg)
+}
+
+func (s *bodyStep) Warn(attackLog *types.AttackLog, a2aStatus *A2AStatus) {
+	switch {
+	case a2aStatus.SAGEEnabled && a2aStatus.HPKEEnabled:
+		logger.Warn("⚠️  Target agent will REJECT this request due to:")
+		logger.Warn("   - Signature verification failure (signature invalidated)")
+		logger.Warn("   - HPKE decryption failure (integrity check will fail)")
+	case a2aStatus.SAGEEnabled:
+		logger.Warn("⚠️  Target agent will REJECT this request due to signature verification failure")
+	case a2aStatus.HPKEEnabled:
+		logger.Warn("⚠️  Target agent will FAIL to decrypt this message (HPKE integrity broken)")
+	}
+}
+
+func (s *bodyStep) Headers(outReq *http.Request, body []byte, msg map[string]interface{}, attackLog *types.AttackLog, a2aStatus *A2AStatus) []types.HeaderChange {
+	return RewriteHeaders(outReq.Header, body, s.config.DigestPolicy)
+}
+
+// injectionFinding explains prompt_injection: only free text changes, which
+// the receiver's business rules cannot catch
+func injectionFinding(e *report.Entry) string {
+	fields := strings.Join(e.ChangedFields(), ", ")
+	switch {
+	case e.Upstream.Outcome == report.OutcomeAccepted:
+		return "instructions injected into " + fields + " reached the agent: no structured field changed, only a signature over the body reveals it"
+	case e.Upstream.Outcome == report.OutcomeRejected && e.Protection.SAGE:
+		return "instructions injected into " + fields + ", and the receiver rejected it: the signature covers the text"
+	}
+	return ""
+}
+
+// salamiFinding explains salami: changes small enough to pass business rules
+func salamiFinding(e *report.Entry) string {
+	impact := "payment fields changed"
+	for _, change := range e.Changes {
+		if change.Field == "economic impact" {
+			impact = fmt.Sprint(change.ModifiedValue)
+		}
+	}
+	switch {
+	case e.Upstream.Outcome == report.OutcomeAccepted:
+		return "subtle tampering accepted (" + impact + "): it passes the receiver's business rules"
+	case e.Upstream.Outcome == report.OutcomeRejected && e.Protection.SAGE:
+		return "subtle tampering rejected (" + impact + "): the signature covers the amounts"
+	}
+	return ""
+}
+
+// stripStep is strip_signature: the body is tampered with by
+// STRIP_SIGNATURE_TAMPER and the signature headers are removed, so the
+// message passes for an unsigned one. Without tamper, as in a pipeline, only
+// the signature is removed.
+type stripStep struct {
+	tamper *bodyStep
+}
+
+func (s *stripStep) Skip(a2aStatus *A2AStatus) string {
+	if s.tamper == nil && a2aStatus.Signature == nil {
+		return "message is not signed"
+	}
+	return ""
+}
+
+func (s *stripStep) Apply(msg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{}) {
+	if s.tamper == nil {
+		logger.Info("✂️  Stripping the SAGE signature - the message will look unsigned")
+		return &types.AttackLog{
+			Timestamp:  time.Now(),
+			AttackType: string(types.AttackTypeStripSignature),
+			Changes: []types.Change{
+				{Field: "signature", OriginalValue: "keyid " + a2aStatus.Signature.KeyID, ModifiedValue: "stripped"},
+			},
+		}, msg
+	}
+
+	attackLog, modifiedMsg := s.tamper.tamper(msg, a2aStatus, "✂️  SAGE signature detected - stripping it so the message looks unsigned")
+	if attackLog != nil {
+		attackLog.AttackType = string(types.AttackTypeStripSignature)
+	}
+	return attackLog, modifiedMsg
+}
+
+func (s *stripStep) Warn(attackLog *types.AttackLog, a2aStatus *A2AStatus) {
+	if a2aStatus.SAGEEnabled {
+		logger.Warn("⚠️  Signature stripped - only a target that REQUIRES signatures will reject this request")
+		return
+	}
+	if s.tamper != nil {
+		s.tamper.Warn(attackLog, a2aStatus)
+	}
+}
+
+func (s *stripStep) Headers(outReq *http.Request, body []byte, msg map[string]interface{}, attackLog *types.AttackLog, a2aStatus *A2AStatus) []types.HeaderChange {
+	return append(RewriteHeaders(outReq.Header, body, DigestKeep), StripSignature(outReq.Header)...)
+}
+
+// stripFinding explains strip_signature by whether the receiver insists on
+// signatures
+func stripFinding(e *report.Entry) string {
+	if !e.Protection.SAGE {
+		return ""
+	}
+	switch e.Upstream.Outcome {
+	case report.OutcomeAccepted:
+		return "signed message was stripped and tampered, and the receiver accepted it as unsigned: it only verifies signatures if present"
+	case report.OutcomeRejected:
+		return "signed message was stripped and tampered, and the receiver rejected it: it requires signatures"
+	}
+	return ""
+}
+
+// downgradeStep is hpke_downgrade: the HPKE envelope is replaced by forged
+// plaintext
+type downgradeStep struct {
+	attack *attacks.DowngradeAttack
+	config *config.Config
+}
+
+func (s *downgradeStep) Skip(a2aStatus *A2AStatus) string {
+	if !a2aStatus.HPKEEnabled {
+		return "message is already plaintext"
+	}
+	return ""
+}
+
+func (s *downgradeStep) Apply(msg map[string]interface{}, a2aStatus *A2AStatus) (*types.AttackLog, map[string]interface{}) {
+	logger.Info("🔐 HPKE detected - replacing encrypted payload with forged plaintext")
+	return s.attack.ModifyMessage(msg)
+}
+
+func (s *downgradeStep) Warn(attackLog *types.AttackLog, a2aStatus *A2AStatus) {
+	logger.Warn("⚠️  HPKE envelope replaced by plaintext - only a target that REQUIRES encryption will reject this request")
+	if a2aStatus.SAGEEnabled {
+		logger.Warn("   - Signature verification will also fail (signed body replaced)")
+	}
+}
+
+func (s *downgradeStep) Headers(outReq *http.Request, body []byte, msg map[string]interface{}, attackLog *types.AttackLog, a2aStatus *A2AStatus) []types.HeaderChange {
+	return RewriteHeaders(outReq.Header, body, s.config.DigestPolicy)
+}
+
+// downgradeFinding explains hpke_downgrade by whether the receiver insists on
+// encryption
+func downgradeFinding(e *report.Entry) string {
+	if !e.Protection.HPKE {
+		return ""
+	}
+	switch e.Upstream.Outcome {
+	case report.OutcomeAccepted:
+		return "HPKE message was replaced by forged plaintext, and the receiver accepted it: it does not require encryption"
+	case report.OutcomeRejected:
+		return "HPKE message was replaced by forged plaintext, and the receiver rejected it"
+	}
+	return ""
+}
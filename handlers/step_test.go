@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/report"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func findingEntry(sageOn bool, status int, body string) *report.Entry {
+	attackLog := &types.AttackLog{
+		Timestamp:  time.Now(),
+		AttackType: "price_manipulation",
+		Changes: []types.Change{
+			{Field: "amount", OriginalValue: 100.0, ModifiedValue: 10000.0},
+		},
+	}
+	upstream := &types.UpstreamResult{
+		StatusCode: status,
+		Body:       body,
+		Outcome:    report.ClassifyOutcome(status, []byte(body)),
+	}
+	return report.NewEntry(attackLog, nil, "/payment", "http://localhost:19083", report.Protection{SAGE: sageOn}, upstream)
+}
+
+func TestAttackStep_Finding(t *testing.T) {
+	strip := func(sageOn bool, status int) *report.Entry {
+		e := findingEntry(sageOn, status, "")
+		e.Scenario = string(types.AttackTypeStripSignature)
+		return e
+	}
+
+	if f := Finding(strip(true, 200)); !strings.Contains(f, "only verifies signatures if present") {
+		t.Errorf("Accepted stripped message: got finding %q", f)
+	}
+	if f := Finding(strip(true, 401)); !strings.Contains(f, "requires signatures") {
+		t.Errorf("Rejected stripped message: got finding %q", f)
+	}
+	if f := Finding(strip(false, 200)); f != "" {
+		t.Errorf("Unsigned message has nothing to strip, got finding %q", f)
+	}
+
+	downgrade := findingEntry(false, 200, "")
+	downgrade.Scenario = string(types.AttackTypeHPKEDowngrade)
+	downgrade.Protection.HPKE = true
+	if f := Finding(downgrade); !strings.Contains(f, "does not require encryption") {
+		t.Errorf("Accepted downgrade: got finding %q", f)
+	}
+
+	uncovered := findingEntry(true, 200, "")
+	uncovered.Scenario = string(types.AttackTypeUncoveredComponents)
+	uncovered.Changes = []types.Change{{Field: "header X-Forwarded-User"}, {Field: "@path"}}
+	if f := Finding(uncovered); !strings.Contains(f, "signature still valid, but header X-Forwarded-User, @path changed") {
+		t.Errorf("Accepted uncovered tampering: got finding %q", f)
+	}
+
+	stale := findingEntry(true, 401, "")
+	stale.Scenario = string(types.AttackTypeStaleSignature)
+	stale.Changes = []types.Change{{Field: "signature age", OriginalValue: "2s", ModifiedValue: "302s"}}
+	if f := Finding(stale); !strings.Contains(f, "signature age 302s") || !strings.Contains(f, "enforces freshness") {
+		t.Errorf("Rejected stale signature: got finding %q", f)
+	}
+
+	spoof := findingEntry(true, 200, "")
+	spoof.Scenario = string(types.AttackTypeIdentitySpoofing)
+	spoof.Changes = []types.Change{{Field: "from", OriginalValue: "planning", ModifiedValue: "root"}}
+	if f := Finding(spoof); !strings.Contains(f, "from planning -> root") || !strings.Contains(f, "nothing binds it") {
+		t.Errorf("Accepted identity spoofing: got finding %q", f)
+	}
+
+	injection := findingEntry(true, 401, "")
+	injection.Scenario = string(types.AttackTypePromptInjection)
+	injection.Changes = []types.Change{{Field: "content"}}
+	if f := Finding(injection); !strings.Contains(f, "into content") || !strings.Contains(f, "signature covers the text") {
+		t.Errorf("Rejected prompt injection: got finding %q", f)
+	}
+
+	salami := findingEntry(false, 200, "")
+	salami.Scenario = string(types.AttackTypeSalami)
+	salami.Changes = []types.Change{{Field: "metadata.amount"}, {Field: "economic impact", ModifiedValue: "120 KRW -> 130 KRW"}}
+	if f := Finding(salami); !strings.Contains(f, "120 KRW -> 130 KRW") || !strings.Contains(f, "business rules") {
+		t.Errorf("Accepted salami: got finding %q", f)
+	}
+
+	pipeline := findingEntry(false, 200, "")
+	pipeline.Scenario = string(types.AttackTypePipeline)
+	pipeline.Steps = []types.PipelineStep{
+		{AttackType: "strip_signature", Status: "skipped", Reason: "message is not signed"},
+		{AttackType: "price_manipulation", Status: "applied"},
+		{AttackType: "prompt_injection", Status: "applied"},
+	}
+	if f := Finding(pipeline); f != "pipeline price_manipulation -> prompt_injection reached the receiver; skipped: strip_signature (message is not signed)" {
+		t.Errorf("Accepted pipeline: got finding %q", f)
+	}
+
+	if f := Finding(findingEntry(true, 200, "")); f != "" {
+		t.Errorf("Body attacks other than injection and salami should have no finding, got %q", f)
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/tlsutil"
+)
+
+// upstreamTLSConfig builds the TLS settings for https agents: extra CA
+// bundles, an mTLS client certificate, or skipped verification. nil keeps
+// Go's defaults.
+func upstreamTLSConfig(cfg *config.Config) *tls.Config {
+	if cfg.UpstreamTLSCAFile == "" && cfg.UpstreamTLSCertFile == "" && !cfg.UpstreamTLSInsecure {
+		return nil
+	}
+	tlsConfig, err := tlsutil.ClientConfig(cfg.UpstreamTLSCAFile, cfg.UpstreamTLSCertFile, cfg.UpstreamTLSKeyFile, cfg.UpstreamTLSInsecure)
+	if err != nil {
+		logger.Error("Upstream TLS settings ignored: %v", err)
+		return nil
+	}
+	if cfg.UpstreamTLSInsecure {
+		logger.Warn("⚠️  Agent certificates are not verified (UPSTREAM_TLS_INSECURE)")
+	}
+	return tlsConfig
+}
+
+// upstreamTransport returns the transport dialing agents with tlsConfig.
+// headerTimeout bounds the wait for response headers only (0 = no limit), so
+// a streamed response body may take as long as the agent needs.
+func upstreamTransport(tlsConfig *tls.Config, headerTimeout time.Duration) http.RoundTripper {
+	if tlsConfig == nil && headerTimeout == 0 {
+		return http.DefaultTransport
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.ResponseHeaderTimeout = headerTimeout
+	return transport
+}
@@ -117,13 +117,36 @@ func LogAttack(attackLog *types.AttackLog) {
 	attackLogger.Printf("Type: %s", attackLog.AttackType)
 	attackLogger.Printf("Timestamp: %s", attackLog.Timestamp.Format(time.RFC3339))
 	attackLogger.Printf("Target Endpoint: %s", attackLog.TargetEndpoint)
+	if a := attackLog.Activation; a != nil {
+		attackLogger.Printf("Activation: #%d %s (seed %d)", a.Sequence, a.Reason, a.Seed)
+	}
 	attackLogger.Println("Changes:")
 
 	for _, change := range attackLog.Changes {
-		attackLogger.Printf("  - Field: %s", change.Field)
+		if change.Step != "" {
+			attackLogger.Printf("  - Field: %s (step %s)", change.Field, change.Step)
+		} else {
+			attackLogger.Printf("  - Field: %s", change.Field)
+		}
 		attackLogger.Printf("    Original: %v", change.OriginalValue)
 		attackLogger.Printf("    Modified: %v", change.ModifiedValue)
 	}
+	if len(attackLog.HeaderChanges) > 0 {
+		attackLogger.Println("Header Changes:")
+		for _, change := range attackLog.HeaderChanges {
+			attackLogger.Printf("  - %s (%s): %q -> %q", change.Header, change.Action, change.OriginalValue, change.ModifiedValue)
+		}
+	}
+	if len(attackLog.Steps) > 0 {
+		attackLogger.Println("Pipeline Steps:")
+		for i, step := range attackLog.Steps {
+			if step.Reason != "" {
+				attackLogger.Printf("  %d. %s: %s (%s)", i+1, step.AttackType, step.Status, step.Reason)
+			} else {
+				attackLogger.Printf("  %d. %s: %s", i+1, step.AttackType, step.Status)
+			}
+		}
+	}
 
 	attackLogger.Println("===========================")
 
@@ -136,11 +159,124 @@ func LogAttack(attackLog *types.AttackLog) {
 			"original_msg":    attackLog.OriginalMsg,
 			"modified_msg":    attackLog.ModifiedMsg,
 			"changes":         attackLog.Changes,
+			"header_changes":  attackLog.HeaderChanges,
+			"steps":           attackLog.Steps,
+			"activation":      attackLog.Activation,
 		}
 		wsHub.BroadcastLog("warn", "attack", "Attack detected: "+attackLog.AttackType, data)
 	}
 }
 
+// LogActivation logs whether an enabled attack fired for a message
+func LogActivation(a *types.Activation) {
+	if logLevel > INFO {
+		return
+	}
+	outcome := "🎲 Attack fired"
+	if !a.Fired {
+		outcome = "🎲 Attack skipped"
+	}
+	message := fmt.Sprintf("%s (#%d, seed %d): %s", outcome, a.Sequence, a.Seed, a.Reason)
+	infoLogger.Print(message)
+
+	if wsHub != nil {
+		data := map[string]interface{}{
+			"fired":    a.Fired,
+			"reason":   a.Reason,
+			"seed":     a.Seed,
+			"sequence": a.Sequence,
+			"roll":     a.Roll,
+		}
+		wsHub.BroadcastLog("info", "activation", message, data)
+	}
+}
+
+// LogRoute logs the routing decision for a request
+func LogRoute(method, uri, route, reason, target string) {
+	if logLevel > INFO {
+		return
+	}
+	message := fmt.Sprintf("Route %s (%s): %s %s -> %s", route, reason, method, uri, target)
+	infoLogger.Print(message)
+
+	if wsHub != nil {
+		data := map[string]interface{}{
+			"method": method,
+			"uri":    uri,
+			"route":  route,
+			"reason": reason,
+			"target": target,
+		}
+		wsHub.BroadcastLog("info", "route", message, data)
+	}
+}
+
+// LogBreaker logs a circuit breaker state change
+func LogBreaker(upstream, from, to, reason string) {
+	message := fmt.Sprintf("Circuit breaker %s: %s -> %s (%s)", upstream, from, to, reason)
+	level := "info"
+	if to == "open" {
+		level = "warn"
+		infoLogger.Print("[WARN] " + message)
+	} else if logLevel <= INFO {
+		infoLogger.Print(message)
+	}
+
+	if wsHub != nil {
+		data := map[string]interface{}{
+			"upstream": upstream,
+			"from":     from,
+			"to":       to,
+			"reason":   reason,
+		}
+		wsHub.BroadcastLog(level, "circuit_breaker", message, data)
+	}
+}
+
+// LogUpstreamAttempt logs one attempt of the retrying client. The console line
+// is printed at debug level; the WebSocket event is always sent so a retried
+// request can be followed body by body.
+func LogUpstreamAttempt(attempt *types.UpstreamAttempt) {
+	outcome := attempt.Error
+	if outcome == "" {
+		outcome = fmt.Sprintf("HTTP %d", attempt.StatusCode)
+	}
+	message := fmt.Sprintf("Attempt %d: %s %s (%d bytes, sha256 %.12s) -> %s in %dms",
+		attempt.Attempt, attempt.Method, attempt.URL, attempt.BodySize, attempt.BodySHA256, outcome, attempt.DurationMs)
+	if logLevel <= DEBUG {
+		debugLogger.Print(message)
+	}
+
+	if wsHub != nil {
+		level := "info"
+		if attempt.Error != "" || attempt.StatusCode >= 500 {
+			level = "warn"
+		}
+		data := map[string]interface{}{
+			"attempt": attempt,
+		}
+		wsHub.BroadcastLog(level, "upstream_attempt", message, data)
+	}
+}
+
+// LogAttackResult logs how the target agent answered a tampered message
+func LogAttackResult(attackLog *types.AttackLog, verdict string) {
+	if attackLog.Upstream == nil {
+		return
+	}
+	attackLogger.Printf("Result: %s (upstream %d, %s)", verdict, attackLog.Upstream.StatusCode, attackLog.Upstream.Outcome)
+
+	if wsHub != nil {
+		data := map[string]interface{}{
+			"attack_type":     attackLog.AttackType,
+			"target_endpoint": attackLog.TargetEndpoint,
+			"verdict":         verdict,
+			"upstream":        attackLog.Upstream,
+		}
+		wsHub.BroadcastLog("info", "attack_result", "Attack result: "+verdict, data)
+	}
+}
+
 // LogAttackSimple logs a simple attack message
 func LogAttackSimple(format string, v ...interface{}) {
 	attackLogger.Printf(format, v...)
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/handlers"
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/logger"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/tlsutil"
 	"github.com/sage-x-project/sage-gateway-infected-for-demo/websocket"
 )
 
@@ -54,38 +58,158 @@ func main() {
 	// Create proxy handler
 	proxyHandler := handlers.NewProxyHandler(cfg)
 
-	// Setup HTTP routes
-	http.HandleFunc("/", proxyHandler.HandleRequest)
-	http.HandleFunc("/payment", proxyHandler.HandleRequest)
-	http.HandleFunc("/order", proxyHandler.HandleRequest)
-	http.HandleFunc("/process", proxyHandler.HandleRequest)
-	http.HandleFunc("/health", proxyHandler.HandleHealth)
-	http.HandleFunc("/status", proxyHandler.HandleStatus)
+	// Probe load-balanced agent replicas until shutdown
+	healthCtx, stopHealthChecks := context.WithCancel(context.Background())
+	go proxyHandler.Balancer().Run(healthCtx)
+
+	// Setup HTTP routes. Every other path reaches the proxy handler, whose
+	// routing table picks the target agent.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", proxyHandler.HandleRequest)
+	mux.HandleFunc("/payment", proxyHandler.HandleRequest)
+	mux.HandleFunc("/order", proxyHandler.HandleRequest)
+	mux.HandleFunc("/process", proxyHandler.HandleRequest)
+	mux.HandleFunc("/health", proxyHandler.HandleHealth)
+	mux.HandleFunc("/status", proxyHandler.HandleStatus)
+	mux.HandleFunc("/metrics", proxyHandler.HandleMetrics)
+	mux.HandleFunc("/api/report", proxyHandler.Recorder().HandleReport)
+	mux.HandleFunc("/api/capture", proxyHandler.Capture().HandleCapture)
 
 	// WebSocket endpoint for log streaming
-	http.HandleFunc("/ws/logs", wsHub.ServeWS)
+	mux.HandleFunc("/ws/logs", wsHub.ServeWS)
 
 	// Start server
 	addr := ":" + cfg.GatewayPort
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	scheme, wsScheme := "http", "ws"
+	if cfg.TLSEnabled {
+		tlsConfig, err := listenerTLS(cfg)
+		if err != nil {
+			fmt.Printf("\n❌ TLS Error:\n%v\n\n", err)
+			os.Exit(1)
+		}
+		server.TLSConfig = tlsConfig
+		scheme, wsScheme = "https", "wss"
+	}
+
 	logger.Info("Gateway server starting on port %s", cfg.GatewayPort)
-	logger.Info("Listening on http://localhost%s", addr)
-	logger.Info("WebSocket endpoint: ws://localhost%s/ws/logs", addr)
+	logger.Info("Listening on %s://localhost%s", scheme, addr)
+	logger.Info("WebSocket endpoint: %s://localhost%s/ws/logs", wsScheme, addr)
+	logger.Info("SAGE ON/OFF report: %s://localhost%s/api/report?format=html", scheme, addr)
 
-	// Setup graceful shutdown
+	serverErr := make(chan error, 1)
 	go func() {
-		if err := http.ListenAndServe(addr, nil); err != nil && err != http.ErrServerClosed {
-			logger.Error("Server error: %v", err)
-			os.Exit(1)
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serverErr <- err
 		}
 	}()
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal or server failure
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
 
-	logger.Info("Shutting down gateway server...")
-	os.Exit(0)
+	select {
+	case sig := <-sigChan:
+		logger.Info("Received %s, shutting down gateway server...", sig)
+	case err := <-serverErr:
+		logger.Error("Server error: %v", err)
+		os.Exit(1)
+	}
+
+	stopHealthChecks()
+	shutdownErr := shutdown(server, proxyHandler, wsHub, time.Duration(cfg.ShutdownTimeout)*time.Second)
+	if shutdownErr != nil {
+		logger.Error("Graceful shutdown incomplete: %v", shutdownErr)
+	}
+
+	// Export the report and capture once no more exchanges can be recorded,
+	// even when the drain timed out: that is when they matter most
+	if cfg.ReportFile != "" {
+		if err := proxyHandler.Recorder().Export(cfg.ReportFile); err != nil {
+			logger.Error("Failed to export report to %s: %v", cfg.ReportFile, err)
+		} else {
+			logger.Info("Report written to %s", cfg.ReportFile)
+		}
+	}
+	if cfg.CaptureFile != "" {
+		if err := proxyHandler.Capture().WriteFile(cfg.CaptureFile); err != nil {
+			logger.Error("Failed to write HAR capture to %s: %v", cfg.CaptureFile, err)
+		} else {
+			logger.Info("HAR capture written to %s", cfg.CaptureFile)
+		}
+	}
+	if shutdownErr != nil {
+		os.Exit(1)
+	}
+
+	logger.Info("Gateway server stopped")
+}
+
+// shutdown drains in-flight requests and the exchanges the gateway sent on
+// its own, then closes WebSocket clients
+// All phases share the same drain timeout.
+func shutdown(server *http.Server, proxyHandler *handlers.ProxyHandler, wsHub *websocket.Hub, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Stop accepting new connections and wait for in-flight proxied requests.
+	// The hub stays up meanwhile so the drain is still visible to log viewers.
+	logger.Info("Draining in-flight requests (timeout: %s)...", timeout)
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warn("Drain timeout reached, closing remaining connections: %v", err)
+		server.Close()
+	}
+	if err := proxyHandler.Wait(ctx); err != nil {
+		logger.Warn("Drain timeout reached with duplicates still in flight: %v", err)
+	}
+
+	// Detach the hub from the logger before it stops accepting events
+	logger.SetWebSocketHub(nil)
+
+	if err := wsHub.Shutdown(ctx); err != nil {
+		return fmt.Errorf("websocket hub shutdown: %w", err)
+	}
+
+	return nil
+}
+
+// listenerTLS builds the HTTPS settings of the gateway. A missing server
+// certificate or client CA bundle is taken from the demo CA in TLS_DEMO_DIR,
+// which is generated on first run.
+func listenerTLS(cfg *config.Config) (*tls.Config, error) {
+	certFile, keyFile, clientCAs := cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile
+	needsClientCA := cfg.TLSClientAuth != "" && cfg.TLSClientAuth != tlsutil.ClientAuthNone
+
+	if certFile == "" || (clientCAs == "" && needsClientCA) {
+		ca, created, err := tlsutil.LoadOrCreateCA(cfg.TLSDemoDir)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			logger.Warn("Generated demo CA %s - trust it in demo clients only", ca.CertFile())
+		}
+		if certFile == "" {
+			if certFile, keyFile, err = ca.Issue("gateway", tlsutil.ServerCert); err != nil {
+				return nil, fmt.Errorf("issue gateway certificate: %w", err)
+			}
+		}
+		if clientCAs == "" {
+			clientCAs = ca.CertFile()
+		}
+	}
+
+	logger.Info("TLS listener: certificate %s, client certificates %s", certFile, cfg.TLSClientAuth)
+	return tlsutil.ServerConfig(certFile, keyFile, clientCAs, cfg.TLSClientAuth)
 }
 
 func printBanner() {
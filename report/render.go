@@ -0,0 +1,207 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// Markdown renders the report as a Markdown document
+func (rep *Report) Markdown() string {
+	var b strings.Builder
+
+	b.WriteString("# SAGE Gateway Demo - SAGE ON vs OFF Report\n\n")
+	fmt.Fprintf(&b, "Generated: %s  \n", rep.GeneratedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Messages recorded: %d\n\n", rep.Total)
+
+	if len(rep.Scenarios) == 0 {
+		b.WriteString("_No traffic has been proxied yet._\n")
+		return b.String()
+	}
+
+	b.WriteString("## Summary\n\n")
+	b.WriteString("| Scenario | Protection | Messages | Tampered | Accepted | Rejected | Result |\n")
+	b.WriteString("|---|---|---:|---:|---:|---:|---|\n")
+	for _, sc := range rep.Scenarios {
+		for _, s := range sc.Summary {
+			fmt.Fprintf(&b, "| %s | %s | %d | %d | %d | %d | %s |\n",
+				mdEscape(sc.Name), s.Protection, s.Messages, s.Tampered, s.Accepted, s.Rejected, s.Result())
+		}
+	}
+
+	for _, sc := range rep.Scenarios {
+		fmt.Fprintf(&b, "\n## Scenario: %s\n\n", mdEscape(sc.Name))
+		b.WriteString("| Time | Message | Tampered fields | Protection | Upstream | Outcome |\n")
+		b.WriteString("|---|---|---|---|---|---|\n")
+		for _, e := range sc.Entries {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+				e.Timestamp.Format("15:04:05"),
+				mdEscape(e.MessageLabel()),
+				mdEscape(strings.Join(changeLines(e.Changes), "<br>")),
+				e.Protection,
+				mdEscape(e.UpstreamLabel()),
+				e.Verdict())
+		}
+
+		var findings []string
+		for _, e := range sc.Entries {
+			if f := e.Finding; f != "" {
+				findings = append(findings, fmt.Sprintf("- %s %s: %s\n", e.Timestamp.Format("15:04:05"), mdEscape(e.MessageLabel()), f))
+			}
+		}
+		if len(findings) > 0 {
+			b.WriteString("\nFindings:\n\n" + strings.Join(findings, ""))
+		}
+	}
+
+	return b.String()
+}
+
+// Result summarises attack outcomes for one protection state
+func (s *Summary) Result() string {
+	switch {
+	case s.Succeeded > 0 && s.Blocked > 0:
+		return fmt.Sprintf("mixed: %d succeeded, %d blocked", s.Succeeded, s.Blocked)
+	case s.Succeeded > 0:
+		return "❌ attack succeeded"
+	case s.Blocked > 0:
+		return "✅ attack blocked"
+	case s.Errors == s.Messages:
+		return "upstream error"
+	}
+	return "no tampering"
+}
+
+// MessageLabel identifies the message in report tables
+func (e *Entry) MessageLabel() string {
+	label := e.Path
+	if e.MessageID != "" {
+		label = e.MessageID + " " + label
+	}
+	if e.From != "" || e.To != "" {
+		label += fmt.Sprintf(" (%s → %s)", e.From, e.To)
+	}
+	return label
+}
+
+// UpstreamLabel describes the upstream response in report tables
+func (e *Entry) UpstreamLabel() string {
+	if e.Upstream.StatusCode == 0 {
+		return "no response"
+	}
+	return fmt.Sprintf("%d %s", e.Upstream.StatusCode, http.StatusText(e.Upstream.StatusCode))
+}
+
+// changeLines renders field changes as "field: old → new"
+func changeLines(changes []types.Change) []string {
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		line := fmt.Sprintf("%s: %s → %s", c.Field, shortValue(c.OriginalValue), shortValue(c.ModifiedValue))
+		if c.Step != "" {
+			line = "[" + c.Step + "] " + line
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func shortValue(v interface{}) string {
+	if v == nil {
+		return "∅"
+	}
+	s := fmt.Sprintf("%v", v)
+	if len(s) > 40 {
+		s = s[:37] + "..."
+	}
+	return s
+}
+
+// mdEscape escapes characters that would break a Markdown table cell
+func mdEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"changes": changeLines,
+	"time":    func(t time.Time) string { return t.Format("15:04:05") },
+	"rfc3339": func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>SAGE Gateway Demo - SAGE ON vs OFF Report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 6px 10px; text-align: left; vertical-align: top; font-size: 14px; }
+th { background: #f3f3f3; }
+.succeeded { background: #fde2e2; }
+.blocked { background: #e2f6e5; }
+.num { text-align: right; }
+</style>
+</head>
+<body>
+<h1>SAGE Gateway Demo - SAGE ON vs OFF Report</h1>
+<p>Generated: {{rfc3339 .GeneratedAt}} &middot; Messages recorded: {{.Total}}</p>
+{{if not .Scenarios}}<p><em>No traffic has been proxied yet.</em></p>{{else}}
+<h2>Summary</h2>
+<table>
+<tr><th>Scenario</th><th>Protection</th><th>Messages</th><th>Tampered</th><th>Accepted</th><th>Rejected</th><th>Result</th></tr>
+{{range $sc := .Scenarios}}{{range .Summary}}<tr class="{{if .Succeeded}}succeeded{{else if .Blocked}}blocked{{end}}">
+<td>{{$sc.Name}}</td><td>{{.Protection}}</td><td class="num">{{.Messages}}</td><td class="num">{{.Tampered}}</td><td class="num">{{.Accepted}}</td><td class="num">{{.Rejected}}</td><td>{{.Result}}</td></tr>
+{{end}}{{end}}</table>
+{{range .Scenarios}}
+<h2>Scenario: {{.Name}}</h2>
+<table>
+<tr><th>Time</th><th>Message</th><th>Tampered fields</th><th>Protection</th><th>Upstream</th><th>Outcome</th></tr>
+{{range .Entries}}<tr class="{{if eq .Verdict "attack succeeded"}}succeeded{{else if eq .Verdict "attack blocked"}}blocked{{end}}">
+<td>{{time .Timestamp}}</td><td>{{.MessageLabel}}</td><td>{{range changes .Changes}}{{.}}<br>{{end}}</td><td>{{.Protection}}</td><td title="{{.Upstream.Body}}">{{.UpstreamLabel}}</td><td>{{.Verdict}}{{with .Finding}}<br><small>{{.}}</small>{{end}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+// HTML renders the report as a standalone HTML page
+func (rep *Report) HTML() (string, error) {
+	var b strings.Builder
+	if err := htmlTemplate.Execute(&b, rep); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// HandleReport serves the report at /api/report.
+// Query parameters: format=markdown|html|json, download=true
+func (r *Recorder) HandleReport(w http.ResponseWriter, req *http.Request) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = FormatMarkdown
+	}
+
+	content, err := r.Build().Render(format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contentType, ext := "text/markdown; charset=utf-8", "md"
+	switch format {
+	case FormatHTML:
+		contentType, ext = "text/html; charset=utf-8", "html"
+	case FormatJSON:
+		contentType, ext = "application/json", "json"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if req.URL.Query().Get("download") == "true" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="sage-report-%s.%s"`,
+			time.Now().Format("20060102-150405"), ext))
+	}
+	w.Write([]byte(content))
+}
@@ -0,0 +1,362 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// Outcome values of an upstream exchange
+const (
+	OutcomeAccepted = "accepted"
+	OutcomeRejected = "rejected"
+	OutcomeError    = "error"
+)
+
+// ScenarioPassthrough groups messages that were forwarded without tampering
+const ScenarioPassthrough = "passthrough"
+
+// maxBodyLen limits how much of an upstream body is kept per entry
+const maxBodyLen = 2048
+
+// Protection is the SAGE protection state of a message (from A2AStatus)
+type Protection struct {
+	SAGE bool `json:"sage"`
+	HPKE bool `json:"hpke"`
+}
+
+// String returns a short label such as "SAGE ON / HPKE OFF"
+func (p Protection) String() string {
+	return "SAGE " + onOff(p.SAGE) + " / HPKE " + onOff(p.HPKE)
+}
+
+func onOff(b bool) string {
+	if b {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// Entry is one proxied exchange correlated with its upstream response
+type Entry struct {
+	Timestamp  time.Time            `json:"timestamp"`
+	Scenario   string               `json:"scenario"`
+	MessageID  string               `json:"message_id,omitempty"`
+	From       string               `json:"from,omitempty"`
+	To         string               `json:"to,omitempty"`
+	Path       string               `json:"path"`
+	Target     string               `json:"target"`
+	Protection Protection           `json:"protection"`
+	Changes    []types.Change       `json:"changes,omitempty"`
+	Steps      []types.PipelineStep `json:"steps,omitempty"`
+	Activation *types.Activation    `json:"activation,omitempty"`
+	Upstream   types.UpstreamResult `json:"upstream"`
+
+	// Finding explains what the outcome says about the receiver, set by the
+	// FindingFunc of the recorder
+	Finding string `json:"finding,omitempty"`
+}
+
+// NewEntry builds an entry from an attack log (nil when nothing was modified)
+func NewEntry(attackLog *types.AttackLog, originalMsg map[string]interface{}, path, target string, protection Protection, upstream *types.UpstreamResult) *Entry {
+	entry := &Entry{
+		Timestamp:  time.Now(),
+		Scenario:   ScenarioPassthrough,
+		Path:       path,
+		Target:     target,
+		Protection: protection,
+	}
+
+	if attackLog != nil && len(attackLog.Changes) > 0 {
+		entry.Timestamp = attackLog.Timestamp
+		entry.Scenario = attackLog.AttackType
+		entry.Changes = attackLog.Changes
+		entry.Steps = attackLog.Steps
+	}
+
+	if originalMsg != nil {
+		entry.MessageID, _ = originalMsg["id"].(string)
+		entry.From, _ = originalMsg["from"].(string)
+		entry.To, _ = originalMsg["to"].(string)
+	}
+
+	if upstream != nil {
+		entry.Upstream = *upstream
+		if len(entry.Upstream.Body) > maxBodyLen {
+			entry.Upstream.Body = entry.Upstream.Body[:maxBodyLen] + "..."
+		}
+	}
+
+	return entry
+}
+
+// Tampered reports whether the gateway modified the message
+func (e *Entry) Tampered() bool {
+	return len(e.Changes) > 0
+}
+
+// Verdict summarises the exchange from the attacker's point of view
+func (e *Entry) Verdict() string {
+	switch {
+	case e.Upstream.Outcome == OutcomeError:
+		return "error"
+	case e.Tampered() && e.Upstream.Outcome == OutcomeAccepted:
+		return "attack succeeded"
+	case e.Tampered():
+		return "attack blocked"
+	case e.Upstream.Outcome == OutcomeAccepted:
+		return "delivered"
+	default:
+		return "rejected"
+	}
+}
+
+// FindingFunc explains what the outcome of a tampered exchange says about the
+// receiver, for attacks whose verdict alone does not tell the story; "" when
+// there is nothing to add
+type FindingFunc func(e *Entry) string
+
+// ChangedFields returns the fields of the changes, in order
+func (e *Entry) ChangedFields() []string {
+	fields := make([]string, len(e.Changes))
+	for i, change := range e.Changes {
+		fields[i] = change.Field
+	}
+	return fields
+}
+
+// ClassifyOutcome derives accepted/rejected/error from an upstream response.
+// An explicit "status" in the reply (top-level or in metadata) takes precedence
+// over the HTTP status code, because some agents answer 200 with a rejection.
+func ClassifyOutcome(statusCode int, body []byte) string {
+	if statusCode == 0 || statusCode >= 500 {
+		return OutcomeError
+	}
+
+	var reply map[string]interface{}
+	if json.Unmarshal(body, &reply) == nil {
+		status, _ := reply["status"].(string)
+		if metadata, ok := reply["metadata"].(map[string]interface{}); ok {
+			if s, ok := metadata["status"].(string); ok {
+				status = s
+			}
+		}
+		switch strings.ToLower(status) {
+		case "rejected", "denied", "failed", "error":
+			return OutcomeRejected
+		case "accepted", "approved", "success", "ok":
+			if statusCode < 300 {
+				return OutcomeAccepted
+			}
+		}
+	}
+
+	if statusCode >= 200 && statusCode < 300 {
+		return OutcomeAccepted
+	}
+	return OutcomeRejected
+}
+
+// Recorder keeps the most recent exchanges for reporting
+type Recorder struct {
+	mu         sync.RWMutex
+	entries    []*Entry
+	maxEntries int
+	finding    FindingFunc
+}
+
+// NewRecorder creates a recorder that keeps at most maxEntries exchanges and
+// explains tampered ones with finding (none when nil)
+func NewRecorder(maxEntries int, finding FindingFunc) *Recorder {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &Recorder{maxEntries: maxEntries, finding: finding}
+}
+
+// Record adds an exchange, dropping the oldest one when full, and sets its
+// Finding
+func (r *Recorder) Record(entry *Entry) {
+	if r.finding != nil && entry.Tampered() {
+		entry.Finding = r.finding(entry)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, entry)
+	if len(r.entries) > r.maxEntries {
+		r.entries = r.entries[len(r.entries)-r.maxEntries:]
+	}
+}
+
+// Entries returns a copy of the recorded exchanges
+func (r *Recorder) Entries() []*Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]*Entry, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// Reset clears all recorded exchanges
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.entries = nil
+	r.mu.Unlock()
+}
+
+// Report is a SAGE ON vs OFF comparison built from recorded exchanges
+type Report struct {
+	GeneratedAt time.Time   `json:"generated_at"`
+	Total       int         `json:"total"`
+	Scenarios   []*Scenario `json:"scenarios"`
+}
+
+// Scenario groups the exchanges of one attack type
+type Scenario struct {
+	Name    string     `json:"name"`
+	Summary []*Summary `json:"summary"`
+	Entries []*Entry   `json:"entries"`
+}
+
+// Summary counts outcomes for one protection state within a scenario
+type Summary struct {
+	Protection Protection `json:"protection"`
+	Messages   int        `json:"messages"`
+	Tampered   int        `json:"tampered"`
+	Accepted   int        `json:"accepted"`
+	Rejected   int        `json:"rejected"`
+	Errors     int        `json:"errors"`
+	Succeeded  int        `json:"attacks_succeeded"`
+	Blocked    int        `json:"attacks_blocked"`
+}
+
+// Build groups the recorded exchanges by scenario and protection state
+func (r *Recorder) Build() *Report {
+	entries := r.Entries()
+	report := &Report{GeneratedAt: time.Now(), Total: len(entries)}
+
+	byName := make(map[string]*Scenario)
+	for _, e := range entries {
+		sc, ok := byName[e.Scenario]
+		if !ok {
+			sc = &Scenario{Name: e.Scenario}
+			byName[e.Scenario] = sc
+			report.Scenarios = append(report.Scenarios, sc)
+		}
+		sc.Entries = append(sc.Entries, e)
+
+		var sum *Summary
+		for _, s := range sc.Summary {
+			if s.Protection == e.Protection {
+				sum = s
+				break
+			}
+		}
+		if sum == nil {
+			sum = &Summary{Protection: e.Protection}
+			sc.Summary = append(sc.Summary, sum)
+		}
+
+		sum.Messages++
+		if e.Tampered() {
+			sum.Tampered++
+		}
+		switch e.Upstream.Outcome {
+		case OutcomeAccepted:
+			sum.Accepted++
+		case OutcomeRejected:
+			sum.Rejected++
+		default:
+			sum.Errors++
+		}
+		switch e.Verdict() {
+		case "attack succeeded":
+			sum.Succeeded++
+		case "attack blocked":
+			sum.Blocked++
+		}
+	}
+
+	// Passthrough last, attack scenarios alphabetically; SAGE OFF before ON
+	sort.SliceStable(report.Scenarios, func(i, j int) bool {
+		a, b := report.Scenarios[i].Name, report.Scenarios[j].Name
+		if (a == ScenarioPassthrough) != (b == ScenarioPassthrough) {
+			return b == ScenarioPassthrough
+		}
+		return a < b
+	})
+	for _, sc := range report.Scenarios {
+		sort.SliceStable(sc.Summary, func(i, j int) bool {
+			return protectionRank(sc.Summary[i].Protection) < protectionRank(sc.Summary[j].Protection)
+		})
+	}
+
+	return report
+}
+
+func protectionRank(p Protection) int {
+	rank := 0
+	if p.SAGE {
+		rank += 2
+	}
+	if p.HPKE {
+		rank++
+	}
+	return rank
+}
+
+// Export writes the report to path; the format follows the file extension
+// (.html/.htm for HTML, .json for JSON, anything else for Markdown)
+func (r *Recorder) Export(path string) error {
+	content, err := r.Build().Render(formatForPath(path))
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+func formatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return FormatHTML
+	case ".json":
+		return FormatJSON
+	}
+	return FormatMarkdown
+}
+
+// Supported report formats
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+)
+
+// Render renders the report in the given format
+func (rep *Report) Render(format string) (string, error) {
+	switch format {
+	case FormatMarkdown, "md", "":
+		return rep.Markdown(), nil
+	case FormatHTML:
+		return rep.HTML()
+	case FormatJSON:
+		b, err := json.MarshalIndent(rep, "", "  ")
+		return string(b), err
+	}
+	return "", fmt.Errorf("unsupported report format %q (valid: markdown, html, json)", format)
+}
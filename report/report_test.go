@@ -0,0 +1,207 @@
+package report
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func attackEntry(sageOn bool, status int, body string) *Entry {
+	attackLog := &types.AttackLog{
+		Timestamp:  time.Now(),
+		AttackType: "price_manipulation",
+		Changes: []types.Change{
+			{Field: "amount", OriginalValue: 100.0, ModifiedValue: 10000.0},
+		},
+	}
+	msg := map[string]interface{}{"id": "msg-1", "from": "root", "to": "payment"}
+	upstream := &types.UpstreamResult{
+		StatusCode: status,
+		Body:       body,
+		Outcome:    ClassifyOutcome(status, []byte(body)),
+	}
+	return NewEntry(attackLog, msg, "/payment", "http://localhost:19083", Protection{SAGE: sageOn}, upstream)
+}
+
+func TestClassifyOutcome(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"plain 200", 200, "ok", OutcomeAccepted},
+		{"metadata accepted", 200, `{"metadata":{"status":"accepted"}}`, OutcomeAccepted},
+		{"200 with rejection", 200, `{"metadata":{"status":"rejected"}}`, OutcomeRejected},
+		{"top-level status", 200, `{"status":"denied"}`, OutcomeRejected},
+		{"401", 401, `{"metadata":{"status":"rejected"}}`, OutcomeRejected},
+		{"4xx with success body", 422, `{"status":"success"}`, OutcomeRejected},
+		{"502", 502, "bad gateway", OutcomeError},
+		{"unreachable", 0, "", OutcomeError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyOutcome(tt.status, []byte(tt.body)); got != tt.want {
+				t.Errorf("ClassifyOutcome(): got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEntry_Passthrough(t *testing.T) {
+	entry := NewEntry(nil, nil, "/order", "http://target", Protection{}, &types.UpstreamResult{
+		StatusCode: 200,
+		Body:       strings.Repeat("x", maxBodyLen+10),
+		Outcome:    OutcomeAccepted,
+	})
+
+	if entry.Scenario != ScenarioPassthrough || entry.Tampered() {
+		t.Errorf("Expected untampered passthrough entry, got %+v", entry)
+	}
+	if entry.Verdict() != "delivered" {
+		t.Errorf("Verdict: got %q, want delivered", entry.Verdict())
+	}
+	if len(entry.Upstream.Body) != maxBodyLen+3 {
+		t.Errorf("Upstream body not truncated: %d bytes", len(entry.Upstream.Body))
+	}
+}
+
+func TestRecorder_Finding(t *testing.T) {
+	r := NewRecorder(0, func(e *Entry) string {
+		return "outcome " + e.Upstream.Outcome
+	})
+
+	tampered := attackEntry(true, 401, "")
+	r.Record(tampered)
+	if tampered.Finding != "outcome rejected" {
+		t.Errorf("Tampered entry: got finding %q", tampered.Finding)
+	}
+	passthrough := NewEntry(nil, nil, "/order", "http://target", Protection{}, &types.UpstreamResult{StatusCode: 200, Outcome: OutcomeAccepted})
+	r.Record(passthrough)
+	if passthrough.Finding != "" {
+		t.Errorf("Untampered entries should have no finding, got %q", passthrough.Finding)
+	}
+
+	untold := attackEntry(true, 200, "")
+	NewRecorder(0, nil).Record(untold)
+	if untold.Finding != "" {
+		t.Errorf("Recorder without a FindingFunc should set none, got %q", untold.Finding)
+	}
+}
+
+func TestRecorder_Build(t *testing.T) {
+	r := NewRecorder(10, nil)
+	r.Record(attackEntry(true, 401, `{"metadata":{"status":"rejected"}}`))
+	r.Record(attackEntry(false, 200, `{"metadata":{"status":"accepted"}}`))
+	r.Record(NewEntry(nil, nil, "/order", "http://target", Protection{}, &types.UpstreamResult{StatusCode: 200, Outcome: OutcomeAccepted}))
+
+	rep := r.Build()
+	if rep.Total != 3 || len(rep.Scenarios) != 2 {
+		t.Fatalf("Expected 3 entries in 2 scenarios, got %d/%d", rep.Total, len(rep.Scenarios))
+	}
+	if rep.Scenarios[1].Name != ScenarioPassthrough {
+		t.Errorf("Passthrough should be listed last, got %s", rep.Scenarios[1].Name)
+	}
+
+	summary := rep.Scenarios[0].Summary
+	if len(summary) != 2 || summary[0].Protection.SAGE || !summary[1].Protection.SAGE {
+		t.Fatalf("Expected SAGE OFF then SAGE ON summaries, got %+v", summary)
+	}
+	if summary[0].Succeeded != 1 || summary[1].Blocked != 1 {
+		t.Errorf("Unexpected attack counts: off=%+v on=%+v", summary[0], summary[1])
+	}
+}
+
+func TestRecorder_MaxEntries(t *testing.T) {
+	r := NewRecorder(2, nil)
+	for i := 0; i < 5; i++ {
+		r.Record(attackEntry(false, 200, ""))
+	}
+	if got := len(r.Entries()); got != 2 {
+		t.Errorf("Expected 2 entries, got %d", got)
+	}
+}
+
+func TestReport_Render(t *testing.T) {
+	r := NewRecorder(0, nil)
+	r.Record(attackEntry(false, 200, ""))
+	r.Record(attackEntry(true, 401, "<script>alert(1)</script>"))
+	rep := r.Build()
+
+	md, _ := rep.Render(FormatMarkdown)
+	for _, want := range []string{"## Summary", "## Scenario: price_manipulation", "SAGE OFF / HPKE OFF", "❌ attack succeeded", "✅ attack blocked", "amount: 100 → 10000"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown missing %q", want)
+		}
+	}
+
+	html, err := rep.Render(FormatHTML)
+	if err != nil {
+		t.Fatalf("Render(html) error: %v", err)
+	}
+	if strings.Contains(html, "<script>") || !strings.Contains(html, "attack blocked") {
+		t.Error("HTML report should escape upstream bodies and include verdicts")
+	}
+
+	js, _ := rep.Render(FormatJSON)
+	var decoded Report
+	if err := json.Unmarshal([]byte(js), &decoded); err != nil || decoded.Total != 2 {
+		t.Errorf("JSON report did not round-trip: %v", err)
+	}
+
+	if _, err := rep.Render("pdf"); err == nil {
+		t.Error("Render() should reject unknown formats")
+	}
+}
+
+func TestRecorder_HandleReport(t *testing.T) {
+	r := NewRecorder(0, nil)
+	r.Record(attackEntry(false, 200, ""))
+
+	req := httptest.NewRequest("GET", "/api/report?format=html&download=true", nil)
+	w := httptest.NewRecorder()
+	r.HandleReport(w, req)
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Unexpected response: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), ".html") {
+		t.Errorf("Missing download filename: %q", w.Header().Get("Content-Disposition"))
+	}
+
+	w = httptest.NewRecorder()
+	r.HandleReport(w, httptest.NewRequest("GET", "/api/report?format=pdf", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Unknown format: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRecorder_Export(t *testing.T) {
+	r := NewRecorder(0, nil)
+	r.Record(attackEntry(true, 401, ""))
+
+	dir := t.TempDir()
+	for _, name := range []string{"report.md", "out/report.html", "report.json"} {
+		path := filepath.Join(dir, name)
+		if err := r.Export(path); err != nil {
+			t.Fatalf("Export(%s) error: %v", name, err)
+		}
+		data, _ := os.ReadFile(path)
+		if len(data) == 0 {
+			t.Errorf("Export(%s) wrote an empty file", name)
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "out/report.html"))
+	if !strings.HasPrefix(string(data), "<!DOCTYPE html>") {
+		t.Error("Export() should pick HTML for .html files")
+	}
+}
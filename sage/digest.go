@@ -0,0 +1,90 @@
+package sage
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// ContentDigest returns an RFC 9530 Content-Digest header value for body
+func ContentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// RecomputeDigest returns a Content-Digest (or Repr-Digest) value listing the
+// supported algorithms of header, recomputed over body. Unsupported
+// algorithms are dropped; sha-256 is used if none remain.
+func RecomputeDigest(header string, body []byte) string {
+	var members []string
+	for _, member := range splitTopLevel(header, ',') {
+		alg, _, _ := strings.Cut(strings.TrimSpace(member), "=")
+		switch strings.ToLower(alg) {
+		case "sha-256":
+			sum := sha256.Sum256(body)
+			members = append(members, "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+		case "sha-512":
+			sum := sha512.Sum512(body)
+			members = append(members, "sha-512=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+		}
+	}
+	if len(members) == 0 {
+		return ContentDigest(body)
+	}
+	return strings.Join(members, ", ")
+}
+
+// VerifyContentDigest checks a Content-Digest header value against body.
+// Every supported algorithm listed in the header must match.
+func VerifyContentDigest(header string, body []byte) error {
+	if strings.TrimSpace(header) == "" {
+		return fmt.Errorf("%w: empty Content-Digest", ErrDigestMismatch)
+	}
+
+	checked := 0
+	for _, member := range splitTopLevel(header, ',') {
+		alg, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			return fmt.Errorf("%w: malformed member %q", ErrDigestMismatch, member)
+		}
+
+		expected, err := decodeByteSequence(value)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrDigestMismatch, err)
+		}
+
+		var actual []byte
+		switch strings.ToLower(alg) {
+		case "sha-256":
+			sum := sha256.Sum256(body)
+			actual = sum[:]
+		case "sha-512":
+			sum := sha512.Sum512(body)
+			actual = sum[:]
+		default:
+			continue
+		}
+
+		if subtle.ConstantTimeCompare(expected, actual) != 1 {
+			return fmt.Errorf("%w: %s does not match body", ErrDigestMismatch, alg)
+		}
+		checked++
+	}
+
+	if checked == 0 {
+		return fmt.Errorf("%w: no supported algorithm", ErrDigestMismatch)
+	}
+	return nil
+}
+
+// decodeByteSequence decodes an RFC 8941 byte sequence (":base64:")
+func decodeByteSequence(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+		return nil, fmt.Errorf("not a byte sequence: %q", value)
+	}
+	return base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+}
@@ -0,0 +1,64 @@
+package sage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestContentDigest_RoundTrip(t *testing.T) {
+	body := []byte(`{"hello": "world"}`)
+
+	// Known value from RFC 9530 Section 2
+	want := "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+	if got := ContentDigest(body); got != want {
+		t.Errorf("ContentDigest(): got %s, want %s", got, want)
+	}
+
+	if err := VerifyContentDigest(want, body); err != nil {
+		t.Errorf("VerifyContentDigest() error: %v", err)
+	}
+}
+
+func TestVerifyContentDigest_Mismatch(t *testing.T) {
+	header := ContentDigest([]byte("original"))
+
+	if err := VerifyContentDigest(header, []byte("tampered")); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Expected ErrDigestMismatch, got: %v", err)
+	}
+}
+
+func TestVerifyContentDigest_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"sha-256",
+		"sha-256=abc",
+		"md5=:AAAA:",
+	}
+
+	for _, header := range tests {
+		if err := VerifyContentDigest(header, nil); !errors.Is(err, ErrDigestMismatch) {
+			t.Errorf("VerifyContentDigest(%q): expected ErrDigestMismatch, got %v", header, err)
+		}
+	}
+}
+
+func TestRecomputeDigest(t *testing.T) {
+	original := []byte(`{"amount":100}`)
+	modified := []byte(`{"amount":10000}`)
+
+	header := RecomputeDigest("sha-512=:AAAA:, md5=:AAAA:, sha-256=:AAAA:", modified)
+	if err := VerifyContentDigest(header, modified); err != nil {
+		t.Errorf("Recomputed digest should verify: %v", err)
+	}
+	if err := VerifyContentDigest(header, original); err == nil {
+		t.Error("Recomputed digest should not match the original body")
+	}
+	if !strings.HasPrefix(header, "sha-512=") || strings.Contains(header, "md5") {
+		t.Errorf("Expected sha-512 then sha-256 only, got %s", header)
+	}
+
+	if got := RecomputeDigest("md5=:AAAA:", modified); got != ContentDigest(modified) {
+		t.Errorf("Expected sha-256 fallback, got %s", got)
+	}
+}
@@ -0,0 +1,97 @@
+package sage
+
+import (
+	"crypto/ecdh"
+	"crypto/hpke"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// SecureMessageType is the envelope type of HPKE-encrypted agent messages
+const SecureMessageType = "secure"
+
+// hpkeInfo binds ciphertexts to the demo application
+var hpkeInfo = []byte("sage-demo/hpke/v1")
+
+// ErrDecrypt is returned when an HPKE payload cannot be opened
+var ErrDecrypt = errors.New("HPKE decryption failed")
+
+// Seal encrypts plaintext to recipient with DHKEM(X25519)/HKDF-SHA256/AES-128-GCM
+// and returns base64(enc || ciphertext)
+func Seal(recipient *ecdh.PublicKey, plaintext []byte) (string, error) {
+	pk, err := hpke.NewDHKEMPublicKey(recipient)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := hpke.Seal(pk, hpke.HKDFSHA256(), hpke.AES128GCM(), hpkeInfo, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a payload produced by Seal
+func Open(key *ecdh.PrivateKey, payload string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	sk, err := hpke.NewDHKEMPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := hpke.Open(sk, hpke.HKDFSHA256(), hpke.AES128GCM(), hpkeInfo, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return plaintext, nil
+}
+
+// EncryptAgentMessage wraps msg in a SecureMessage encrypted to the demo key of msg.To
+func EncryptAgentMessage(msg *types.AgentMessage) (*types.SecureMessage, error) {
+	plaintext, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient := DemoKeyPair(msg.To)
+	payload, err := Seal(recipient.KEMKey.PublicKey(), plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	return &types.SecureMessage{
+		ID:               msg.ID,
+		ContextID:        msg.ContextID,
+		From:             msg.From,
+		To:               msg.To,
+		Type:             SecureMessageType,
+		KeyID:            recipient.KeyID,
+		EncryptedPayload: payload,
+		Timestamp:        timestamp,
+	}, nil
+}
+
+// DecryptSecureMessage opens a SecureMessage with the recipient key pair
+func DecryptSecureMessage(sm *types.SecureMessage, key *KeyPair) (*types.AgentMessage, error) {
+	plaintext, err := Open(key.KEMKey, sm.EncryptedPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg types.AgentMessage
+	if err := json.Unmarshal(plaintext, &msg); err != nil {
+		return nil, fmt.Errorf("%w: payload is not an AgentMessage: %v", ErrDecrypt, err)
+	}
+	return &msg, nil
+}
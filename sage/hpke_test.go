@@ -0,0 +1,89 @@
+package sage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+func TestEncryptAgentMessage_RoundTrip(t *testing.T) {
+	msg := &types.AgentMessage{
+		ID:        "msg-001",
+		ContextID: "ctx-001",
+		From:      "root",
+		To:        "payment",
+		Content:   "Pay 100",
+		Timestamp: time.Now().UTC().Truncate(time.Second),
+		Type:      "request",
+		Metadata:  map[string]interface{}{"amount": 100.0},
+	}
+
+	sm, err := EncryptAgentMessage(msg)
+	if err != nil {
+		t.Fatalf("EncryptAgentMessage() error: %v", err)
+	}
+
+	if sm.Type != SecureMessageType {
+		t.Errorf("Type: got %s, want %s", sm.Type, SecureMessageType)
+	}
+	if sm.To != "payment" || sm.From != "root" {
+		t.Errorf("Envelope: got from=%s to=%s", sm.From, sm.To)
+	}
+
+	opened, err := DecryptSecureMessage(sm, DemoKeyPair("payment"))
+	if err != nil {
+		t.Fatalf("DecryptSecureMessage() error: %v", err)
+	}
+	if opened.Content != msg.Content || opened.Metadata["amount"] != 100.0 {
+		t.Errorf("Decrypted message mismatch: %+v", opened)
+	}
+}
+
+func TestDecryptSecureMessage_WrongRecipient(t *testing.T) {
+	sm, err := EncryptAgentMessage(&types.AgentMessage{From: "root", To: "payment"})
+	if err != nil {
+		t.Fatalf("EncryptAgentMessage() error: %v", err)
+	}
+
+	if _, err := DecryptSecureMessage(sm, DemoKeyPair("medical")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt, got: %v", err)
+	}
+}
+
+func TestOpen_BitFlipped(t *testing.T) {
+	key := DemoKeyPair("payment")
+	payload, err := Seal(key.KEMKey.PublicKey(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Seal() error: %v", err)
+	}
+
+	raw := []byte(payload)
+	raw[len(raw)/2] ^= 0x01
+
+	if _, err := Open(key.KEMKey, string(raw)); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt for tampered payload, got: %v", err)
+	}
+}
+
+func TestDemoKeyPair_Deterministic(t *testing.T) {
+	a := DemoKeyPair("payment")
+	b := DemoKeyPair("payment")
+	c := DemoKeyPair("medical")
+
+	if !a.PublicKey().Equal(b.PublicKey()) {
+		t.Error("DemoKeyPair() is not deterministic")
+	}
+	if a.PublicKey().Equal(c.PublicKey()) {
+		t.Error("DemoKeyPair() returned the same key for different agents")
+	}
+
+	agent, ok := AgentFromKeyID(a.KeyID)
+	if !ok || agent != "payment" {
+		t.Errorf("AgentFromKeyID(): got %s, %v", agent, ok)
+	}
+	if _, ok := AgentFromKeyID("did:web:example.com"); ok {
+		t.Error("AgentFromKeyID() accepted a non-demo DID")
+	}
+}
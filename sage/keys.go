@@ -0,0 +1,73 @@
+package sage
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// DemoKeyIDPrefix is the DID prefix used for demo agent key identifiers
+const DemoKeyIDPrefix = "did:sage:demo:"
+
+// KeyPair holds the signing and HPKE keys of a single agent
+type KeyPair struct {
+	AgentID    string
+	KeyID      string
+	SigningKey ed25519.PrivateKey
+	KEMKey     *ecdh.PrivateKey
+}
+
+// DemoKeyPair derives a deterministic key pair for the given agent name.
+// The keys are derived from the agent name only, so the gateway, the mock
+// agents and the traffic generator agree on them without sharing files.
+// They are for demonstrations only and provide no real security.
+func DemoKeyPair(agentID string) *KeyPair {
+	signSeed := sha256.Sum256([]byte("sage-demo/ed25519/" + agentID))
+	kemSeed := sha256.Sum256([]byte("sage-demo/x25519/" + agentID))
+
+	kemKey, err := ecdh.X25519().NewPrivateKey(kemSeed[:])
+	if err != nil {
+		// X25519 accepts any 32-byte scalar
+		panic(fmt.Sprintf("sage: derive demo KEM key: %v", err))
+	}
+
+	return &KeyPair{
+		AgentID:    agentID,
+		KeyID:      DemoKeyID(agentID),
+		SigningKey: ed25519.NewKeyFromSeed(signSeed[:]),
+		KEMKey:     kemKey,
+	}
+}
+
+// PublicKey returns the Ed25519 verification key
+func (k *KeyPair) PublicKey() ed25519.PublicKey {
+	return k.SigningKey.Public().(ed25519.PublicKey)
+}
+
+// DemoKeyID returns the DID used as keyid for the given agent
+func DemoKeyID(agentID string) string {
+	return DemoKeyIDPrefix + agentID
+}
+
+// AgentFromKeyID returns the agent bound to a demo keyid
+func AgentFromKeyID(keyID string) (string, bool) {
+	if !strings.HasPrefix(keyID, DemoKeyIDPrefix) {
+		return "", false
+	}
+	agentID := strings.TrimPrefix(keyID, DemoKeyIDPrefix)
+	return agentID, agentID != ""
+}
+
+// KeyResolver resolves a keyid to a verification key
+type KeyResolver func(keyID string) (ed25519.PublicKey, error)
+
+// DemoKeyResolver resolves any demo DID to the matching derived key
+func DemoKeyResolver(keyID string) (ed25519.PublicKey, error) {
+	agentID, ok := AgentFromKeyID(keyID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	return DemoKeyPair(agentID).PublicKey(), nil
+}
@@ -0,0 +1,648 @@
+package sage
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by signature and digest verification
+var (
+	ErrNoSignature      = errors.New("no RFC 9421 signature")
+	ErrMalformed        = errors.New("malformed signature header")
+	ErrUnknownKey       = errors.New("unknown keyid")
+	ErrDigestMismatch   = errors.New("content digest mismatch")
+	ErrSignatureInvalid = errors.New("signature verification failed")
+	ErrMissingComponent = errors.New("covered component missing")
+	ErrStaleSignature   = errors.New("signature is not fresh")
+)
+
+// DefaultLabel is the signature label used by the demo signer
+const DefaultLabel = "sig1"
+
+// AlgEd25519 is the RFC 9421 algorithm name of Ed25519
+const AlgEd25519 = "ed25519"
+
+// DefaultRequestComponents is the coverage used for signed agent requests
+var DefaultRequestComponents = []string{"@method", "@path", "@query", "content-type", "content-digest"}
+
+// DefaultResponseComponents is the coverage used for signed agent responses
+var DefaultResponseComponents = []string{"@status", "content-type", "content-digest"}
+
+// Component is a single covered component identifier
+type Component struct {
+	Name  string // lowercase field name or derived component such as "@path"
+	Param string // value of the ;name parameter (used by @query-param)
+}
+
+// String serializes the component identifier as it appears in Signature-Input
+func (c Component) String() string {
+	if c.Param != "" {
+		return strconv.Quote(c.Name) + ";name=" + strconv.Quote(c.Param)
+	}
+	return strconv.Quote(c.Name)
+}
+
+// IsDerived reports whether the component is a derived component
+func (c Component) IsDerived() bool {
+	return strings.HasPrefix(c.Name, "@")
+}
+
+// SignatureParams is one parsed Signature-Input member
+type SignatureParams struct {
+	Label      string
+	Components []Component
+	Created    time.Time // zero when absent
+	Expires    time.Time // zero when absent
+	KeyID      string
+	Alg        string
+	Nonce      string
+	Tag        string
+
+	raw string // serialized value used as the @signature-params line
+}
+
+// Covers reports whether the named component is covered by the signature
+func (p *SignatureParams) Covers(name string) bool {
+	name = strings.ToLower(name)
+	for _, c := range p.Components {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CoversQueryParam reports whether a query parameter is covered,
+// either individually or through @query
+func (p *SignatureParams) CoversQueryParam(param string) bool {
+	for _, c := range p.Components {
+		if c.Name == "@query" || (c.Name == "@query-param" && c.Param == param) {
+			return true
+		}
+	}
+	return false
+}
+
+// Age returns how long ago the signature was created, zero without a
+// created parameter
+func (p *SignatureParams) Age(now time.Time) time.Duration {
+	if p.Created.IsZero() {
+		return 0
+	}
+	return now.Sub(p.Created)
+}
+
+// Deadline returns when the signature stops being fresh: the earlier of its
+// expires parameter and created plus maxAge (ignored when 0). It is zero when
+// neither applies.
+func (p *SignatureParams) Deadline(maxAge time.Duration) time.Time {
+	deadline := p.Expires
+	if maxAge > 0 && !p.Created.IsZero() {
+		if byAge := p.Created.Add(maxAge); deadline.IsZero() || byAge.Before(deadline) {
+			deadline = byAge
+		}
+	}
+	return deadline
+}
+
+// Raw returns the serialized signature parameters
+func (p *SignatureParams) Raw() string {
+	return p.raw
+}
+
+// Message exposes the parts of an HTTP message that components are derived from
+type Message struct {
+	Method    string
+	Scheme    string
+	Authority string
+	Path      string
+	RawQuery  string
+	Status    int
+	Header    http.Header
+}
+
+// RequestMessage builds a Message view of a request.
+// It works for both outgoing client requests and incoming server requests.
+func RequestMessage(r *http.Request) *Message {
+	authority := r.Host
+	if authority == "" && r.URL != nil {
+		authority = r.URL.Host
+	}
+
+	scheme := "http"
+	if r.URL != nil && r.URL.Scheme != "" {
+		scheme = r.URL.Scheme
+	} else if r.TLS != nil {
+		scheme = "https"
+	}
+
+	path := "/"
+	rawQuery := ""
+	if r.URL != nil {
+		if p := r.URL.EscapedPath(); p != "" {
+			path = p
+		}
+		rawQuery = r.URL.RawQuery
+	}
+
+	return &Message{
+		Method:    r.Method,
+		Scheme:    strings.ToLower(scheme),
+		Authority: strings.ToLower(authority),
+		Path:      path,
+		RawQuery:  rawQuery,
+		Header:    r.Header,
+	}
+}
+
+// ResponseMessage builds a Message view of a response
+func ResponseMessage(status int, header http.Header) *Message {
+	return &Message{Status: status, Header: header}
+}
+
+// ComponentValue returns the canonical value of a covered component
+func (m *Message) ComponentValue(c Component) (string, error) {
+	switch c.Name {
+	case "@method":
+		return strings.ToUpper(m.Method), nil
+	case "@scheme":
+		return m.Scheme, nil
+	case "@authority":
+		return m.Authority, nil
+	case "@path":
+		return m.Path, nil
+	case "@query":
+		return "?" + m.RawQuery, nil
+	case "@target-uri":
+		target := m.Scheme + "://" + m.Authority + m.Path
+		if m.RawQuery != "" {
+			target += "?" + m.RawQuery
+		}
+		return target, nil
+	case "@request-target":
+		if m.RawQuery != "" {
+			return m.Path + "?" + m.RawQuery, nil
+		}
+		return m.Path, nil
+	case "@query-param":
+		values, err := url.ParseQuery(m.RawQuery)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		if _, ok := values[c.Param]; !ok {
+			return "", fmt.Errorf("%w: query parameter %q", ErrMissingComponent, c.Param)
+		}
+		return url.QueryEscape(values.Get(c.Param)), nil
+	case "@status":
+		if m.Status == 0 {
+			return "", fmt.Errorf("%w: @status on a request", ErrMissingComponent)
+		}
+		return strconv.Itoa(m.Status), nil
+	}
+
+	if c.IsDerived() {
+		return "", fmt.Errorf("%w: unsupported derived component %s", ErrMalformed, c.Name)
+	}
+
+	raw := m.Header.Values(c.Name)
+	if len(raw) == 0 {
+		return "", fmt.Errorf("%w: header %s", ErrMissingComponent, c.Name)
+	}
+	values := make([]string, len(raw))
+	for i, v := range raw {
+		values[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(values, ", "), nil
+}
+
+// SignatureBase builds the RFC 9421 signature base for the given parameters
+func (m *Message) SignatureBase(params *SignatureParams) (string, error) {
+	var b strings.Builder
+	for _, c := range params.Components {
+		value, err := m.ComponentValue(c)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(c.String())
+		b.WriteString(": ")
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+	b.WriteString(`"@signature-params": `)
+	b.WriteString(params.raw)
+	return b.String(), nil
+}
+
+// SignOptions controls how a message is signed
+type SignOptions struct {
+	Label      string        // defaults to DefaultLabel
+	Components []string      // defaults to DefaultRequestComponents/DefaultResponseComponents
+	Created    time.Time     // defaults to now
+	Expires    time.Duration // zero means no expires parameter
+	Nonce      string
+}
+
+// NewSignatureParams builds serialized signature parameters for a signer
+func NewSignatureParams(key *KeyPair, components []string, opts *SignOptions) *SignatureParams {
+	if opts == nil {
+		opts = &SignOptions{}
+	}
+
+	params := &SignatureParams{
+		Label: opts.Label,
+		KeyID: key.KeyID,
+		Alg:   AlgEd25519,
+		Nonce: opts.Nonce,
+	}
+	if params.Label == "" {
+		params.Label = DefaultLabel
+	}
+
+	params.Created = opts.Created
+	if params.Created.IsZero() {
+		params.Created = time.Now()
+	}
+	params.Created = params.Created.Truncate(time.Second)
+	if opts.Expires > 0 {
+		params.Expires = params.Created.Add(opts.Expires)
+	}
+
+	for _, name := range components {
+		c := Component{Name: strings.ToLower(name)}
+		if base, param, ok := strings.Cut(name, ";name="); ok {
+			c.Name = strings.ToLower(base)
+			c.Param = strings.Trim(param, `"`)
+		}
+		params.Components = append(params.Components, c)
+	}
+
+	params.raw = params.serialize()
+	return params
+}
+
+// serialize renders the inner list and parameters of a Signature-Input member
+func (p *SignatureParams) serialize() string {
+	items := make([]string, len(p.Components))
+	for i, c := range p.Components {
+		items[i] = c.String()
+	}
+
+	var b strings.Builder
+	b.WriteString("(" + strings.Join(items, " ") + ")")
+	if !p.Created.IsZero() {
+		b.WriteString(";created=" + strconv.FormatInt(p.Created.Unix(), 10))
+	}
+	if !p.Expires.IsZero() {
+		b.WriteString(";expires=" + strconv.FormatInt(p.Expires.Unix(), 10))
+	}
+	if p.KeyID != "" {
+		b.WriteString(";keyid=" + strconv.Quote(p.KeyID))
+	}
+	if p.Alg != "" {
+		b.WriteString(";alg=" + strconv.Quote(p.Alg))
+	}
+	if p.Nonce != "" {
+		b.WriteString(";nonce=" + strconv.Quote(p.Nonce))
+	}
+	if p.Tag != "" {
+		b.WriteString(";tag=" + strconv.Quote(p.Tag))
+	}
+	return b.String()
+}
+
+// SignRequest sets Content-Digest, Signature-Input and Signature on req.
+// body must be the exact bytes that will be sent.
+func SignRequest(req *http.Request, body []byte, key *KeyPair, opts *SignOptions) (*SignatureParams, error) {
+	components := DefaultRequestComponents
+	if opts != nil && len(opts.Components) > 0 {
+		components = opts.Components
+	}
+	req.Header.Set("Content-Digest", ContentDigest(body))
+	return sign(RequestMessage(req), req.Header, key, components, opts)
+}
+
+// SignResponse sets Content-Digest, Signature-Input and Signature on a response header
+func SignResponse(status int, header http.Header, body []byte, key *KeyPair, opts *SignOptions) (*SignatureParams, error) {
+	components := DefaultResponseComponents
+	if opts != nil && len(opts.Components) > 0 {
+		components = opts.Components
+	}
+	header.Set("Content-Digest", ContentDigest(body))
+	return sign(ResponseMessage(status, header), header, key, components, opts)
+}
+
+func sign(msg *Message, header http.Header, key *KeyPair, components []string, opts *SignOptions) (*SignatureParams, error) {
+	params := NewSignatureParams(key, components, opts)
+
+	base, err := msg.SignatureBase(params)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := ed25519.Sign(key.SigningKey, []byte(base))
+	header.Set("Signature-Input", params.Label+"="+params.raw)
+	header.Set("Signature", params.Label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return params, nil
+}
+
+// StripSignature removes every SAGE signature header from h
+func StripSignature(h http.Header) {
+	h.Del("Signature")
+	h.Del("Signature-Input")
+	h.Del("Content-Digest")
+}
+
+// HasSignature reports whether h carries RFC 9421 signature headers
+func HasSignature(h http.Header) bool {
+	return h.Get("Signature") != "" && h.Get("Signature-Input") != ""
+}
+
+// Verifier verifies RFC 9421 signatures produced by SAGE agents
+type Verifier struct {
+	Keys KeyResolver // defaults to DemoKeyResolver
+
+	// Freshness: expires is always enforced, created only with a MaxAge.
+	// ClockSkew is tolerated in both directions.
+	MaxAge    time.Duration    // maximum age relative to created, 0 = unlimited
+	ClockSkew time.Duration    // allowed difference between signer and verifier clocks
+	Now       func() time.Time // defaults to time.Now
+}
+
+// VerifyRequest verifies the signature of an incoming request against body
+func (v *Verifier) VerifyRequest(r *http.Request, body []byte) (*SignatureParams, error) {
+	return v.Verify(RequestMessage(r), body)
+}
+
+// VerifyResponse verifies the signature of a response against body
+func (v *Verifier) VerifyResponse(resp *http.Response, body []byte) (*SignatureParams, error) {
+	return v.Verify(ResponseMessage(resp.StatusCode, resp.Header), body)
+}
+
+// Verify verifies the first signature on msg and returns its parameters
+func (v *Verifier) Verify(msg *Message, body []byte) (*SignatureParams, error) {
+	if !HasSignature(msg.Header) {
+		return nil, ErrNoSignature
+	}
+
+	inputs, err := ParseSignatureInput(msg.Header.Get("Signature-Input"))
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := ParseSignature(msg.Header.Get("Signature"))
+	if err != nil {
+		return nil, err
+	}
+
+	params := inputs[0]
+	sig, ok := signatures[params.Label]
+	if !ok {
+		return params, fmt.Errorf("%w: no Signature member for label %q", ErrMalformed, params.Label)
+	}
+
+	if params.Alg != "" && params.Alg != AlgEd25519 {
+		return params, fmt.Errorf("%w: unsupported alg %q", ErrSignatureInvalid, params.Alg)
+	}
+
+	resolve := v.Keys
+	if resolve == nil {
+		resolve = DemoKeyResolver
+	}
+	pub, err := resolve(params.KeyID)
+	if err != nil {
+		return params, err
+	}
+
+	// The digest binds the body to the signature; check it even if uncovered
+	if digest := msg.Header.Get("Content-Digest"); digest != "" || params.Covers("content-digest") {
+		if err := VerifyContentDigest(digest, body); err != nil {
+			return params, err
+		}
+	}
+
+	base, err := msg.SignatureBase(params)
+	if err != nil {
+		return params, err
+	}
+
+	if !ed25519.Verify(pub, []byte(base), sig) {
+		return params, ErrSignatureInvalid
+	}
+
+	if err := v.checkFreshness(params); err != nil {
+		return params, err
+	}
+
+	return params, nil
+}
+
+// checkFreshness rejects a valid signature that expired, is older than
+// MaxAge or was created in the future
+func (v *Verifier) checkFreshness(params *SignatureParams) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	if !params.Expires.IsZero() && now.After(params.Expires.Add(v.ClockSkew)) {
+		return fmt.Errorf("%w: expired %s ago", ErrStaleSignature, now.Sub(params.Expires).Truncate(time.Second))
+	}
+	if v.MaxAge <= 0 {
+		return nil
+	}
+	if params.Created.IsZero() {
+		return fmt.Errorf("%w: no created parameter", ErrStaleSignature)
+	}
+	switch age := params.Age(now); {
+	case age > v.MaxAge+v.ClockSkew:
+		return fmt.Errorf("%w: created %s ago, max age %s", ErrStaleSignature, age.Truncate(time.Second), v.MaxAge)
+	case age < -v.ClockSkew:
+		return fmt.Errorf("%w: created %s in the future", ErrStaleSignature, (-age).Truncate(time.Second))
+	}
+	return nil
+}
+
+// ParseSignatureInput parses an RFC 9421 Signature-Input header
+func ParseSignatureInput(header string) ([]*SignatureParams, error) {
+	var result []*SignatureParams
+
+	for _, member := range splitTopLevel(header, ',') {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+
+		label, value, ok := strings.Cut(member, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: Signature-Input member %q", ErrMalformed, member)
+		}
+
+		params, err := parseInnerList(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		params.Label = strings.TrimSpace(label)
+		result = append(result, params)
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: empty Signature-Input", ErrMalformed)
+	}
+	return result, nil
+}
+
+// ParseSignature parses an RFC 9421 Signature header into label -> signature bytes
+func ParseSignature(header string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+
+	for _, member := range splitTopLevel(header, ',') {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+
+		label, value, ok := strings.Cut(member, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: Signature member %q", ErrMalformed, member)
+		}
+
+		sig, err := decodeByteSequence(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		result[strings.TrimSpace(label)] = sig
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: empty Signature", ErrMalformed)
+	}
+	return result, nil
+}
+
+// parseInnerList parses `("@method" "content-digest");created=1;keyid="x"`
+func parseInnerList(value string) (*SignatureParams, error) {
+	if !strings.HasPrefix(value, "(") {
+		return nil, fmt.Errorf("%w: expected inner list, got %q", ErrMalformed, value)
+	}
+	end := closingParen(value)
+	if end < 0 {
+		return nil, fmt.Errorf("%w: unterminated inner list %q", ErrMalformed, value)
+	}
+
+	params := &SignatureParams{raw: value}
+
+	for _, item := range splitTopLevel(value[1:end], ' ') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := splitTopLevel(item, ';')
+		name, err := strconv.Unquote(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: component %q", ErrMalformed, parts[0])
+		}
+		c := Component{Name: strings.ToLower(name)}
+		for _, p := range parts[1:] {
+			if k, v, ok := strings.Cut(p, "="); ok && k == "name" {
+				c.Param, _ = strconv.Unquote(v)
+			}
+		}
+		params.Components = append(params.Components, c)
+	}
+
+	for _, p := range splitTopLevel(value[end+1:], ';') {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(p, "=")
+		switch k {
+		case "created", "expires":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s=%q", ErrMalformed, k, v)
+			}
+			if k == "created" {
+				params.Created = time.Unix(n, 0)
+			} else {
+				params.Expires = time.Unix(n, 0)
+			}
+		case "keyid", "alg", "nonce", "tag":
+			s, err := strconv.Unquote(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s=%q", ErrMalformed, k, v)
+			}
+			switch k {
+			case "keyid":
+				params.KeyID = s
+			case "alg":
+				params.Alg = s
+			case "nonce":
+				params.Nonce = s
+			case "tag":
+				params.Tag = s
+			}
+		}
+	}
+
+	return params, nil
+}
+
+// closingParen returns the index of the parenthesis closing value[0]
+func closingParen(value string) int {
+	inQuote := false
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			if inQuote {
+				i++
+			}
+		case '"':
+			inQuote = !inQuote
+		case ')':
+			if !inQuote {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits s on sep outside of quotes and parentheses
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	inQuote := false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inQuote {
+				i++
+			}
+		case '"':
+			inQuote = !inQuote
+		case '(':
+			if !inQuote {
+				depth++
+			}
+		case ')':
+			if !inQuote {
+				depth--
+			}
+		case sep:
+			if !inQuote && depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,262 @@
+package sage
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newSignedRequest(t *testing.T, body []byte, opts *SignOptions) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/payment?trace=1", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	if _, err := SignRequest(req, body, DemoKeyPair("root"), opts); err != nil {
+		t.Fatalf("SignRequest() error: %v", err)
+	}
+	return req
+}
+
+func TestSignRequest_Verify(t *testing.T) {
+	body := []byte(`{"amount":100}`)
+	req := newSignedRequest(t, body, nil)
+
+	v := &Verifier{}
+	params, err := v.VerifyRequest(req, body)
+	if err != nil {
+		t.Fatalf("VerifyRequest() error: %v", err)
+	}
+
+	if params.KeyID != DemoKeyID("root") {
+		t.Errorf("KeyID: got %s, want %s", params.KeyID, DemoKeyID("root"))
+	}
+	if params.Alg != AlgEd25519 {
+		t.Errorf("Alg: got %s, want %s", params.Alg, AlgEd25519)
+	}
+	if !params.Covers("content-digest") || !params.Covers("@path") {
+		t.Errorf("Components: got %v", params.Components)
+	}
+}
+
+func TestVerify_TamperedBody(t *testing.T) {
+	body := []byte(`{"amount":100}`)
+	req := newSignedRequest(t, body, nil)
+
+	_, err := (&Verifier{}).VerifyRequest(req, []byte(`{"amount":10000}`))
+	if !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Expected ErrDigestMismatch, got: %v", err)
+	}
+}
+
+func TestVerify_TamperedCoveredComponent(t *testing.T) {
+	body := []byte(`{"amount":100}`)
+	req := newSignedRequest(t, body, nil)
+	req.URL.Path = "/refund"
+
+	_, err := (&Verifier{}).VerifyRequest(req, body)
+	if !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("Expected ErrSignatureInvalid, got: %v", err)
+	}
+}
+
+func TestVerify_UncoveredHeaderChange(t *testing.T) {
+	body := []byte(`{"amount":100}`)
+	req := newSignedRequest(t, body, nil)
+	req.Header.Set("X-Priority", "urgent")
+
+	if _, err := (&Verifier{}).VerifyRequest(req, body); err != nil {
+		t.Errorf("Uncovered header should not break the signature, got: %v", err)
+	}
+}
+
+func TestVerify_NoSignature(t *testing.T) {
+	req := httptest.NewRequest("POST", "/payment", nil)
+
+	_, err := (&Verifier{}).VerifyRequest(req, nil)
+	if !errors.Is(err, ErrNoSignature) {
+		t.Errorf("Expected ErrNoSignature, got: %v", err)
+	}
+}
+
+func TestVerify_UnknownKey(t *testing.T) {
+	body := []byte(`{}`)
+	req := newSignedRequest(t, body, nil)
+
+	v := &Verifier{Keys: func(keyID string) (ed25519.PublicKey, error) {
+		return nil, ErrUnknownKey
+	}}
+	_, err := v.VerifyRequest(req, body)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got: %v", err)
+	}
+}
+
+func TestVerify_WrongSigner(t *testing.T) {
+	body := []byte(`{}`)
+	req := newSignedRequest(t, body, nil)
+
+	// Claim the signature came from another agent
+	req.Header.Set("Signature-Input", `sig1=("@method" "@path" "@query" "content-type" "content-digest");created=1;keyid="did:sage:demo:planning";alg="ed25519"`)
+
+	_, err := (&Verifier{}).VerifyRequest(req, body)
+	if !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("Expected ErrSignatureInvalid, got: %v", err)
+	}
+}
+
+func TestVerify_Freshness(t *testing.T) {
+	created := time.Unix(1700000000, 0)
+	tests := []struct {
+		name     string
+		expires  time.Duration
+		verifier *Verifier
+		at       time.Duration // verification time relative to created
+		stale    bool
+	}{
+		{"no limits", 0, &Verifier{}, time.Hour, false},
+		{"before expires", time.Minute, &Verifier{}, 30 * time.Second, false},
+		{"after expires", time.Minute, &Verifier{}, 61 * time.Second, true},
+		{"after expires within skew", time.Minute, &Verifier{ClockSkew: 5 * time.Second}, 61 * time.Second, false},
+		{"within max age", 0, &Verifier{MaxAge: time.Minute}, 59 * time.Second, false},
+		{"older than max age", 0, &Verifier{MaxAge: time.Minute}, 2 * time.Minute, true},
+		{"created in the future", 0, &Verifier{MaxAge: time.Minute}, -10 * time.Second, true},
+		{"future within skew", 0, &Verifier{MaxAge: time.Minute, ClockSkew: 30 * time.Second}, -10 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`{}`)
+			req := newSignedRequest(t, body, &SignOptions{Created: created, Expires: tt.expires})
+			tt.verifier.Now = func() time.Time { return created.Add(tt.at) }
+
+			_, err := tt.verifier.VerifyRequest(req, body)
+			if tt.stale && !errors.Is(err, ErrStaleSignature) {
+				t.Errorf("Expected ErrStaleSignature, got: %v", err)
+			} else if !tt.stale && err != nil {
+				t.Errorf("VerifyRequest() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSignatureParams_Deadline(t *testing.T) {
+	created := time.Unix(1700000000, 0)
+	p := &SignatureParams{Created: created, Expires: created.Add(time.Minute)}
+
+	if got := p.Deadline(0); !got.Equal(p.Expires) {
+		t.Errorf("Deadline(0): got %v, want expires %v", got, p.Expires)
+	}
+	if got := p.Deadline(30 * time.Second); !got.Equal(created.Add(30 * time.Second)) {
+		t.Errorf("Deadline(30s): got %v, want created+30s", got)
+	}
+	if got := (&SignatureParams{}).Deadline(time.Minute); !got.IsZero() {
+		t.Errorf("Deadline without created/expires: got %v, want zero", got)
+	}
+	if got := p.Age(created.Add(5 * time.Second)); got != 5*time.Second {
+		t.Errorf("Age: got %v, want 5s", got)
+	}
+}
+
+func TestSignResponse_Verify(t *testing.T) {
+	body := []byte(`{"status":"accepted"}`)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	if _, err := SignResponse(http.StatusOK, header, body, DemoKeyPair("payment"), nil); err != nil {
+		t.Fatalf("SignResponse() error: %v", err)
+	}
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: header}
+	if _, err := (&Verifier{}).VerifyResponse(resp, body); err != nil {
+		t.Errorf("VerifyResponse() error: %v", err)
+	}
+
+	resp.StatusCode = http.StatusForbidden
+	if _, err := (&Verifier{}).VerifyResponse(resp, body); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("Expected ErrSignatureInvalid after status change, got: %v", err)
+	}
+}
+
+func TestParseSignatureInput(t *testing.T) {
+	header := `sig1=("@method" "@query-param";name="id" "content-digest");created=1700000000;expires=1700000300;keyid="did:sage:demo:root";alg="ed25519";nonce="abc"`
+
+	inputs, err := ParseSignatureInput(header)
+	if err != nil {
+		t.Fatalf("ParseSignatureInput() error: %v", err)
+	}
+	if len(inputs) != 1 {
+		t.Fatalf("Expected 1 member, got %d", len(inputs))
+	}
+
+	p := inputs[0]
+	if p.Label != "sig1" {
+		t.Errorf("Label: got %s, want sig1", p.Label)
+	}
+	if len(p.Components) != 3 {
+		t.Fatalf("Components: got %d, want 3", len(p.Components))
+	}
+	if p.Components[1].Name != "@query-param" || p.Components[1].Param != "id" {
+		t.Errorf("Component[1]: got %+v", p.Components[1])
+	}
+	if !p.CoversQueryParam("id") || p.CoversQueryParam("other") {
+		t.Error("CoversQueryParam() mismatch")
+	}
+	if !p.Created.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Created: got %v", p.Created)
+	}
+	if !p.Expires.Equal(time.Unix(1700000300, 0)) {
+		t.Errorf("Expires: got %v", p.Expires)
+	}
+	if p.KeyID != "did:sage:demo:root" || p.Alg != "ed25519" || p.Nonce != "abc" {
+		t.Errorf("Params: got keyid=%s alg=%s nonce=%s", p.KeyID, p.Alg, p.Nonce)
+	}
+}
+
+func TestParseSignatureInput_Malformed(t *testing.T) {
+	tests := []string{
+		"",
+		"sig1",
+		`sig1="@method"`,
+		`sig1=("@method"`,
+		`sig1=("@method");created=abc`,
+	}
+
+	for _, header := range tests {
+		if _, err := ParseSignatureInput(header); !errors.Is(err, ErrMalformed) {
+			t.Errorf("ParseSignatureInput(%q): expected ErrMalformed, got %v", header, err)
+		}
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	sigs, err := ParseSignature(`sig1=:AQID:, sig2=:BAU=:`)
+	if err != nil {
+		t.Fatalf("ParseSignature() error: %v", err)
+	}
+	if !bytes.Equal(sigs["sig1"], []byte{1, 2, 3}) {
+		t.Errorf("sig1: got %v", sigs["sig1"])
+	}
+	if !bytes.Equal(sigs["sig2"], []byte{4, 5}) {
+		t.Errorf("sig2: got %v", sigs["sig2"])
+	}
+}
+
+func TestStripSignature(t *testing.T) {
+	body := []byte(`{}`)
+	req := newSignedRequest(t, body, nil)
+
+	if !HasSignature(req.Header) {
+		t.Fatal("Expected signed request")
+	}
+
+	StripSignature(req.Header)
+
+	if HasSignature(req.Header) || req.Header.Get("Content-Digest") != "" {
+		t.Error("StripSignature() left signature headers behind")
+	}
+}
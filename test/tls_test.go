@@ -0,0 +1,152 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/config"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/handlers"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/sage"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/tlsutil"
+	"github.com/sage-x-project/sage-gateway-infected-for-demo/types"
+)
+
+// TestTLSDoesNotStopTampering runs the whole chain over mTLS: root agent ->
+// gateway (HTTPS, client certificate required) -> payment agent (HTTPS,
+// client certificate required). Every hop is authenticated and encrypted, yet
+// the gateway terminates TLS and rewrites the amount. Only the end-to-end
+// RFC 9421 signature lets the agent notice.
+func TestTLSDoesNotStopTampering(t *testing.T) {
+	dir := t.TempDir()
+	ca, _, err := tlsutil.LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA() error: %v", err)
+	}
+	issue := func(name string, usage tlsutil.Usage) (string, string) {
+		certFile, keyFile, err := ca.Issue(name, usage)
+		if err != nil {
+			t.Fatalf("Issue(%s) error: %v", name, err)
+		}
+		return certFile, keyFile
+	}
+	agentCert, agentKey := issue("agent", tlsutil.ServerCert)
+	gatewayCert, gatewayKey := issue("gateway", tlsutil.ServerCert)
+	gatewayClientCert, gatewayClientKey := issue("gateway-client", tlsutil.ClientCert)
+	rootCert, rootKey := issue("root-agent", tlsutil.ClientCert)
+
+	// Payment agent: accepts any mTLS client, verifies signatures if present
+	type received struct {
+		amount    interface{}
+		transport string
+		signature string
+	}
+	var got received
+	agent := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var msg types.AgentMessage
+		json.Unmarshal(body, &msg)
+		got = received{amount: msg.Metadata["amount"], transport: tlsutil.Describe(r.TLS), signature: "absent"}
+
+		if _, err := (&sage.Verifier{}).VerifyRequest(r, body); err == nil {
+			got.signature = "verified"
+		} else if err != sage.ErrNoSignature {
+			got.signature = "invalid"
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	agent.TLS, err = tlsutil.ServerConfig(agentCert, agentKey, ca.CertFile(), tlsutil.ClientAuthRequire)
+	if err != nil {
+		t.Fatalf("agent ServerConfig() error: %v", err)
+	}
+	agent.StartTLS()
+	defer agent.Close()
+
+	// Compromised gateway: HTTPS listener, mTLS to the agent
+	cfg := &config.Config{
+		AttackEnabled:       true,
+		AttackType:          types.AttackTypePriceManipulation,
+		PriceMultiplier:     100.0,
+		TargetAgentURL:      agent.URL,
+		UpstreamTLSCAFile:   ca.CertFile(),
+		UpstreamTLSCertFile: gatewayClientCert,
+		UpstreamTLSKeyFile:  gatewayClientKey,
+	}
+	proxyHandler := handlers.NewProxyHandler(cfg)
+	gateway := httptest.NewUnstartedServer(http.HandlerFunc(proxyHandler.HandleRequest))
+	gateway.TLS, err = tlsutil.ServerConfig(gatewayCert, gatewayKey, ca.CertFile(), tlsutil.ClientAuthRequire)
+	if err != nil {
+		t.Fatalf("gateway ServerConfig() error: %v", err)
+	}
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	// Root agent: trusts the demo CA and presents its client certificate
+	clientTLS, err := tlsutil.ClientConfig(ca.CertFile(), rootCert, rootKey, false)
+	if err != nil {
+		t.Fatalf("ClientConfig() error: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}, Timeout: 5 * time.Second}
+
+	send := func(sign bool) int {
+		body, _ := json.Marshal(types.AgentMessage{
+			ID: "msg-tls", From: "root", To: "payment", Content: "Pay for sunglasses",
+			Timestamp: time.Now(), Type: "request",
+			Metadata: map[string]interface{}{"amount": 100.0},
+		})
+		req, _ := http.NewRequest("POST", gateway.URL+"/payment", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if sign {
+			if _, err := sage.SignRequest(req, body, sage.DemoKeyPair("root"), nil); err != nil {
+				t.Fatalf("SignRequest() error: %v", err)
+			}
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request through the gateway failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	t.Run("TLS only", func(t *testing.T) {
+		if status := send(false); status != http.StatusOK {
+			t.Fatalf("Status: got %d, want 200", status)
+		}
+		if got.amount != 10000.0 {
+			t.Errorf("Agent received amount %v over %q, want the tampered 10000", got.amount, got.transport)
+		}
+		if got.transport == "" {
+			t.Error("Agent connection should be TLS")
+		}
+
+		gw := proxyHandler.Capture().Last().Gateway
+		if gw.ClientTLS == "" || gw.UpstreamTLS == "" {
+			t.Errorf("Capture should record both TLS legs, got client %q upstream %q", gw.ClientTLS, gw.UpstreamTLS)
+		}
+	})
+
+	t.Run("TLS and SAGE signature", func(t *testing.T) {
+		if status := send(true); status != http.StatusUnauthorized {
+			t.Fatalf("Status: got %d, want 401", status)
+		}
+		if got.signature != "invalid" {
+			t.Errorf("Signature: got %s, want invalid", got.signature)
+		}
+	})
+
+	t.Run("client certificate required", func(t *testing.T) {
+		anonymous, _ := tlsutil.ClientConfig(ca.CertFile(), "", "", false)
+		noCert := &http.Client{Transport: &http.Transport{TLSClientConfig: anonymous}, Timeout: 5 * time.Second}
+		if resp, err := noCert.Get(gateway.URL + "/health"); err == nil {
+			resp.Body.Close()
+			t.Error("Gateway should refuse clients without a certificate")
+		}
+	})
+}
@@ -0,0 +1,184 @@
+// Package tlsutil provides the TLS setup shared by the gateway and the mock
+// agents: a demo certificate authority generated on first run, certificates
+// issued from it, and tls.Configs for HTTPS listeners and (m)TLS clients.
+//
+// The demo CA exists to show that transport security terminated at a
+// compromised gateway does not stop tampering. Its key is written to disk
+// unencrypted; never trust it outside a demo.
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File names inside the demo directory
+const (
+	CACertFile = "ca.pem"
+	CAKeyFile  = "ca-key.pem"
+)
+
+// Usage selects the extended key usage of an issued certificate
+type Usage int
+
+const (
+	ServerCert Usage = iota // TLS server authentication
+	ClientCert              // TLS client authentication (mTLS)
+)
+
+// DefaultHosts are the SANs of every demo server certificate
+var DefaultHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	leafValidity = 365 * 24 * time.Hour
+)
+
+// CA is the demo certificate authority
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+	dir  string
+}
+
+// LoadOrCreateCA loads the demo CA from dir, generating it on first run.
+// created reports whether a new CA was written.
+func LoadOrCreateCA(dir string) (ca *CA, created bool, err error) {
+	certPath, keyPath := filepath.Join(dir, CACertFile), filepath.Join(dir, CAKeyFile)
+
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil {
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return nil, false, fmt.Errorf("parse demo CA: %w", err)
+		}
+		signer, ok := pair.PrivateKey.(crypto.Signer)
+		if !ok || !cert.IsCA {
+			return nil, false, fmt.Errorf("%s is not a CA certificate", certPath)
+		}
+		return &CA{Cert: cert, Key: signer, dir: dir}, false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("load demo CA: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, false, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{CommonName: "SAGE Gateway Demo CA", Organization: []string{"SAGE demo (insecure)"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, false, err
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, false, err
+	}
+	if err := writePEM(certPath, keyPath, der, key); err != nil {
+		return nil, false, err
+	}
+	return &CA{Cert: cert, Key: key, dir: dir}, true, nil
+}
+
+// CertFile returns the path of the CA certificate, the bundle to trust
+func (ca *CA) CertFile() string {
+	return filepath.Join(ca.dir, CACertFile)
+}
+
+// Issue returns the certificate and key files of name (name.pem and
+// name-key.pem in the demo directory), issuing them from the CA unless a
+// pair signed by this CA is still valid. hosts are added to DefaultHosts as
+// SANs of server certificates.
+func (ca *CA) Issue(name string, usage Usage, hosts ...string) (certFile, keyFile string, err error) {
+	certFile = filepath.Join(ca.dir, name+".pem")
+	keyFile = filepath.Join(ca.dir, name+"-key.pem")
+	if ca.issued(certFile, keyFile) {
+		return certFile, keyFile, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"SAGE demo (insecure)"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	switch usage {
+	case ServerCert:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		for _, host := range append(append([]string{}, DefaultHosts...), hosts...) {
+			if ip := net.ParseIP(host); ip != nil {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			} else {
+				template.DNSNames = append(template.DNSNames, host)
+			}
+		}
+	case ClientCert:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.Key)
+	if err != nil {
+		return "", "", err
+	}
+	if err := writePEM(certFile, keyFile, der, key); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+// issued reports whether a valid pair signed by this CA already exists
+func (ca *CA) issued(certFile, keyFile string) bool {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil || time.Now().After(cert.NotAfter) {
+		return false
+	}
+	return cert.CheckSignatureFrom(ca.Cert) == nil
+}
+
+func writePEM(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)
+}
+
+func serialNumber() *big.Int {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return serial
+}
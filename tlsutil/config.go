@@ -0,0 +1,107 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Client certificate policies of an HTTPS listener (TLS_CLIENT_AUTH)
+const (
+	ClientAuthNone     = "none"     // no client certificate requested
+	ClientAuthOptional = "optional" // verified if presented
+	ClientAuthRequire  = "require"  // handshake fails without a valid certificate
+)
+
+// ServerConfig builds the tls.Config of an HTTPS listener. clientCAFiles
+// (comma-separated PEM bundles) verify client certificates unless clientAuth
+// is none.
+func ServerConfig(certFile, keyFile, clientCAFiles, clientAuth string) (*tls.Config, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	switch clientAuth {
+	case "", ClientAuthNone:
+		return config, nil
+	case ClientAuthOptional:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid client auth %q (valid: none, optional, require)", clientAuth)
+	}
+	if config.ClientCAs, err = LoadCertPool(clientCAFiles, false); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ClientConfig builds the tls.Config for dialing TLS servers. caFiles
+// (comma-separated PEM bundles) are trusted in addition to the system roots;
+// certFile and keyFile, if set, are presented for mTLS.
+func ClientConfig(caFiles, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+	if caFiles != "" {
+		pool, err := LoadCertPool(caFiles, true)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return config, nil
+}
+
+// LoadCertPool reads comma-separated PEM bundles into a pool, starting from
+// the system roots when withSystem is set
+func LoadCertPool(files string, withSystem bool) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if withSystem {
+		if system, err := x509.SystemCertPool(); err == nil {
+			pool = system
+		}
+	}
+	for _, file := range strings.Split(files, ",") {
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// Describe summarises a connection's TLS state for logs and captures, e.g.
+// "TLS 1.3, peer CN=gateway-client"; "" for plaintext connections
+func Describe(state *tls.ConnectionState) string {
+	if state == nil {
+		return ""
+	}
+	desc := tls.VersionName(state.Version)
+	if len(state.PeerCertificates) > 0 {
+		desc += ", peer CN=" + state.PeerCertificates[0].Subject.CommonName
+	}
+	return desc
+}
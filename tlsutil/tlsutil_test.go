@@ -0,0 +1,105 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOrCreateCA(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+
+	ca, created, err := LoadOrCreateCA(dir)
+	if err != nil || !created {
+		t.Fatalf("LoadOrCreateCA() = created %v, error %v; want a new CA", created, err)
+	}
+	again, created, err := LoadOrCreateCA(dir)
+	if err != nil || created {
+		t.Fatalf("Second LoadOrCreateCA() = created %v, error %v; want the stored CA", created, err)
+	}
+	if !again.Cert.Equal(ca.Cert) {
+		t.Error("Stored CA differs from the generated one")
+	}
+	if info, err := os.Stat(filepath.Join(dir, CAKeyFile)); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("CA key should be private, got %v (%v)", info.Mode().Perm(), err)
+	}
+}
+
+func TestCA_Issue(t *testing.T) {
+	ca, _, err := LoadOrCreateCA(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA() error: %v", err)
+	}
+
+	certFile, keyFile, err := ca.Issue("agent", ServerCert, "payment.local")
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Issued pair cannot be loaded: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(pair.Certificate[0])
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	for _, host := range []string{"localhost", "127.0.0.1", "payment.local"} {
+		if _, err := cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Errorf("Certificate not valid for %s: %v", host, err)
+		}
+	}
+
+	// A valid certificate is kept
+	before, _ := os.ReadFile(certFile)
+	if _, _, err := ca.Issue("agent", ServerCert); err != nil {
+		t.Fatalf("Second Issue() error: %v", err)
+	}
+	if after, _ := os.ReadFile(certFile); string(after) != string(before) {
+		t.Error("Issue() replaced a valid certificate")
+	}
+
+	// A certificate from another CA is replaced
+	other, _, _ := LoadOrCreateCA(t.TempDir())
+	if _, _, err := other.Issue("agent", ServerCert); err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+	if !ca.issued(certFile, keyFile) || other.issued(certFile, keyFile) {
+		t.Error("issued() should only accept certificates signed by the CA itself")
+	}
+}
+
+func TestServerConfig_ClientAuth(t *testing.T) {
+	ca, _, _ := LoadOrCreateCA(t.TempDir())
+	certFile, keyFile, _ := ca.Issue("gateway", ServerCert)
+
+	tests := map[string]tls.ClientAuthType{
+		ClientAuthNone:     tls.NoClientCert,
+		ClientAuthOptional: tls.VerifyClientCertIfGiven,
+		ClientAuthRequire:  tls.RequireAndVerifyClientCert,
+	}
+	for mode, want := range tests {
+		config, err := ServerConfig(certFile, keyFile, ca.CertFile(), mode)
+		if err != nil {
+			t.Fatalf("ServerConfig(%s) error: %v", mode, err)
+		}
+		if config.ClientAuth != want {
+			t.Errorf("ServerConfig(%s).ClientAuth = %v, want %v", mode, config.ClientAuth, want)
+		}
+	}
+	if _, err := ServerConfig(certFile, keyFile, ca.CertFile(), "always"); err == nil {
+		t.Error("ServerConfig() should reject an unknown client auth mode")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	if got := Describe(nil); got != "" {
+		t.Errorf("Describe(nil) = %q, want empty", got)
+	}
+	ca, _, _ := LoadOrCreateCA(t.TempDir())
+	state := &tls.ConnectionState{Version: tls.VersionTLS13, PeerCertificates: []*x509.Certificate{ca.Cert}}
+	if got := Describe(state); got != "TLS 1.3, peer CN=SAGE Gateway Demo CA" {
+		t.Errorf("Describe() = %q", got)
+	}
+}
@@ -14,6 +14,19 @@ type AgentMessage struct {
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// SecureMessage represents an HPKE-encrypted AgentMessage envelope
+// (matching the SAGE transport.SecureMessage format)
+type SecureMessage struct {
+	ID               string    `json:"id"`
+	ContextID        string    `json:"contextId,omitempty"`
+	From             string    `json:"from"`
+	To               string    `json:"to"`
+	Type             string    `json:"type"` // secure
+	KeyID            string    `json:"kid,omitempty"`
+	EncryptedPayload string    `json:"encryptedPayload"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
 // PaymentMessage represents a payment request message
 type PaymentMessage struct {
 	Amount      float64 `json:"amount"`
@@ -46,7 +59,27 @@ type AttackLog struct {
 	OriginalMsg    map[string]interface{} `json:"original_message"`
 	ModifiedMsg    map[string]interface{} `json:"modified_message"`
 	Changes        []Change               `json:"changes"`
+	HeaderChanges  []HeaderChange         `json:"header_changes,omitempty"`
+	Steps          []PipelineStep         `json:"steps,omitempty"`
 	TargetEndpoint string                 `json:"target_endpoint"`
+	Activation     *Activation            `json:"activation,omitempty"`
+	Upstream       *UpstreamResult        `json:"upstream,omitempty"`
+}
+
+// Activation records whether an enabled attack fired for a message and why
+type Activation struct {
+	Fired    bool    `json:"fired"`
+	Reason   string  `json:"reason"`
+	Seed     int64   `json:"seed"`
+	Sequence int     `json:"sequence"`       // 1 for the first decision since startup
+	Roll     float64 `json:"roll,omitempty"` // draw compared with ATTACK_PROBABILITY
+}
+
+// UpstreamResult records how the target agent answered a forwarded message
+type UpstreamResult struct {
+	StatusCode int    `json:"status_code"`
+	Body       string `json:"body,omitempty"`
+	Outcome    string `json:"outcome"` // accepted, rejected, error
 }
 
 // Change represents a single field modification
@@ -54,6 +87,22 @@ type Change struct {
 	Field         string      `json:"field"`
 	OriginalValue interface{} `json:"original_value"`
 	ModifiedValue interface{} `json:"modified_value"`
+	Step          string      `json:"step,omitempty"` // pipeline step (attack type) that made the change
+}
+
+// PipelineStep records what one step of an attack pipeline did
+type PipelineStep struct {
+	AttackType string `json:"attack_type"`
+	Status     string `json:"status"` // applied, skipped
+	Reason     string `json:"reason,omitempty"`
+}
+
+// HeaderChange represents a header rewritten to match a modified body
+type HeaderChange struct {
+	Header        string `json:"header"`
+	Action        string `json:"action"` // set, recomputed, stripped, resigned
+	OriginalValue string `json:"original_value,omitempty"`
+	ModifiedValue string `json:"modified_value,omitempty"`
 }
 
 // AttackType represents the type of attack
@@ -63,6 +112,14 @@ const (
 	AttackTypePriceManipulation   AttackType = "price_manipulation"
 	AttackTypeAddressManipulation AttackType = "address_manipulation"
 	AttackTypeProductSubstitution AttackType = "product_substitution"
+	AttackTypeStripSignature      AttackType = "strip_signature"      // removes the RFC 9421 signature, then tampers
+	AttackTypeHPKEDowngrade       AttackType = "hpke_downgrade"       // replaces an HPKE envelope with forged plaintext
+	AttackTypeUncoveredComponents AttackType = "uncovered_components" // tampers only where the signature does not reach
+	AttackTypeStaleSignature      AttackType = "stale_signature"      // holds a signed message until it is no longer fresh
+	AttackTypeIdentitySpoofing    AttackType = "identity_spoofing"    // rewrites the sender or the conversation (contextId)
+	AttackTypePromptInjection     AttackType = "prompt_injection"     // inserts instructions into content and A2A text parts
+	AttackTypeSalami              AttackType = "salami"               // small skims, rounding, currency/unit or quantity changes
+	AttackTypePipeline            AttackType = "pipeline"             // runs the ATTACK_PIPELINE steps in order
 	AttackTypeNone                AttackType = "none"
 )
 
@@ -75,4 +132,51 @@ type ProxyResponse struct {
 	AttackType     string                 `json:"attack_type,omitempty"`
 	TargetResponse interface{}            `json:"target_response,omitempty"`
 	Error          string                 `json:"error,omitempty"`
+	Upstreams      []UpstreamPool         `json:"upstreams,omitempty"`
+	Breakers       []BreakerStatus        `json:"circuit_breakers,omitempty"`
+}
+
+// UpstreamPool reports the replicas of a load-balanced agent
+type UpstreamPool struct {
+	Agent    string            `json:"agent"`
+	Policy   string            `json:"policy"`
+	Backends []UpstreamBackend `json:"backends"`
+}
+
+// UpstreamBackend reports the state of one replica
+type UpstreamBackend struct {
+	URL            string    `json:"url"`
+	Healthy        bool      `json:"healthy"`
+	ActiveRequests int64     `json:"active_requests"`
+	TotalRequests  int64     `json:"total_requests"`
+	Failures       int       `json:"consecutive_failures"`
+	LastCheck      time.Time `json:"last_check,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+}
+
+// BreakerStatus reports the circuit breaker of one upstream host
+type BreakerStatus struct {
+	Upstream  string `json:"upstream"`
+	State     string `json:"state"`
+	Requests  int    `json:"window_requests"`
+	Failures  int    `json:"window_failures"`
+	Successes int64  `json:"successes_total"`
+	Failed    int64  `json:"failures_total"`
+	Rejected  int64  `json:"rejected_total"`
+	Opened    int64  `json:"opened_total"`
+}
+
+// UpstreamAttempt records one attempt of the retrying client, including a
+// fingerprint of the body that was actually sent
+type UpstreamAttempt struct {
+	Attempt    int       `json:"attempt"`
+	StartedAt  time.Time `json:"started_at"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	BodySize   int64     `json:"body_size"`
+	BodySHA256 string    `json:"body_sha256,omitempty"`
+	Body       string    `json:"body,omitempty"` // first bytes of the body
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
 }
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -33,6 +34,12 @@ type Hub struct {
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
+
+	// Shutdown coordination
+	quit     chan struct{}
+	done     chan struct{}
+	quitOnce sync.Once
+	writers  sync.WaitGroup
 }
 
 var (
@@ -54,17 +61,35 @@ func NewHub() *Hub {
 		broadcast:  make(chan *LogEvent, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
 	}
 }
 
 // Run starts the WebSocket hub
+// It returns once Shutdown has been called and all clients have been released.
 func (h *Hub) Run() {
+	defer close(h.done)
+
 	for {
 		select {
+		case <-h.quit:
+			h.mu.Lock()
+			for client := range h.clients {
+				delete(h.clients, client)
+				close(client.send)
+			}
+			h.mu.Unlock()
+			log.Printf("[websocket] Hub stopped")
+			return
+
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
 			h.mu.Unlock()
+			// The writer is tracked here so Shutdown can wait for close frames
+			h.writers.Add(1)
+			go client.writePump()
 			log.Printf("[websocket] Client connected (total: %d)", len(h.clients))
 
 		case client := <-h.unregister:
@@ -92,6 +117,33 @@ func (h *Hub) Run() {
 	}
 }
 
+// Shutdown stops the hub, sends a close frame to every connected client and
+// waits until the close frames have been written or the context expires
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.quitOnce.Do(func() {
+		close(h.quit)
+	})
+
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	writersDone := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(writersDone)
+	}()
+
+	select {
+	case <-writersDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Broadcast sends a log event to all connected clients
 func (h *Hub) Broadcast(event *LogEvent) {
 	select {
@@ -135,17 +187,26 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 		hub:  h,
 	}
 
-	client.hub.register <- client
+	select {
+	case client.hub.register <- client:
+	case <-h.quit:
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+		conn.Close()
+		return
+	}
 
-	// Start goroutines for reading and writing
-	go client.writePump()
+	// The hub starts the write pump on registration; start the read pump here
 	go client.readPump()
 }
 
 // readPump pumps messages from the WebSocket connection to the hub
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.quit:
+		}
 		c.conn.Close()
 	}()
 
@@ -172,6 +233,7 @@ func (c *Client) writePump() {
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.writers.Done()
 	}()
 
 	for {
@@ -180,7 +242,8 @@ func (c *Client) writePump() {
 			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if !ok {
 				// Hub closed the channel
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
 				return
 			}
 
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -226,3 +227,80 @@ func TestHub_ClientDisconnect(t *testing.T) {
 		t.Errorf("Expected 0 clients after disconnect, got %d", hub.GetClientCount())
 	}
 }
+
+func TestHub_Shutdown(t *testing.T) {
+	hub := NewHub()
+	runDone := make(chan struct{})
+	go func() {
+		hub.Run()
+		close(runDone)
+	}()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error: %v", err)
+	}
+
+	select {
+	case <-runDone:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return after Shutdown()")
+	}
+
+	// Client should receive a close frame
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected CloseGoingAway, got: %v", err)
+	}
+
+	if hub.GetClientCount() != 0 {
+		t.Errorf("Expected 0 clients after shutdown, got %d", hub.GetClientCount())
+	}
+
+	// Calling Shutdown twice must be safe
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Errorf("Second Shutdown() error: %v", err)
+	}
+}
+
+func TestHub_ServeWS_AfterShutdown(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	hub.Shutdown(ctx)
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected CloseGoingAway for late client, got: %v", err)
+	}
+}